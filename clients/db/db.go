@@ -2,8 +2,9 @@ package db
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"time"
 
 	"github.com/marlosl/gpt-telegram-bot/consts"
 
@@ -29,11 +30,13 @@ type CacheItem struct {
 
 var MESSAGE = "MESSAGE"
 
+// batchWriteSize is the maximum number of items of a BatchWriteItem call.
+const batchWriteSize = 25
+
 func NewDBClient(tableName string, config *aws.Config) (*DBClient, error) {
 	sess, err := session.NewSession(config)
 	if err != nil {
-		fmt.Println("Got an error creating a new session:")
-		fmt.Println(err)
+		slog.Error("Got an error creating a new session", "error", err)
 		return nil, err
 	}
 
@@ -65,7 +68,7 @@ func (db *CacheRepository) SaveItem(item *string) error {
 
 	av, err := dynamodbattribute.MarshalMap(dbItem)
 	if err != nil {
-		log.Fatalf("Got error marshalling map: %s", err)
+		slog.Error("Got error marshalling map", "error", err)
 	}
 
 	input := &dynamodb.PutItemInput{
@@ -75,17 +78,17 @@ func (db *CacheRepository) SaveItem(item *string) error {
 
 	_, err = svc.PutItem(input)
 	if err != nil {
-		log.Fatalf("Got error calling PutItem: %s", err)
+		slog.Error("Got error calling PutItem", "error", err)
 	}
 
-	fmt.Println("Successfully added '" + *item + "' to table " + *db.TableName)
+	slog.Debug("Successfully added item", "item", *item, "table", *db.TableName)
 	return nil
 }
 
 func (db *CacheRepository) ItemExists(item string) bool {
 	dbItem, err := db.GetItem(item)
 	if err != nil {
-		fmt.Printf("Got error calling GetItem: %s\n", err)
+		slog.Error("Got error calling GetItem", "error", err)
 		return false
 	}
 
@@ -110,13 +113,13 @@ func (db *CacheRepository) GetItem(item string) (*string, error) {
 
 	result, err := svc.GetItem(input)
 	if err != nil {
-		fmt.Printf("Got error calling GetItem: %s\n", err)
+		slog.Error("Got error calling GetItem", "error", err)
 	}
 
 	dbItem := &CacheItem{}
 	err = dynamodbattribute.UnmarshalMap(result.Item, dbItem)
 	if err != nil {
-		fmt.Printf("Got error unmarshalling: %s\n", err)
+		slog.Error("Got error unmarshalling", "error", err)
 	}
 
 	return &dbItem.SK, nil
@@ -140,9 +143,73 @@ func (db *CacheRepository) DeleteItem(item string) error {
 
 	_, err := svc.DeleteItem(input)
 	if err != nil {
-		fmt.Printf("Got error calling DeleteItem: %v\n", err)
+		slog.Error("Got error calling DeleteItem", "error", err)
+	}
+
+	slog.Debug("Deleted item", "item", item, "table", *db.TableName)
+	return nil
+}
+
+// query returns all the items of the partition whose sort key starts with
+// prefix, following the result pages.
+func (db *DBClient) query(pk string, prefix string, items interface{}) error {
+	svc := dynamodb.New(db.Session)
+
+	var records []map[string]*dynamodb.AttributeValue
+	err := svc.QueryPages(&dynamodb.QueryInput{
+		TableName:              db.TableName,
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {S: aws.String(pk)},
+			":sk": {S: aws.String(prefix)},
+		},
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		records = append(records, page.Items...)
+		return true
+	})
+	if err != nil {
+		slog.Error("Got error calling Query", "error", err)
+		return err
 	}
 
-	fmt.Println("Deleted '" + item + "' from table " + *db.TableName)
+	err = dynamodbattribute.UnmarshalListOfMaps(records, items)
+	if err != nil {
+		slog.Error("Got error unmarshalling", "error", err)
+		return err
+	}
+	return nil
+}
+
+// batchWrite sends the requests in batches, retrying the unprocessed ones.
+func (db *DBClient) batchWrite(requests []*dynamodb.WriteRequest) error {
+	svc := dynamodb.New(db.Session)
+
+	for start := 0; start < len(requests); start += batchWriteSize {
+		end := start + batchWriteSize
+		if end > len(requests) {
+			end = len(requests)
+		}
+
+		pending := map[string][]*dynamodb.WriteRequest{
+			*db.TableName: requests[start:end],
+		}
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt > 0 {
+				time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+			}
+
+			output, err := svc.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+				RequestItems: pending,
+			})
+			if err != nil {
+				slog.Error("Got error calling BatchWriteItem", "error", err)
+				return err
+			}
+			pending = output.UnprocessedItems
+			if attempt == 5 && len(pending) > 0 {
+				return fmt.Errorf("could not write %d items", len(pending[*db.TableName]))
+			}
+		}
+	}
 	return nil
 }
@@ -0,0 +1,269 @@
+// Package dbtest serves an in-memory DynamoDB table for the tests of the
+// repositories and the handlers using them. It understands the calls the
+// repositories make on the table keys: GetItem, PutItem, DeleteItem with
+// attribute_exists and attribute_not_exists conditions, and queries on a
+// partition key, optionally with a begins_with on the sort key.
+package dbtest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const TableName = "test-table"
+
+type Item = map[string]*dynamodb.AttributeValue
+
+type Server struct {
+	*httptest.Server
+
+	mutex sync.Mutex
+	items map[string]map[string]Item
+	// Calls lists the operations received, like GetItem or Query.
+	Calls []string
+}
+
+func NewServer() *Server {
+	s := &Server{
+		items: map[string]map[string]Item{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Session returns a session sending the DynamoDB calls to the server.
+func (s *Server) Session() *session.Session {
+	return session.Must(session.NewSession(&aws.Config{
+		Endpoint:    aws.String(s.URL),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("test", "test", ""),
+		DisableSSL:  aws.Bool(true),
+		MaxRetries:  aws.Int(0),
+	}))
+}
+
+// Put stores the item as is.
+func (s *Server) Put(item Item) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.put(item)
+}
+
+// Get returns the item with the keys, or nil.
+func (s *Server) Get(pk string, sk string) Item {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.items[pk][sk]
+}
+
+// Items returns every item of the table.
+func (s *Server) Items() []Item {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var items []Item
+	for _, partition := range s.items {
+		for _, item := range partition {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (s *Server) put(item Item) {
+	pk, sk := keys(item)
+	if s.items[pk] == nil {
+		s.items[pk] = map[string]Item{}
+	}
+	s.items[pk][sk] = item
+}
+
+func keys(item Item) (string, string) {
+	return aws.StringValue(item["PK"].S), aws.StringValue(item["SK"].S)
+}
+
+type apiError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func validationError(format string, args ...interface{}) *apiError {
+	return &apiError{http.StatusBadRequest, "ValidationException", fmt.Sprintf(format, args...)}
+}
+
+var conditionFailed = &apiError{http.StatusBadRequest, "ConditionalCheckFailedException", "The conditional request failed"}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	operation := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810.")
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Calls = append(s.Calls, operation)
+
+	var output interface{}
+	var err *apiError
+	switch operation {
+	case "GetItem":
+		input := &dynamodb.GetItemInput{}
+		if err = decode(r, input); err == nil {
+			output, err = s.getItem(input)
+		}
+	case "PutItem":
+		input := &dynamodb.PutItemInput{}
+		if err = decode(r, input); err == nil {
+			output, err = s.putItem(input)
+		}
+	case "DeleteItem":
+		input := &dynamodb.DeleteItemInput{}
+		if err = decode(r, input); err == nil {
+			output, err = s.deleteItem(input)
+		}
+	case "Query":
+		input := &dynamodb.QueryInput{}
+		if err = decode(r, input); err == nil {
+			output, err = s.query(input)
+		}
+	default:
+		err = validationError("unsupported operation %s", operation)
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	if err != nil {
+		w.WriteHeader(err.status)
+		body, _ := jsonutil.BuildJSON(map[string]*string{
+			"__type":  aws.String("com.amazonaws.dynamodb.v20120810#" + err.code),
+			"message": aws.String(err.message),
+		})
+		w.Write(body)
+		return
+	}
+
+	body, buildErr := jsonutil.BuildJSON(output)
+	if buildErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Write(body)
+}
+
+func decode(r *http.Request, input interface{}) *apiError {
+	if err := jsonutil.UnmarshalJSON(input, r.Body); err != nil {
+		return validationError("invalid request: %v", err)
+	}
+	return nil
+}
+
+func (s *Server) getItem(input *dynamodb.GetItemInput) (interface{}, *apiError) {
+	pk, sk := keys(input.Key)
+	return &dynamodb.GetItemOutput{Item: s.items[pk][sk]}, nil
+}
+
+func (s *Server) putItem(input *dynamodb.PutItemInput) (interface{}, *apiError) {
+	pk, sk := keys(input.Item)
+	if err := checkCondition(input.ConditionExpression, s.items[pk][sk]); err != nil {
+		return nil, err
+	}
+	s.put(input.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (s *Server) deleteItem(input *dynamodb.DeleteItemInput) (interface{}, *apiError) {
+	pk, sk := keys(input.Key)
+	old := s.items[pk][sk]
+	if err := checkCondition(input.ConditionExpression, old); err != nil {
+		return nil, err
+	}
+	delete(s.items[pk], sk)
+
+	output := &dynamodb.DeleteItemOutput{}
+	if aws.StringValue(input.ReturnValues) == dynamodb.ReturnValueAllOld {
+		output.Attributes = old
+	}
+	return output, nil
+}
+
+func checkCondition(expression *string, item Item) *apiError {
+	switch aws.StringValue(expression) {
+	case "":
+		return nil
+	case "attribute_exists(PK)":
+		if item == nil {
+			return conditionFailed
+		}
+		return nil
+	case "attribute_not_exists(PK)":
+		if item != nil {
+			return conditionFailed
+		}
+		return nil
+	}
+	return validationError("unsupported condition %s", aws.StringValue(expression))
+}
+
+func (s *Server) query(input *dynamodb.QueryInput) (interface{}, *apiError) {
+	if input.IndexName != nil || input.FilterExpression != nil {
+		return nil, validationError("indexes and filters are not supported")
+	}
+
+	prefix := ""
+	switch aws.StringValue(input.KeyConditionExpression) {
+	case "PK = :pk":
+	case "PK = :pk AND begins_with(SK, :sk)":
+		prefix = aws.StringValue(input.ExpressionAttributeValues[":sk"].S)
+	default:
+		return nil, validationError("unsupported key condition %s", aws.StringValue(input.KeyConditionExpression))
+	}
+
+	partition := s.items[aws.StringValue(input.ExpressionAttributeValues[":pk"].S)]
+	var sortKeys []string
+	for sk := range partition {
+		if strings.HasPrefix(sk, prefix) {
+			sortKeys = append(sortKeys, sk)
+		}
+	}
+
+	forward := input.ScanIndexForward == nil || *input.ScanIndexForward
+	sort.Strings(sortKeys)
+	if !forward {
+		sort.Sort(sort.Reverse(sort.StringSlice(sortKeys)))
+	}
+
+	if input.ExclusiveStartKey != nil {
+		_, start := keys(input.ExclusiveStartKey)
+		for len(sortKeys) > 0 && (forward && sortKeys[0] <= start || !forward && sortKeys[0] >= start) {
+			sortKeys = sortKeys[1:]
+		}
+	}
+
+	output := &dynamodb.QueryOutput{Items: []Item{}}
+	for _, sk := range sortKeys {
+		if input.Limit != nil && int64(len(output.Items)) == *input.Limit {
+			break
+		}
+		output.Items = append(output.Items, partition[sk])
+	}
+
+	// Like DynamoDB, a page stopped by the limit has a last evaluated key
+	// even when no items are left.
+	if input.Limit != nil && int64(len(output.Items)) == *input.Limit && len(output.Items) > 0 {
+		last := output.Items[len(output.Items)-1]
+		output.LastEvaluatedKey = Item{"PK": last["PK"], "SK": last["SK"]}
+	}
+	output.Count = aws.Int64(int64(len(output.Items)))
+	return output, nil
+}
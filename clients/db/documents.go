@@ -0,0 +1,170 @@
+package db
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// DocumentExpiration is how long a document stays attached to a chat.
+const DocumentExpiration = 7 * 24 * time.Hour
+
+type DocumentRepository struct {
+	DBClient
+}
+
+type Document struct {
+	PK         string `json:"pk" dynamodbav:"PK"`
+	SK         string `json:"sk" dynamodbav:"SK"`
+	ChatId     string `json:"chatId" dynamodbav:"ChatId"`
+	DocumentId string `json:"documentId" dynamodbav:"DocumentId"`
+	Name       string `json:"name" dynamodbav:"Name"`
+	MimeType   string `json:"mimeType,omitempty" dynamodbav:"MimeType,omitempty"`
+	Length     int    `json:"length" dynamodbav:"Length"`
+	Chunks     int    `json:"chunks" dynamodbav:"Chunks"`
+	CreatedAt  int64  `json:"createdAt" dynamodbav:"CreatedAt"`
+	ExpiresAt  int64  `json:"expiresAt" dynamodbav:"ExpiresAt"`
+}
+
+type DocumentChunk struct {
+	PK         string `json:"pk" dynamodbav:"PK"`
+	SK         string `json:"sk" dynamodbav:"SK"`
+	DocumentId string `json:"documentId" dynamodbav:"DocumentId"`
+	Index      int    `json:"index" dynamodbav:"Index"`
+	Content    string `json:"content" dynamodbav:"Content"`
+	ExpiresAt  int64  `json:"expiresAt" dynamodbav:"ExpiresAt"`
+}
+
+func NewDocumentRepository() (*DocumentRepository, error) {
+	tableName := os.Getenv(consts.CacheTable)
+	dbClient, err := NewDBClient(tableName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DocumentRepository{
+		*dbClient,
+	}, nil
+}
+
+func documentKey(documentId string) string {
+	return "DOC#" + documentId
+}
+
+func chunkPrefix(documentId string) string {
+	return "CHUNK#" + documentId + "#"
+}
+
+// SaveDocument stores the document and its chunks, replacing a document
+// with the same id.
+func (db *DocumentRepository) SaveDocument(document *Document, chunks []string) error {
+	now := time.Now()
+	document.PK = chatKey(document.ChatId)
+	document.SK = documentKey(document.DocumentId)
+	document.Chunks = len(chunks)
+	document.CreatedAt = now.Unix()
+	document.ExpiresAt = now.Add(DocumentExpiration).Unix()
+
+	var requests []*dynamodb.WriteRequest
+	for i, content := range chunks {
+		av, err := dynamodbattribute.MarshalMap(&DocumentChunk{
+			PK:         document.PK,
+			SK:         fmt.Sprintf("%s%05d", chunkPrefix(document.DocumentId), i),
+			DocumentId: document.DocumentId,
+			Index:      i,
+			Content:    content,
+			ExpiresAt:  document.ExpiresAt,
+		})
+		if err != nil {
+			slog.Error("Got error marshalling map", "error", err)
+			return err
+		}
+		requests = append(requests, &dynamodb.WriteRequest{
+			PutRequest: &dynamodb.PutRequest{Item: av},
+		})
+	}
+
+	err := db.batchWrite(requests)
+	if err != nil {
+		return err
+	}
+
+	av, err := dynamodbattribute.MarshalMap(document)
+	if err != nil {
+		slog.Error("Got error marshalling map", "error", err)
+		return err
+	}
+
+	svc := dynamodb.New(db.Session)
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		Item:      av,
+		TableName: db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling PutItem", "error", err)
+		return err
+	}
+	return nil
+}
+
+// ListDocuments returns the documents attached to the chat, oldest first.
+func (db *DocumentRepository) ListDocuments(chatId string) ([]Document, error) {
+	var documents []Document
+	err := db.query(chatKey(chatId), "DOC#", &documents)
+	if err != nil {
+		return nil, err
+	}
+
+	var attached []Document
+	now := time.Now().Unix()
+	for _, document := range documents {
+		// The TTL removes items lazily, skip the expired ones.
+		if document.ExpiresAt >= now {
+			attached = append(attached, document)
+		}
+	}
+	return attached, nil
+}
+
+// ListChunks returns the chunks of the document in order.
+func (db *DocumentRepository) ListChunks(chatId string, documentId string) ([]DocumentChunk, error) {
+	var chunks []DocumentChunk
+	err := db.query(chatKey(chatId), chunkPrefix(documentId), &chunks)
+	return chunks, err
+}
+
+// DeleteDocument removes the document and its chunks.
+func (db *DocumentRepository) DeleteDocument(chatId string, documentId string) error {
+	pk := chatKey(chatId)
+
+	var chunks []DocumentChunk
+	err := db.query(pk, chunkPrefix(documentId), &chunks)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{documentKey(documentId)}
+	for _, chunk := range chunks {
+		keys = append(keys, chunk.SK)
+	}
+
+	var requests []*dynamodb.WriteRequest
+	for _, sk := range keys {
+		requests = append(requests, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{
+				Key: map[string]*dynamodb.AttributeValue{
+					"PK": {S: aws.String(pk)},
+					"SK": {S: aws.String(sk)},
+				},
+			},
+		})
+	}
+	return db.batchWrite(requests)
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+type GalleryRepository struct {
+	DBClient
+}
+
+type GalleryImage struct {
+	PK        string `json:"pk" dynamodbav:"PK"`
+	SK        string `json:"sk" dynamodbav:"SK"`
+	UserId    string `json:"userId" dynamodbav:"UserId"`
+	ChatId    string `json:"chatId" dynamodbav:"ChatId"`
+	Key       string `json:"key" dynamodbav:"Key"`
+	Prompt    string `json:"prompt" dynamodbav:"Prompt"`
+	Model     string `json:"model" dynamodbav:"Model"`
+	CreatedAt int64  `json:"createdAt" dynamodbav:"CreatedAt"`
+}
+
+func NewGalleryRepository() (*GalleryRepository, error) {
+	tableName := os.Getenv(consts.CacheTable)
+	dbClient, err := NewDBClient(tableName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GalleryRepository{
+		*dbClient,
+	}, nil
+}
+
+func userKey(userId string) string {
+	return "USER#" + userId
+}
+
+func (db *GalleryRepository) SaveImage(image *GalleryImage) error {
+	svc := dynamodb.New(db.Session)
+
+	image.PK = userKey(image.UserId)
+	image.SK = fmt.Sprintf("IMAGE#%d#%s", image.CreatedAt, image.Key)
+
+	av, err := dynamodbattribute.MarshalMap(image)
+	if err != nil {
+		slog.Error("Got error marshalling map", "error", err)
+		return err
+	}
+
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		Item:      av,
+		TableName: db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling PutItem", "error", err)
+		return err
+	}
+	return nil
+}
+
+// ListImages returns the most recent images of the user, newest first.
+func (db *GalleryRepository) ListImages(userId string, limit int64) ([]GalleryImage, error) {
+	svc := dynamodb.New(db.Session)
+
+	result, err := svc.Query(&dynamodb.QueryInput{
+		TableName:              db.TableName,
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {S: aws.String(userKey(userId))},
+			":sk": {S: aws.String("IMAGE#")},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int64(limit),
+	})
+	if err != nil {
+		slog.Error("Got error calling Query", "error", err)
+		return nil, err
+	}
+
+	var images []GalleryImage
+	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &images)
+	if err != nil {
+		slog.Error("Got error unmarshalling", "error", err)
+		return nil, err
+	}
+	return images, nil
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// HistoryExpiration is how long a message is kept in the chat history.
+// Items are removed by the DynamoDB TTL on ExpiresAt.
+const HistoryExpiration = 7 * 24 * time.Hour
+
+type HistoryRepository struct {
+	DBClient
+}
+
+type HistoryMessage struct {
+	PK        string `json:"pk" dynamodbav:"PK"`
+	SK        string `json:"sk" dynamodbav:"SK"`
+	ChatId    string `json:"chatId" dynamodbav:"ChatId"`
+	ThreadId  string `json:"threadId,omitempty" dynamodbav:"ThreadId,omitempty"`
+	MessageId int64  `json:"messageId" dynamodbav:"MessageId"`
+	Role      string `json:"role" dynamodbav:"Role"`
+	Name      string `json:"name,omitempty" dynamodbav:"Name,omitempty"`
+	Content   string `json:"content" dynamodbav:"Content"`
+	CreatedAt int64  `json:"createdAt" dynamodbav:"CreatedAt"`
+	ExpiresAt int64  `json:"expiresAt" dynamodbav:"ExpiresAt"`
+}
+
+func NewHistoryRepository() (*HistoryRepository, error) {
+	tableName := os.Getenv(consts.CacheTable)
+	dbClient, err := NewDBClient(tableName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HistoryRepository{
+		*dbClient,
+	}, nil
+}
+
+// historyPrefix returns the key prefix of the messages of the thread. The
+// messages of every thread of the chat share the empty thread prefix, as
+// do the messages stored before threads existed.
+func historyPrefix(threadId string) string {
+	if threadId == "" {
+		return "HISTORY#"
+	}
+	return "HISTORY#" + threadId + "#"
+}
+
+func (db *HistoryRepository) AddMessage(message *HistoryMessage) error {
+	svc := dynamodb.New(db.Session)
+
+	now := time.Now()
+	if message.CreatedAt == 0 {
+		message.CreatedAt = now.UnixNano()
+	}
+	message.PK = chatKey(message.ChatId)
+	message.SK = fmt.Sprintf("%s%020d", historyPrefix(message.ThreadId), message.CreatedAt)
+	message.ExpiresAt = now.Add(HistoryExpiration).Unix()
+
+	av, err := dynamodbattribute.MarshalMap(message)
+	if err != nil {
+		slog.Error("Got error marshalling map", "error", err)
+		return err
+	}
+
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		Item:      av,
+		TableName: db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling PutItem", "error", err)
+		return err
+	}
+	return nil
+}
+
+// ListMessages returns the last messages of the thread, oldest first.
+func (db *HistoryRepository) ListMessages(chatId string, threadId string, limit int64) ([]HistoryMessage, error) {
+	svc := dynamodb.New(db.Session)
+
+	result, err := svc.Query(&dynamodb.QueryInput{
+		TableName:              db.TableName,
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {S: aws.String(chatKey(chatId))},
+			":sk": {S: aws.String(historyPrefix(threadId))},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int64(limit),
+	})
+	if err != nil {
+		slog.Error("Got error calling Query", "error", err)
+		return nil, err
+	}
+
+	var messages []HistoryMessage
+	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &messages)
+	if err != nil {
+		slog.Error("Got error unmarshalling", "error", err)
+		return nil, err
+	}
+
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// ListAllMessages returns every stored message of the thread, oldest first.
+func (db *HistoryRepository) ListAllMessages(chatId string, threadId string) ([]HistoryMessage, error) {
+	var messages []HistoryMessage
+	err := db.query(chatKey(chatId), historyPrefix(threadId), &messages)
+	return messages, err
+}
+
+// DeleteMessages removes every stored message of the thread.
+func (db *HistoryRepository) DeleteMessages(chatId string, threadId string) error {
+	messages, err := db.ListAllMessages(chatId, threadId)
+	if err != nil {
+		return err
+	}
+
+	var requests []*dynamodb.WriteRequest
+	for _, message := range messages {
+		requests = append(requests, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{
+				Key: map[string]*dynamodb.AttributeValue{
+					"PK": {S: aws.String(message.PK)},
+					"SK": {S: aws.String(message.SK)},
+				},
+			},
+		})
+	}
+	return db.batchWrite(requests)
+}
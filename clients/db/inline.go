@@ -0,0 +1,160 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// InlineExpiration is how long an inline answer is reused for the same
+// query of the same user.
+const InlineExpiration = time.Hour
+
+type InlineRepository struct {
+	DBClient
+}
+
+type InlineResult struct {
+	PK        string `json:"pk" dynamodbav:"PK"`
+	SK        string `json:"sk" dynamodbav:"SK"`
+	UserId    string `json:"userId" dynamodbav:"UserId"`
+	Query     string `json:"query" dynamodbav:"Query"`
+	Content   string `json:"content" dynamodbav:"Content"`
+	ExpiresAt int64  `json:"expiresAt" dynamodbav:"ExpiresAt"`
+}
+
+type InlineQueryMark struct {
+	PK        string `json:"pk" dynamodbav:"PK"`
+	SK        string `json:"sk" dynamodbav:"SK"`
+	QueryId   string `json:"queryId" dynamodbav:"QueryId"`
+	ExpiresAt int64  `json:"expiresAt" dynamodbav:"ExpiresAt"`
+}
+
+var INLINE_LATEST = "INLINE#LATEST"
+
+func NewInlineRepository() (*InlineRepository, error) {
+	tableName := os.Getenv(consts.CacheTable)
+	dbClient, err := NewDBClient(tableName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InlineRepository{
+		*dbClient,
+	}, nil
+}
+
+func inlineResultKey(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return "INLINE#" + hex.EncodeToString(sum[:])
+}
+
+// GetResult returns the answer stored for the query of the user, or nil
+// when there is none or it has expired.
+func (db *InlineRepository) GetResult(userId string, query string) (*InlineResult, error) {
+	svc := dynamodb.New(db.Session)
+
+	result, err := svc.GetItem(&dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String(userKey(userId))},
+			"SK": {S: aws.String(inlineResultKey(query))},
+		},
+		TableName: db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling GetItem", "error", err)
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	item := &InlineResult{}
+	err = dynamodbattribute.UnmarshalMap(result.Item, item)
+	if err != nil {
+		slog.Error("Got error unmarshalling", "error", err)
+		return nil, err
+	}
+
+	// The TTL removes items lazily, an expired one may still be returned.
+	if item.ExpiresAt < time.Now().Unix() {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (db *InlineRepository) SaveResult(userId string, query string, content string) error {
+	return db.put(&InlineResult{
+		PK:        userKey(userId),
+		SK:        inlineResultKey(query),
+		UserId:    userId,
+		Query:     query,
+		Content:   content,
+		ExpiresAt: time.Now().Add(InlineExpiration).Unix(),
+	})
+}
+
+// MarkLatestQuery records the last inline query the user typed.
+func (db *InlineRepository) MarkLatestQuery(userId string, queryId string) error {
+	return db.put(&InlineQueryMark{
+		PK:        userKey(userId),
+		SK:        INLINE_LATEST,
+		QueryId:   queryId,
+		ExpiresAt: time.Now().Add(InlineExpiration).Unix(),
+	})
+}
+
+// LatestQuery returns the id of the last inline query the user typed.
+func (db *InlineRepository) LatestQuery(userId string) (string, error) {
+	svc := dynamodb.New(db.Session)
+
+	result, err := svc.GetItem(&dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String(userKey(userId))},
+			"SK": {S: aws.String(INLINE_LATEST)},
+		},
+		TableName:      db.TableName,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		slog.Error("Got error calling GetItem", "error", err)
+		return "", err
+	}
+
+	mark := &InlineQueryMark{}
+	err = dynamodbattribute.UnmarshalMap(result.Item, mark)
+	if err != nil {
+		slog.Error("Got error unmarshalling", "error", err)
+		return "", err
+	}
+	return mark.QueryId, nil
+}
+
+func (db *InlineRepository) put(item interface{}) error {
+	svc := dynamodb.New(db.Session)
+
+	av, err := dynamodbattribute.MarshalMap(item)
+	if err != nil {
+		slog.Error("Got error marshalling map", "error", err)
+		return err
+	}
+
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		Item:      av,
+		TableName: db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling PutItem", "error", err)
+		return err
+	}
+	return nil
+}
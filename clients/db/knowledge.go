@@ -0,0 +1,92 @@
+package db
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+type KnowledgeRepository struct {
+	DBClient
+}
+
+// KnowledgeEntry is a chunk of the knowledge base with its embedding,
+// stored as little-endian float32 values.
+type KnowledgeEntry struct {
+	PK      string `json:"pk" dynamodbav:"PK"`
+	SK      string `json:"sk" dynamodbav:"SK"`
+	Source  string `json:"source" dynamodbav:"Source"`
+	Index   int    `json:"index" dynamodbav:"Index"`
+	Content string `json:"content" dynamodbav:"Content"`
+	Vector  []byte `json:"vector" dynamodbav:"Vector"`
+}
+
+var KNOWLEDGE = "KNOWLEDGE"
+
+func NewKnowledgeRepository() (*KnowledgeRepository, error) {
+	tableName := os.Getenv(consts.CacheTable)
+	dbClient, err := NewDBClient(tableName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KnowledgeRepository{
+		*dbClient,
+	}, nil
+}
+
+func knowledgePrefix(source string) string {
+	return "KNOWLEDGE#" + source + "#"
+}
+
+func (db *KnowledgeRepository) SaveEntries(entries []KnowledgeEntry) error {
+	var requests []*dynamodb.WriteRequest
+	for i := range entries {
+		entry := &entries[i]
+		entry.PK = KNOWLEDGE
+		entry.SK = fmt.Sprintf("%s%05d", knowledgePrefix(entry.Source), entry.Index)
+
+		av, err := dynamodbattribute.MarshalMap(entry)
+		if err != nil {
+			return err
+		}
+		requests = append(requests, &dynamodb.WriteRequest{
+			PutRequest: &dynamodb.PutRequest{Item: av},
+		})
+	}
+	return db.batchWrite(requests)
+}
+
+// ListEntries returns the whole knowledge base.
+func (db *KnowledgeRepository) ListEntries() ([]KnowledgeEntry, error) {
+	var entries []KnowledgeEntry
+	err := db.query(KNOWLEDGE, "KNOWLEDGE#", &entries)
+	return entries, err
+}
+
+// DeleteSource removes the entries of a source, before it is ingested again.
+func (db *KnowledgeRepository) DeleteSource(source string) error {
+	var entries []KnowledgeEntry
+	err := db.query(KNOWLEDGE, knowledgePrefix(source), &entries)
+	if err != nil {
+		return err
+	}
+
+	var requests []*dynamodb.WriteRequest
+	for _, entry := range entries {
+		requests = append(requests, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{
+				Key: map[string]*dynamodb.AttributeValue{
+					"PK": {S: aws.String(KNOWLEDGE)},
+					"SK": {S: aws.String(entry.SK)},
+				},
+			},
+		})
+	}
+	return db.batchWrite(requests)
+}
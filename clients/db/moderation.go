@@ -0,0 +1,104 @@
+package db
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// ModerationExpiration is how long the audit of moderated texts is kept.
+const ModerationExpiration = 90 * 24 * time.Hour
+
+type ModerationRepository struct {
+	DBClient
+}
+
+// ModerationRecord is the audit of a text flagged by the moderation, with
+// the action taken and an excerpt of the text.
+type ModerationRecord struct {
+	PK         string   `json:"pk" dynamodbav:"PK"`
+	SK         string   `json:"sk" dynamodbav:"SK"`
+	ChatId     string   `json:"chatId" dynamodbav:"ChatId"`
+	UserId     string   `json:"userId,omitempty" dynamodbav:"UserId,omitempty"`
+	UserName   string   `json:"userName,omitempty" dynamodbav:"UserName,omitempty"`
+	Stage      string   `json:"stage" dynamodbav:"Stage"`
+	Action     string   `json:"action" dynamodbav:"Action"`
+	Categories []string `json:"categories" dynamodbav:"Categories"`
+	Excerpt    string   `json:"excerpt,omitempty" dynamodbav:"Excerpt,omitempty"`
+	CreatedAt  int64    `json:"createdAt" dynamodbav:"CreatedAt"`
+	ExpiresAt  int64    `json:"expiresAt" dynamodbav:"ExpiresAt"`
+}
+
+func NewModerationRepository() (*ModerationRepository, error) {
+	tableName := os.Getenv(consts.CacheTable)
+	dbClient, err := NewDBClient(tableName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ModerationRepository{
+		*dbClient,
+	}, nil
+}
+
+func (db *ModerationRepository) AddRecord(record *ModerationRecord) error {
+	now := time.Now()
+	if record.CreatedAt == 0 {
+		record.CreatedAt = now.UnixNano()
+	}
+	record.PK = chatKey(record.ChatId)
+	record.SK = fmt.Sprintf("MODERATION#%020d", record.CreatedAt)
+	record.ExpiresAt = now.Add(ModerationExpiration).Unix()
+
+	av, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		slog.Error("Got error marshalling map", "error", err)
+		return err
+	}
+
+	svc := dynamodb.New(db.Session)
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		Item:      av,
+		TableName: db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling PutItem", "error", err)
+		return err
+	}
+	return nil
+}
+
+// ListRecords returns the last audit records of the chat, newest first.
+func (db *ModerationRepository) ListRecords(chatId string, limit int64) ([]ModerationRecord, error) {
+	svc := dynamodb.New(db.Session)
+
+	result, err := svc.Query(&dynamodb.QueryInput{
+		TableName:              db.TableName,
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {S: aws.String(chatKey(chatId))},
+			":sk": {S: aws.String("MODERATION#")},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int64(limit),
+	})
+	if err != nil {
+		slog.Error("Got error calling Query", "error", err)
+		return nil, err
+	}
+
+	var records []ModerationRecord
+	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &records)
+	if err != nil {
+		slog.Error("Got error unmarshalling", "error", err)
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+const (
+	PersonalPrompt = "personal"
+	TeamPrompt     = "team"
+)
+
+type PromptRepository struct {
+	DBClient
+}
+
+// PromptTemplate is a saved prompt. Personal prompts belong to a user and
+// are kept under the user key, team prompts are shared by a chat and kept
+// under the chat key.
+type PromptTemplate struct {
+	PK        string `json:"pk" dynamodbav:"PK"`
+	SK        string `json:"sk" dynamodbav:"SK"`
+	Scope     string `json:"scope" dynamodbav:"Scope"`
+	OwnerId   string `json:"ownerId" dynamodbav:"OwnerId"`
+	Name      string `json:"name" dynamodbav:"Name"`
+	Template  string `json:"template" dynamodbav:"Template"`
+	CreatedBy string `json:"createdBy,omitempty" dynamodbav:"CreatedBy,omitempty"`
+	CreatedAt int64  `json:"createdAt" dynamodbav:"CreatedAt"`
+	UpdatedAt int64  `json:"updatedAt" dynamodbav:"UpdatedAt"`
+}
+
+func NewPromptRepository() (*PromptRepository, error) {
+	tableName := os.Getenv(consts.CacheTable)
+	dbClient, err := NewDBClient(tableName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PromptRepository{
+		*dbClient,
+	}, nil
+}
+
+func promptOwnerKey(scope string, ownerId string) string {
+	if scope == TeamPrompt {
+		return chatKey(ownerId)
+	}
+	return userKey(ownerId)
+}
+
+func promptKey(name string) string {
+	return "PROMPT#" + name
+}
+
+func (db *PromptRepository) SavePrompt(prompt *PromptTemplate) error {
+	now := time.Now().Unix()
+	if prompt.CreatedAt == 0 {
+		prompt.CreatedAt = now
+	}
+	prompt.UpdatedAt = now
+	prompt.PK = promptOwnerKey(prompt.Scope, prompt.OwnerId)
+	prompt.SK = promptKey(prompt.Name)
+
+	av, err := dynamodbattribute.MarshalMap(prompt)
+	if err != nil {
+		slog.Error("Got error marshalling map", "error", err)
+		return err
+	}
+
+	svc := dynamodb.New(db.Session)
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		Item:      av,
+		TableName: db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling PutItem", "error", err)
+		return err
+	}
+	return nil
+}
+
+// GetPrompt returns the prompt, or nil when it does not exist.
+func (db *PromptRepository) GetPrompt(scope string, ownerId string, name string) (*PromptTemplate, error) {
+	svc := dynamodb.New(db.Session)
+	result, err := svc.GetItem(&dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String(promptOwnerKey(scope, ownerId))},
+			"SK": {S: aws.String(promptKey(name))},
+		},
+		TableName: db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling GetItem", "error", err)
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	prompt := &PromptTemplate{}
+	err = dynamodbattribute.UnmarshalMap(result.Item, prompt)
+	if err != nil {
+		slog.Error("Got error unmarshalling", "error", err)
+		return nil, err
+	}
+	return prompt, nil
+}
+
+// ListPrompts returns the prompts of the owner sorted by name.
+func (db *PromptRepository) ListPrompts(scope string, ownerId string) ([]PromptTemplate, error) {
+	var prompts []PromptTemplate
+	err := db.query(promptOwnerKey(scope, ownerId), "PROMPT#", &prompts)
+	return prompts, err
+}
+
+func (db *PromptRepository) DeletePrompt(scope string, ownerId string, name string) error {
+	svc := dynamodb.New(db.Session)
+	_, err := svc.DeleteItem(&dynamodb.DeleteItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String(promptOwnerKey(scope, ownerId))},
+			"SK": {S: aws.String(promptKey(name))},
+		},
+		TableName: db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling DeleteItem", "error", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+const (
+	ReminderSchedule = "reminder"
+	PromptSchedule   = "prompt"
+
+	// ScheduleDueIndex is the global secondary index of the schedules by
+	// their next run, so the scheduler only reads the due ones.
+	ScheduleDueIndex = "ScheduleDueIndex"
+)
+
+// SCHEDULES is the partition of ScheduleDueIndex holding the schedules of
+// every chat.
+var SCHEDULES = "SCHEDULES"
+
+type ScheduleRepository struct {
+	DBClient
+}
+
+// Schedule is a reminder sent once at NextRun, or a prompt whose answer is
+// sent every time the cron expression fires.
+type Schedule struct {
+	PK         string `json:"pk" dynamodbav:"PK"`
+	SK         string `json:"sk" dynamodbav:"SK"`
+	ScheduleId string `json:"scheduleId" dynamodbav:"ScheduleId"`
+	ChatId     string `json:"chatId" dynamodbav:"ChatId"`
+	Kind       string `json:"kind" dynamodbav:"Kind"`
+	Text       string `json:"text" dynamodbav:"Text"`
+	Cron       string `json:"cron,omitempty" dynamodbav:"Cron,omitempty"`
+	NextRun    int64  `json:"nextRun" dynamodbav:"NextRun"`
+	DuePK      string `json:"-" dynamodbav:"DuePK"`
+	CreatedAt  int64  `json:"createdAt" dynamodbav:"CreatedAt"`
+}
+
+func NewScheduleRepository() (*ScheduleRepository, error) {
+	tableName := os.Getenv(consts.CacheTable)
+	dbClient, err := NewDBClient(tableName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ScheduleRepository{
+		*dbClient,
+	}, nil
+}
+
+func scheduleKey(scheduleId string) string {
+	return "SCHEDULE#" + scheduleId
+}
+
+// SaveSchedule keeps the schedule in the partition of its chat, indexed by
+// its next run in ScheduleDueIndex.
+func (db *ScheduleRepository) SaveSchedule(schedule *Schedule) error {
+	schedule.PK = chatKey(schedule.ChatId)
+	schedule.SK = scheduleKey(schedule.ScheduleId)
+	schedule.DuePK = SCHEDULES
+
+	av, err := dynamodbattribute.MarshalMap(schedule)
+	if err != nil {
+		slog.Error("Got error marshalling map", "error", err)
+		return err
+	}
+
+	svc := dynamodb.New(db.Session)
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		Item:      av,
+		TableName: db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling PutItem", "error", err)
+		return err
+	}
+	return nil
+}
+
+// AdvanceSchedule moves the schedule from the previous run to its next
+// one. It reports false, without an error, when the schedule was deleted
+// or already moved by another run, so the run is not repeated.
+func (db *ScheduleRepository) AdvanceSchedule(schedule *Schedule, previous int64) (bool, error) {
+	svc := dynamodb.New(db.Session)
+	_, err := svc.UpdateItem(&dynamodb.UpdateItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String(chatKey(schedule.ChatId))},
+			"SK": {S: aws.String(scheduleKey(schedule.ScheduleId))},
+		},
+		UpdateExpression:    aws.String("SET NextRun = :next"),
+		ConditionExpression: aws.String("attribute_exists(PK) AND NextRun = :previous"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":next":     {N: aws.String(strconv.FormatInt(schedule.NextRun, 10))},
+			":previous": {N: aws.String(strconv.FormatInt(previous, 10))},
+		},
+		TableName: db.TableName,
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	if err != nil {
+		slog.Error("Got error calling UpdateItem", "error", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// ListSchedules returns the schedules of the chat, oldest first.
+func (db *ScheduleRepository) ListSchedules(chatId string) ([]Schedule, error) {
+	var schedules []Schedule
+	err := db.query(chatKey(chatId), "SCHEDULE#", &schedules)
+	return schedules, err
+}
+
+// ListDueSchedules returns the schedules of every chat whose next run is
+// not after now, querying ScheduleDueIndex.
+func (db *ScheduleRepository) ListDueSchedules(now int64) ([]Schedule, error) {
+	svc := dynamodb.New(db.Session)
+
+	var records []map[string]*dynamodb.AttributeValue
+	err := svc.QueryPages(&dynamodb.QueryInput{
+		TableName:              db.TableName,
+		IndexName:              aws.String(ScheduleDueIndex),
+		KeyConditionExpression: aws.String("DuePK = :pk AND NextRun <= :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk":  {S: aws.String(SCHEDULES)},
+			":now": {N: aws.String(strconv.FormatInt(now, 10))},
+		},
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		records = append(records, page.Items...)
+		return true
+	})
+	if err != nil {
+		slog.Error("Got error calling Query", "error", err)
+		return nil, err
+	}
+
+	var due []Schedule
+	err = dynamodbattribute.UnmarshalListOfMaps(records, &due)
+	if err != nil {
+		slog.Error("Got error unmarshalling", "error", err)
+		return nil, err
+	}
+	return due, nil
+}
+
+func (db *ScheduleRepository) DeleteSchedule(chatId string, scheduleId string) error {
+	svc := dynamodb.New(db.Session)
+	_, err := svc.DeleteItem(&dynamodb.DeleteItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String(chatKey(chatId))},
+			"SK": {S: aws.String(scheduleKey(scheduleId))},
+		},
+		TableName: db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling DeleteItem", "error", err)
+		return err
+	}
+	return nil
+}
+
+// TakeSchedule deletes the schedule to run it once. It reports false,
+// without an error, when the schedule was already deleted by another run or
+// by the chat, so a reminder is not sent twice.
+func (db *ScheduleRepository) TakeSchedule(chatId string, scheduleId string) (bool, error) {
+	svc := dynamodb.New(db.Session)
+	_, err := svc.DeleteItem(&dynamodb.DeleteItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String(chatKey(chatId))},
+			"SK": {S: aws.String(scheduleKey(scheduleId))},
+		},
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+		TableName:           db.TableName,
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	if err != nil {
+		slog.Error("Got error calling DeleteItem", "error", err)
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,168 @@
+package db
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+type SettingsRepository struct {
+	DBClient
+}
+
+type ChatSettings struct {
+	PK              string   `json:"pk" dynamodbav:"PK"`
+	SK              string   `json:"sk" dynamodbav:"SK"`
+	ChatId          string   `json:"chatId" dynamodbav:"ChatId"`
+	VoiceMode       string   `json:"voiceMode" dynamodbav:"VoiceMode"`
+	AllowedCommands []string `json:"allowedCommands,omitempty" dynamodbav:"AllowedCommands,omitempty"`
+	QuietHoursStart string   `json:"quietHoursStart,omitempty" dynamodbav:"QuietHoursStart,omitempty"`
+	QuietHoursEnd   string   `json:"quietHoursEnd,omitempty" dynamodbav:"QuietHoursEnd,omitempty"`
+	TimeZone        string   `json:"timeZone,omitempty" dynamodbav:"TimeZone,omitempty"`
+	AllowedTools    []string `json:"allowedTools,omitempty" dynamodbav:"AllowedTools,omitempty"`
+	ToolsDisabled   bool     `json:"toolsDisabled,omitempty" dynamodbav:"ToolsDisabled,omitempty"`
+	AutoSummarize   bool     `json:"autoSummarize,omitempty" dynamodbav:"AutoSummarize,omitempty"`
+	ActiveThread    string   `json:"activeThread,omitempty" dynamodbav:"ActiveThread,omitempty"`
+}
+
+var SETTINGS = "SETTINGS"
+
+// The attributes of the settings, as passed to UpdateSettings.
+const (
+	VoiceModeSetting       = "VoiceMode"
+	AllowedCommandsSetting = "AllowedCommands"
+	QuietHoursStartSetting = "QuietHoursStart"
+	QuietHoursEndSetting   = "QuietHoursEnd"
+	TimeZoneSetting        = "TimeZone"
+	AllowedToolsSetting    = "AllowedTools"
+	ToolsDisabledSetting   = "ToolsDisabled"
+	AutoSummarizeSetting   = "AutoSummarize"
+	ActiveThreadSetting    = "ActiveThread"
+)
+
+func NewSettingsRepository() (*SettingsRepository, error) {
+	tableName := os.Getenv(consts.CacheTable)
+	dbClient, err := NewDBClient(tableName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SettingsRepository{
+		*dbClient,
+	}, nil
+}
+
+func chatKey(chatId string) string {
+	return "CHAT#" + chatId
+}
+
+// GetSettings returns the settings stored for the chat, or empty settings
+// when nothing has been saved yet.
+func (db *SettingsRepository) GetSettings(chatId string) (*ChatSettings, error) {
+	svc := dynamodb.New(db.Session)
+
+	pk := chatKey(chatId)
+	input := &dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {
+				S: &pk,
+			},
+			"SK": {
+				S: &SETTINGS,
+			},
+		},
+		TableName: db.TableName,
+	}
+
+	result, err := svc.GetItem(input)
+	if err != nil {
+		slog.Error("Got error calling GetItem", "error", err)
+		return nil, err
+	}
+
+	settings := &ChatSettings{
+		PK:     pk,
+		SK:     SETTINGS,
+		ChatId: chatId,
+	}
+	if result.Item == nil {
+		return settings, nil
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Item, settings)
+	if err != nil {
+		slog.Error("Got error unmarshalling", "error", err)
+		return nil, err
+	}
+	return settings, nil
+}
+
+// UpdateSettings writes the given attributes of the settings, e.g.
+// VoiceModeSetting, leaving the others as they are, so concurrent changes
+// of different settings do not overwrite each other. Empty attributes are
+// removed.
+func (db *SettingsRepository) UpdateSettings(settings *ChatSettings, attributes ...string) error {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	av, err := dynamodbattribute.MarshalMap(settings)
+	if err != nil {
+		slog.Error("Got error marshalling map", "error", err)
+		return err
+	}
+
+	expression, names, values := updateExpression(av, attributes)
+	input := &dynamodb.UpdateItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String(chatKey(settings.ChatId))},
+			"SK": {S: aws.String(SETTINGS)},
+		},
+		UpdateExpression:         aws.String(expression),
+		ExpressionAttributeNames: names,
+		TableName:                db.TableName,
+	}
+	if len(values) > 0 {
+		input.ExpressionAttributeValues = values
+	}
+
+	_, err = dynamodb.New(db.Session).UpdateItem(input)
+	if err != nil {
+		slog.Error("Got error calling UpdateItem", "error", err)
+		return err
+	}
+	return nil
+}
+
+// updateExpression builds the UpdateItem expression that sets the given
+// attributes of item, or removes them when item does not have them.
+func updateExpression(item map[string]*dynamodb.AttributeValue, attributes []string) (string, map[string]*string, map[string]*dynamodb.AttributeValue) {
+	names := map[string]*string{}
+	values := map[string]*dynamodb.AttributeValue{}
+	var set, remove []string
+	for _, attribute := range attributes {
+		name := "#" + attribute
+		names[name] = aws.String(attribute)
+		if value, ok := item[attribute]; ok {
+			values[":"+attribute] = value
+			set = append(set, name+" = :"+attribute)
+		} else {
+			remove = append(remove, name)
+		}
+	}
+
+	var clauses []string
+	if len(set) > 0 {
+		clauses = append(clauses, "SET "+strings.Join(set, ", "))
+	}
+	if len(remove) > 0 {
+		clauses = append(clauses, "REMOVE "+strings.Join(remove, ", "))
+	}
+	return strings.Join(clauses, " "), names, values
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateExpression(t *testing.T) {
+	tests := []struct {
+		name       string
+		settings   ChatSettings
+		attributes []string
+		expression string
+		values     map[string]*dynamodb.AttributeValue
+	}{
+		{
+			name:       "set one attribute",
+			settings:   ChatSettings{ChatId: "1", VoiceMode: "both", TimeZone: "Europe/Lisbon"},
+			attributes: []string{VoiceModeSetting},
+			expression: "SET #VoiceMode = :VoiceMode",
+			values: map[string]*dynamodb.AttributeValue{
+				":VoiceMode": {S: aws.String("both")},
+			},
+		},
+		{
+			name:       "remove empty attributes",
+			settings:   ChatSettings{ChatId: "1"},
+			attributes: []string{QuietHoursStartSetting, QuietHoursEndSetting},
+			expression: "REMOVE #QuietHoursStart, #QuietHoursEnd",
+			values:     map[string]*dynamodb.AttributeValue{},
+		},
+		{
+			name:       "set and remove",
+			settings:   ChatSettings{ChatId: "1", AllowedTools: []string{"calculator"}},
+			attributes: []string{AllowedToolsSetting, ToolsDisabledSetting},
+			expression: "SET #AllowedTools = :AllowedTools REMOVE #ToolsDisabled",
+			values: map[string]*dynamodb.AttributeValue{
+				":AllowedTools": {L: []*dynamodb.AttributeValue{{S: aws.String("calculator")}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, err := dynamodbattribute.MarshalMap(tt.settings)
+			require.NoError(t, err)
+
+			expression, names, values := updateExpression(item, tt.attributes)
+			assert.Equal(t, tt.expression, expression)
+			assert.Equal(t, tt.values, values)
+			assert.Len(t, names, len(tt.attributes))
+			for _, attribute := range tt.attributes {
+				assert.Equal(t, attribute, aws.StringValue(names["#"+attribute]))
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+var STATUS = "STATUS"
+
+// StatusRepository keeps the status of the dialog a chat is in. The status
+// is any record, marshalled under the chat key together with the time it
+// expires.
+type StatusRepository struct {
+	DBClient
+}
+
+func NewStatusRepository() (*StatusRepository, error) {
+	tableName := os.Getenv(consts.CacheTable)
+	dbClient, err := NewDBClient(tableName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StatusRepository{
+		*dbClient,
+	}, nil
+}
+
+// SaveStatus stores the status of the chat until the given time.
+func (db *StatusRepository) SaveStatus(chatId string, status interface{}, expiresAt time.Time) error {
+	av, err := dynamodbattribute.MarshalMap(status)
+	if err != nil {
+		slog.Error("Got error marshalling map", "error", err)
+		return err
+	}
+	av["PK"] = &dynamodb.AttributeValue{S: aws.String(chatKey(chatId))}
+	av["SK"] = &dynamodb.AttributeValue{S: aws.String(STATUS)}
+	av["ExpiresAt"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(expiresAt.Unix(), 10))}
+
+	svc := dynamodb.New(db.Session)
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		Item:      av,
+		TableName: db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling PutItem", "error", err)
+		return err
+	}
+	return nil
+}
+
+// GetStatus loads the status of the chat into status and reports whether
+// there is one that has not expired.
+func (db *StatusRepository) GetStatus(chatId string, status interface{}) (bool, error) {
+	svc := dynamodb.New(db.Session)
+	result, err := svc.GetItem(&dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String(chatKey(chatId))},
+			"SK": {S: aws.String(STATUS)},
+		},
+		ConsistentRead: aws.Bool(true),
+		TableName:      db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling GetItem", "error", err)
+		return false, err
+	}
+	if result.Item == nil {
+		return false, nil
+	}
+
+	// The TTL removes items lazily, skip an expired status.
+	if expiresAt, ok := result.Item["ExpiresAt"]; ok && expiresAt.N != nil {
+		seconds, err := strconv.ParseInt(*expiresAt.N, 10, 64)
+		if err == nil && seconds < time.Now().Unix() {
+			return false, nil
+		}
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Item, status)
+	if err != nil {
+		slog.Error("Got error unmarshalling", "error", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (db *StatusRepository) DeleteStatus(chatId string) error {
+	svc := dynamodb.New(db.Session)
+	_, err := svc.DeleteItem(&dynamodb.DeleteItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String(chatKey(chatId))},
+			"SK": {S: aws.String(STATUS)},
+		},
+		TableName: db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling DeleteItem", "error", err)
+		return err
+	}
+	return nil
+}
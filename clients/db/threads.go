@@ -0,0 +1,196 @@
+package db
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+type ThreadRepository struct {
+	DBClient
+}
+
+// Thread is a named conversation of a chat. Its messages are kept in the
+// history under the thread id, and the thread expires with them unless the
+// conversation goes on.
+type Thread struct {
+	PK        string `json:"pk" dynamodbav:"PK"`
+	SK        string `json:"sk" dynamodbav:"SK"`
+	ChatId    string `json:"chatId" dynamodbav:"ChatId"`
+	ThreadId  string `json:"threadId" dynamodbav:"ThreadId"`
+	Title     string `json:"title,omitempty" dynamodbav:"Title,omitempty"`
+	CreatedAt int64  `json:"createdAt" dynamodbav:"CreatedAt"`
+	UpdatedAt int64  `json:"updatedAt" dynamodbav:"UpdatedAt"`
+	ExpiresAt int64  `json:"expiresAt" dynamodbav:"ExpiresAt"`
+}
+
+func NewThreadRepository() (*ThreadRepository, error) {
+	tableName := os.Getenv(consts.CacheTable)
+	dbClient, err := NewDBClient(tableName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ThreadRepository{
+		*dbClient,
+	}, nil
+}
+
+func threadKey(threadId string) string {
+	return "THREAD#" + threadId
+}
+
+// NewThreadId returns an id that sorts by creation time. Ids start with a
+// letter, so the keys of their messages never mix with the older ones.
+func NewThreadId() string {
+	return fmt.Sprintf("T%020d", time.Now().UnixNano())
+}
+
+// CreateThread stores a new thread for the chat.
+func (db *ThreadRepository) CreateThread(chatId string, title string) (*Thread, error) {
+	now := time.Now().Unix()
+	thread := &Thread{
+		ChatId:    chatId,
+		ThreadId:  NewThreadId(),
+		Title:     title,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return thread, db.SaveThread(thread)
+}
+
+// SaveThread stores the thread, extending its expiration.
+func (db *ThreadRepository) SaveThread(thread *Thread) error {
+	thread.PK = chatKey(thread.ChatId)
+	thread.SK = threadKey(thread.ThreadId)
+	thread.ExpiresAt = time.Now().Add(HistoryExpiration).Unix()
+
+	av, err := dynamodbattribute.MarshalMap(thread)
+	if err != nil {
+		slog.Error("Got error marshalling map", "error", err)
+		return err
+	}
+
+	svc := dynamodb.New(db.Session)
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		Item:      av,
+		TableName: db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling PutItem", "error", err)
+		return err
+	}
+	return nil
+}
+
+// GetThread returns the thread, or nil when it does not exist.
+func (db *ThreadRepository) GetThread(chatId string, threadId string) (*Thread, error) {
+	svc := dynamodb.New(db.Session)
+	result, err := svc.GetItem(&dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String(chatKey(chatId))},
+			"SK": {S: aws.String(threadKey(threadId))},
+		},
+		TableName: db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling GetItem", "error", err)
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	thread := &Thread{}
+	err = dynamodbattribute.UnmarshalMap(result.Item, thread)
+	if err != nil {
+		slog.Error("Got error unmarshalling", "error", err)
+		return nil, err
+	}
+	return thread, nil
+}
+
+// ListThreads returns a page of the threads of the chat, newest first,
+// starting after the thread id of the previous page. The id to pass for
+// the next page is empty on the last one. The TTL removes items lazily, so
+// the expired threads are skipped and the query goes on until the page is
+// full.
+func (db *ThreadRepository) ListThreads(chatId string, limit int64, after string) ([]Thread, string, error) {
+	pk := chatKey(chatId)
+	var start map[string]*dynamodb.AttributeValue
+	if after != "" {
+		start = map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String(pk)},
+			"SK": {S: aws.String(threadKey(after))},
+		}
+	}
+
+	svc := dynamodb.New(db.Session)
+	var active []Thread
+	now := time.Now().Unix()
+	for {
+		result, err := svc.Query(&dynamodb.QueryInput{
+			TableName:              db.TableName,
+			KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":pk": {S: aws.String(pk)},
+				":sk": {S: aws.String("THREAD#")},
+			},
+			ScanIndexForward:  aws.Bool(false),
+			Limit:             aws.Int64(limit - int64(len(active))),
+			ExclusiveStartKey: start,
+		})
+		if err != nil {
+			slog.Error("Got error calling Query", "error", err)
+			return nil, "", err
+		}
+
+		var threads []Thread
+		err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &threads)
+		if err != nil {
+			slog.Error("Got error unmarshalling", "error", err)
+			return nil, "", err
+		}
+
+		for _, thread := range threads {
+			if thread.ExpiresAt >= now {
+				active = append(active, thread)
+			}
+		}
+
+		start = result.LastEvaluatedKey
+		if len(start) == 0 || int64(len(active)) >= limit {
+			break
+		}
+	}
+
+	next := ""
+	if sk, ok := start["SK"]; ok && sk.S != nil {
+		next = strings.TrimPrefix(*sk.S, "THREAD#")
+	}
+	return active, next, nil
+}
+
+func (db *ThreadRepository) DeleteThread(chatId string, threadId string) error {
+	svc := dynamodb.New(db.Session)
+	_, err := svc.DeleteItem(&dynamodb.DeleteItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String(chatKey(chatId))},
+			"SK": {S: aws.String(threadKey(threadId))},
+		},
+		TableName: db.TableName,
+	})
+	if err != nil {
+		slog.Error("Got error calling DeleteItem", "error", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db/dbtest"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestThreadRepository(t *testing.T) (*dbtest.Server, *ThreadRepository) {
+	server := dbtest.NewServer()
+	t.Cleanup(server.Close)
+	return server, &ThreadRepository{DBClient{
+		TableName: aws.String(dbtest.TableName),
+		Session:   server.Session(),
+	}}
+}
+
+// putThreads stores threads T1..Tn of the chat, expiring at the given
+// times, without extending them like SaveThread does.
+func putThreads(t *testing.T, server *dbtest.Server, chatId string, expiresAt ...time.Time) {
+	for i, expiration := range expiresAt {
+		thread := &Thread{
+			ChatId:    chatId,
+			ThreadId:  fmt.Sprintf("T%d", i+1),
+			ExpiresAt: expiration.Unix(),
+		}
+		thread.PK = chatKey(chatId)
+		thread.SK = threadKey(thread.ThreadId)
+
+		item, err := dynamodbattribute.MarshalMap(thread)
+		require.NoError(t, err)
+		server.Put(item)
+	}
+}
+
+func threadIds(threads []Thread) []string {
+	ids := []string{}
+	for _, thread := range threads {
+		ids = append(ids, thread.ThreadId)
+	}
+	return ids
+}
+
+func TestListThreads(t *testing.T) {
+	server, repository := newTestThreadRepository(t)
+	active := time.Now().Add(time.Hour)
+	putThreads(t, server, "1", active, active, active, active, active)
+	putThreads(t, server, "2", active)
+
+	pages := []struct {
+		after    string
+		wantIds  []string
+		wantNext string
+	}{
+		{"", []string{"T5", "T4"}, "T4"},
+		{"T4", []string{"T3", "T2"}, "T2"},
+		{"T2", []string{"T1"}, ""},
+	}
+
+	for _, page := range pages {
+		t.Run("after "+page.after, func(t *testing.T) {
+			threads, next, err := repository.ListThreads("1", 2, page.after)
+			require.NoError(t, err)
+			assert.Equal(t, page.wantIds, threadIds(threads))
+			assert.Equal(t, page.wantNext, next)
+		})
+	}
+}
+
+func TestListThreadsSkipsExpired(t *testing.T) {
+	server, repository := newTestThreadRepository(t)
+	active := time.Now().Add(time.Hour)
+	expired := time.Now().Add(-time.Hour)
+	putThreads(t, server, "1", active, expired, active, expired, expired, active, expired)
+
+	pages := []struct {
+		after    string
+		wantIds  []string
+		wantNext string
+	}{
+		{"", []string{"T6", "T3"}, "T3"},
+		{"T3", []string{"T1"}, ""},
+	}
+
+	for _, page := range pages {
+		t.Run("after "+page.after, func(t *testing.T) {
+			threads, next, err := repository.ListThreads("1", 2, page.after)
+			require.NoError(t, err)
+			assert.Equal(t, page.wantIds, threadIds(threads))
+			assert.Equal(t, page.wantNext, next)
+		})
+	}
+
+	threads, next, err := repository.ListThreads("2", 2, "")
+	require.NoError(t, err)
+	assert.Empty(t, threads)
+	assert.Equal(t, "", next)
+}
+
+func TestThreadLifecycle(t *testing.T) {
+	_, repository := newTestThreadRepository(t)
+
+	thread, err := repository.CreateThread("1", "Trip to Lisbon")
+	require.NoError(t, err)
+	assert.Greater(t, thread.ExpiresAt, time.Now().Unix())
+
+	stored, err := repository.GetThread("1", thread.ThreadId)
+	require.NoError(t, err)
+	assert.Equal(t, thread, stored)
+
+	require.NoError(t, repository.DeleteThread("1", thread.ThreadId))
+	stored, err = repository.GetThread("1", thread.ThreadId)
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+}
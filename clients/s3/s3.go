@@ -0,0 +1,80 @@
+package s3
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// PresignExpiration is the longest expiration allowed for SigV4 presigned URLs.
+const PresignExpiration = 7 * 24 * time.Hour
+
+type S3Client struct {
+	Bucket    *string
+	PublicUrl string
+	Session   *session.Session
+}
+
+// NewS3Client creates a client for the bucket. When IMAGE_BUCKET_ENDPOINT is
+// set the client talks to that S3-compatible server (e.g. MinIO) using
+// path-style addressing.
+func NewS3Client(bucket string) (*S3Client, error) {
+	cfg := aws.NewConfig()
+	if endpoint := os.Getenv(consts.ImageBucketEndpoint); endpoint != "" {
+		cfg = cfg.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		slog.Error("Got an error creating a new session", "error", err)
+		return nil, err
+	}
+
+	return &S3Client{
+		Bucket:    &bucket,
+		PublicUrl: strings.TrimSuffix(os.Getenv(consts.ImageBucketPublicUrl), "/"),
+		Session:   sess,
+	}, nil
+}
+
+func (s *S3Client) PutObject(key string, body io.ReadSeeker, contentType string, metadata map[string]string) error {
+	svc := s3.New(s.Session)
+
+	_, err := svc.PutObject(&s3.PutObjectInput{
+		Bucket:      s.Bucket,
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+		Metadata:    aws.StringMap(metadata),
+	})
+	if err != nil {
+		slog.Error("Got an error putting object", "key", key, "error", err)
+		return err
+	}
+	return nil
+}
+
+// GetUrl returns the public URL of the object when the bucket is exposed
+// through IMAGE_BUCKET_PUBLIC_URL, e.g. by the CloudFront distribution of
+// the deploy, or a presigned URL otherwise. Presigned URLs expire after
+// PresignExpiration, so they are only meant for local setups.
+func (s *S3Client) GetUrl(key string) (string, error) {
+	if s.PublicUrl != "" {
+		return s.PublicUrl + "/" + key, nil
+	}
+
+	svc := s3.New(s.Session)
+	req, _ := svc.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: s.Bucket,
+		Key:    aws.String(key),
+	})
+	return req.Presign(PresignExpiration)
+}
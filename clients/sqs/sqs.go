@@ -1,11 +1,16 @@
 package sqs
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"strconv"
 
+	"github.com/marlosl/gpt-telegram-bot/consts"
+	"github.com/marlosl/gpt-telegram-bot/utils/logger"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sqs"
@@ -19,15 +24,13 @@ type SQSClient struct {
 func NewSQSClient(queue *string) (*SQSClient, error) {
 	sess, err := session.NewSession()
 	if err != nil {
-		fmt.Println("Got an error creating a new session:")
-		fmt.Println(err)
+		slog.Error("Got an error creating a new session", "error", err)
 		return nil, err
 	}
 
 	result, err := GetQueueURL(sess, queue)
 	if err != nil {
-		fmt.Println("Got an error getting the queue URL:")
-		fmt.Println(err)
+		slog.Error("Got an error getting the queue URL", "error", err)
 		return nil, err
 	}
 
@@ -37,29 +40,38 @@ func NewSQSClient(queue *string) (*SQSClient, error) {
 	}, nil
 }
 
-func (s *SQSClient) SendMsg(message interface{}) error {
+// SendMsg queues the message. The correlation id carried by ctx is sent as
+// the CorrelationId message attribute, so the worker logs with the same id.
+func (s *SQSClient) SendMsg(ctx context.Context, message interface{}) error {
 	svc := sqs.New(s.Session)
 
 	body, err := json.Marshal(message)
 	if err != nil {
-		fmt.Println("Got an error marshalling the message:")
-		fmt.Println(err)
+		slog.ErrorContext(ctx, "Got an error marshalling the message", "error", err)
 		return err
 	}
 
 	hashBytes := sha1.Sum(body)
 	hash := fmt.Sprintf("%x", hashBytes)
 
-	fmt.Println("HASH: ", hash)
+	slog.DebugContext(ctx, "Message hash", "hash", hash)
 	messageSize := len(body)
 
-	_, err = svc.SendMessage(&sqs.SendMessageInput{
-		MessageAttributes: map[string]*sqs.MessageAttributeValue{
-			"MessageSize": {
-				DataType:    aws.String("Number"),
-				StringValue: aws.String(strconv.Itoa(messageSize)),
-			},
+	attributes := map[string]*sqs.MessageAttributeValue{
+		"MessageSize": {
+			DataType:    aws.String("Number"),
+			StringValue: aws.String(strconv.Itoa(messageSize)),
 		},
+	}
+	if id := logger.CorrelationId(ctx); id != "" {
+		attributes[consts.CorrelationIdAttribute] = &sqs.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(id),
+		}
+	}
+
+	_, err = svc.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		MessageAttributes:      attributes,
 		MessageBody:            aws.String(string(body)),
 		QueueUrl:               s.QueueURL,
 		MessageGroupId:         aws.String("messages"),
@@ -67,15 +79,30 @@ func (s *SQSClient) SendMsg(message interface{}) error {
 	})
 
 	if err != nil {
-		fmt.Println("Got an error sending the message:")
-		fmt.Println(err)
+		slog.ErrorContext(ctx, "Got an error sending the message", "error", err)
 		return err
 	}
 
-	fmt.Println("Sent message to queue ")
+	slog.InfoContext(ctx, "Sent message to queue")
 	return nil
 }
 
+// QueueDepth returns the approximate number of messages waiting in the queue.
+func (s *SQSClient) QueueDepth(ctx context.Context) (int64, error) {
+	svc := sqs.New(s.Session)
+
+	result, err := svc.GetQueueAttributesWithContext(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       s.QueueURL,
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameApproximateNumberOfMessages)},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	value := aws.StringValue(result.Attributes[sqs.QueueAttributeNameApproximateNumberOfMessages])
+	return strconv.ParseInt(value, 10, 64)
+}
+
 func GetQueueURL(sess *session.Session, queue *string) (*sqs.GetQueueUrlOutput, error) {
 	svc := sqs.New(sess)
 
@@ -14,6 +14,20 @@ func Deploy(ctx *pulumi.Context) error {
 		return err
 	}
 
+	fmt.Println("Creating bucket...")
+	err = CreateImageBucket(ctx)
+	if err != nil {
+		fmt.Printf("Can't create bucket: %v\n", err)
+		return err
+	}
+
+	fmt.Println("Creating image distribution...")
+	err = CreateImageDistribution(ctx)
+	if err != nil {
+		fmt.Printf("Can't create image distribution: %v\n", err)
+		return err
+	}
+
 	fmt.Println("Creating policies...")
 	err = CreateLambdaRolePolicy(ctx)
 	if err != nil {
@@ -49,5 +63,12 @@ func Deploy(ctx *pulumi.Context) error {
 		return err
 	}
 
+	fmt.Println("Creating scheduler rule...")
+	err = CreateSchedulerRule(ctx)
+	if err != nil {
+		fmt.Printf("Can't create scheduler rule: %v\n", err)
+		return err
+	}
+
 	return nil
 }
@@ -6,10 +6,12 @@ import (
 
 	"github.com/marlosl/gpt-telegram-bot/consts"
 
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/cloudfront"
 	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/cloudwatch"
 	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/dynamodb"
 	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/iam"
 	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/lambda"
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/s3"
 	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/sqs"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
@@ -19,10 +21,14 @@ var (
 	IamPolicyLambdaExecution         *iam.RolePolicy
 	ChatGPTTalkHandlerLambdaFunction *lambda.Function
 	SendImageHandlerLambdaFunction   *lambda.Function
+	SchedulerHandlerLambdaFunction   *lambda.Function
 	ChatGPTHandlerLogGroup           *cloudwatch.LogGroup
 	SendImageHandlerLogGroup         *cloudwatch.LogGroup
+	SchedulerHandlerLogGroup         *cloudwatch.LogGroup
 	CacheDynamoDbTable               *dynamodb.Table
 	SQSSendImageQueue                *sqs.Queue
+	ImageS3Bucket                    *s3.Bucket
+	ImageDistribution                *cloudfront.Distribution
 )
 
 func CreateLambdaRolePolicy(ctx *pulumi.Context) error {
@@ -46,7 +52,7 @@ func CreateLambdaRolePolicy(ctx *pulumi.Context) error {
 
 	lambdaPolicy, err := iam.NewRolePolicy(ctx, "ChatGPTIamPolicyLambdaExecution", &iam.RolePolicyArgs{
 		Role: role.Name,
-		Policy: pulumi.String(`{
+		Policy: pulumi.Sprintf(`{
 							"Version": "2012-10-17",
 							"Statement": [{
 									"Effect": "Allow",
@@ -84,8 +90,19 @@ func CreateLambdaRolePolicy(ctx *pulumi.Context) error {
 										"sqs:*"
 								],
 								"Resource": "arn:aws:sqs:*:*:*"
+							},
+							{
+								"Effect": "Allow",
+								"Action": [
+										"s3:PutObject",
+										"s3:GetObject"
+								],
+								"Resource": [
+										"%s",
+										"%s/*"
+								]
 							}]
-					}`),
+					}`, ImageS3Bucket.Arn, ImageS3Bucket.Arn),
 	})
 
 	if err != nil {
@@ -97,6 +114,14 @@ func CreateLambdaRolePolicy(ctx *pulumi.Context) error {
 
 func CreateLambdaFunctions(ctx *pulumi.Context) error {
 	outputDir := os.Getenv(consts.ProjectOutputDir)
+
+	// Piper answers with WAV, which is encoded to OGG/Opus voice notes with
+	// ffmpeg. Lambda has no ffmpeg, so it comes from a layer when set.
+	layers := pulumi.StringArray{}
+	if ffmpegLayer := os.Getenv(consts.FfmpegLayerArn); ffmpegLayer != "" {
+		layers = append(layers, pulumi.String(ffmpegLayer))
+	}
+
 	chatGPTTalkFile := filepath.Join(outputDir, "chatgpttalk/chat-gpt-talk-handler.zip")
 	chatGPTTalkHandlerLambdaFunction, err := lambda.NewFunction(ctx, "ChatGPTTalkHandlerLambdaFunction", &lambda.FunctionArgs{
 		Handler:    pulumi.String("main"),
@@ -105,16 +130,21 @@ func CreateLambdaFunctions(ctx *pulumi.Context) error {
 		Name:       pulumi.String("chat-gpt-talk-handler"),
 		MemorySize: pulumi.Int(128),
 		Code:       pulumi.NewFileArchive(chatGPTTalkFile),
+		Layers:     layers,
 		Timeout:    pulumi.Int(300),
 		Publish:    pulumi.Bool(true),
 		Environment: &lambda.FunctionEnvironmentArgs{
 			Variables: pulumi.StringMap{
-				"REGION":           pulumi.String(os.Getenv(consts.AwsRegion)),
-				"CACHE_TABLE":      CacheDynamoDbTable.Name,
-				"SEND_IMAGE_QUEUE": pulumi.String("chat-gpt-send-image.fifo"),
+				"REGION":                  pulumi.String(os.Getenv(consts.AwsRegion)),
+				"CACHE_TABLE":             CacheDynamoDbTable.Name,
+				"SEND_IMAGE_QUEUE":        pulumi.String("chat-gpt-send-image.fifo"),
+				"IMAGE_BUCKET":            ImageS3Bucket.Bucket,
+				"IMAGE_BUCKET_PUBLIC_URL": pulumi.Sprintf("https://%s", ImageDistribution.DomainName),
+				"LOG_LEVEL":               pulumi.String(os.Getenv(consts.LogLevel)),
+				"LOG_REDACT_CONTENT":      pulumi.String(os.Getenv(consts.LogRedactContent)),
 			},
 		}},
-		pulumi.DependsOn([]pulumi.Resource{IamPolicyLambdaExecution, ChatGPTHandlerLogGroup, CacheDynamoDbTable}),
+		pulumi.DependsOn([]pulumi.Resource{IamPolicyLambdaExecution, ChatGPTHandlerLogGroup, CacheDynamoDbTable, ImageS3Bucket, ImageDistribution}),
 	)
 	if err != nil {
 		return err
@@ -133,7 +163,9 @@ func CreateLambdaFunctions(ctx *pulumi.Context) error {
 		Publish:    pulumi.Bool(true),
 		Environment: &lambda.FunctionEnvironmentArgs{
 			Variables: pulumi.StringMap{
-				"SEND_IMAGE_QUEUE": pulumi.String("chat-gpt-send-image.fifo"),
+				"SEND_IMAGE_QUEUE":   pulumi.String("chat-gpt-send-image.fifo"),
+				"LOG_LEVEL":          pulumi.String(os.Getenv(consts.LogLevel)),
+				"LOG_REDACT_CONTENT": pulumi.String(os.Getenv(consts.LogRedactContent)),
 			},
 		}},
 		pulumi.DependsOn([]pulumi.Resource{IamPolicyLambdaExecution, SendImageHandlerLogGroup}),
@@ -143,6 +175,34 @@ func CreateLambdaFunctions(ctx *pulumi.Context) error {
 	}
 	SendImageHandlerLambdaFunction = sendImageHandlerLambdaFunction
 
+	schedulerHandlerFile := filepath.Join(outputDir, "chatgptscheduler/chat-gpt-scheduler-handler.zip")
+	schedulerHandlerLambdaFunction, err := lambda.NewFunction(ctx, "SchedulerHandlerLambdaFunction", &lambda.FunctionArgs{
+		Handler:    pulumi.String("main"),
+		Role:       IamRoleLambdaExecution,
+		Runtime:    pulumi.String("go1.x"),
+		Name:       pulumi.String("chat-gpt-scheduler-handler"),
+		MemorySize: pulumi.Int(128),
+		Code:       pulumi.NewFileArchive(schedulerHandlerFile),
+		Timeout:    pulumi.Int(300),
+		Publish:    pulumi.Bool(true),
+		Environment: &lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap{
+				"REGION":                  pulumi.String(os.Getenv(consts.AwsRegion)),
+				"CACHE_TABLE":             CacheDynamoDbTable.Name,
+				"SEND_IMAGE_QUEUE":        pulumi.String("chat-gpt-send-image.fifo"),
+				"IMAGE_BUCKET":            ImageS3Bucket.Bucket,
+				"IMAGE_BUCKET_PUBLIC_URL": pulumi.Sprintf("https://%s", ImageDistribution.DomainName),
+				"LOG_LEVEL":               pulumi.String(os.Getenv(consts.LogLevel)),
+				"LOG_REDACT_CONTENT":      pulumi.String(os.Getenv(consts.LogRedactContent)),
+			},
+		}},
+		pulumi.DependsOn([]pulumi.Resource{IamPolicyLambdaExecution, SchedulerHandlerLogGroup, CacheDynamoDbTable, ImageS3Bucket, ImageDistribution}),
+	)
+	if err != nil {
+		return err
+	}
+	SchedulerHandlerLambdaFunction = schedulerHandlerLambdaFunction
+
 	url, err := lambda.NewFunctionUrl(ctx, "lambdaURL", &lambda.FunctionUrlArgs{
 		FunctionName:      chatGPTTalkHandlerLambdaFunction.Name,
 		AuthorizationType: pulumi.String("NONE"),
@@ -185,11 +245,19 @@ func CreateLogGroups(ctx *pulumi.Context) error {
 		Name: pulumi.String("/aws/lambda/chat-gpt-send-image-handler"),
 	})
 
+	if err != nil {
+		return err
+	}
+	schedulerHandlerLogGroup, err := cloudwatch.NewLogGroup(ctx, "SchedulerHandlerLogGroup", &cloudwatch.LogGroupArgs{
+		Name: pulumi.String("/aws/lambda/chat-gpt-scheduler-handler"),
+	})
+
 	if err != nil {
 		return err
 	}
 	ChatGPTHandlerLogGroup = chatGPTHandlerLogGroup
 	SendImageHandlerLogGroup = sendImageHandlerLogGroup
+	SchedulerHandlerLogGroup = schedulerHandlerLogGroup
 	return nil
 }
 
@@ -204,11 +272,31 @@ func CreateDynamoDbTable(ctx *pulumi.Context) error {
 				Name: pulumi.String("SK"),
 				Type: pulumi.String("S"),
 			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("DuePK"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("NextRun"),
+				Type: pulumi.String("N"),
+			},
+		},
+		GlobalSecondaryIndexes: dynamodb.TableGlobalSecondaryIndexArray{
+			&dynamodb.TableGlobalSecondaryIndexArgs{
+				Name:           pulumi.String("ScheduleDueIndex"),
+				HashKey:        pulumi.String("DuePK"),
+				RangeKey:       pulumi.String("NextRun"),
+				ProjectionType: pulumi.String("ALL"),
+			},
 		},
 		BillingMode: pulumi.String("PAY_PER_REQUEST"),
 		HashKey:     pulumi.String("PK"),
 		RangeKey:    pulumi.String("SK"),
 		Name:        pulumi.String("gpt-cache"),
+		Ttl: &dynamodb.TableTtlArgs{
+			AttributeName: pulumi.String("ExpiresAt"),
+			Enabled:       pulumi.Bool(true),
+		},
 	})
 	if err != nil {
 		return err
@@ -218,6 +306,93 @@ func CreateDynamoDbTable(ctx *pulumi.Context) error {
 	return nil
 }
 
+func CreateImageBucket(ctx *pulumi.Context) error {
+	imageBucket, err := s3.NewBucket(ctx, "ImageS3Bucket", &s3.BucketArgs{
+		BucketPrefix: pulumi.String("chat-gpt-images-"),
+	})
+	if err != nil {
+		return err
+	}
+
+	ImageS3Bucket = imageBucket
+	return nil
+}
+
+// CreateImageDistribution serves the image bucket through CloudFront, so
+// the image links sent to the chats do not expire like presigned URLs. The
+// bucket stays private and only the distribution can read it.
+func CreateImageDistribution(ctx *pulumi.Context) error {
+	accessControl, err := cloudfront.NewOriginAccessControl(ctx, "ImageOriginAccessControl", &cloudfront.OriginAccessControlArgs{
+		Name:                          pulumi.String("chat-gpt-images"),
+		OriginAccessControlOriginType: pulumi.String("s3"),
+		SigningBehavior:               pulumi.String("always"),
+		SigningProtocol:               pulumi.String("sigv4"),
+	})
+	if err != nil {
+		return err
+	}
+
+	distribution, err := cloudfront.NewDistribution(ctx, "ImageDistribution", &cloudfront.DistributionArgs{
+		Enabled: pulumi.Bool(true),
+		Origins: cloudfront.DistributionOriginArray{
+			&cloudfront.DistributionOriginArgs{
+				DomainName:            ImageS3Bucket.BucketRegionalDomainName,
+				OriginId:              pulumi.String("ImageS3Bucket"),
+				OriginAccessControlId: accessControl.ID(),
+			},
+		},
+		DefaultCacheBehavior: &cloudfront.DistributionDefaultCacheBehaviorArgs{
+			TargetOriginId:       pulumi.String("ImageS3Bucket"),
+			ViewerProtocolPolicy: pulumi.String("redirect-to-https"),
+			AllowedMethods:       pulumi.StringArray{pulumi.String("GET"), pulumi.String("HEAD")},
+			CachedMethods:        pulumi.StringArray{pulumi.String("GET"), pulumi.String("HEAD")},
+			ForwardedValues: &cloudfront.DistributionDefaultCacheBehaviorForwardedValuesArgs{
+				QueryString: pulumi.Bool(false),
+				Cookies: &cloudfront.DistributionDefaultCacheBehaviorForwardedValuesCookiesArgs{
+					Forward: pulumi.String("none"),
+				},
+			},
+		},
+		Restrictions: &cloudfront.DistributionRestrictionsArgs{
+			GeoRestriction: &cloudfront.DistributionRestrictionsGeoRestrictionArgs{
+				RestrictionType: pulumi.String("none"),
+			},
+		},
+		ViewerCertificate: &cloudfront.DistributionViewerCertificateArgs{
+			CloudfrontDefaultCertificate: pulumi.Bool(true),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s3.NewBucketPolicy(ctx, "ImageS3BucketPolicy", &s3.BucketPolicyArgs{
+		Bucket: ImageS3Bucket.ID(),
+		Policy: pulumi.Sprintf(`{
+			"Version": "2012-10-17",
+			"Statement": [{
+				"Effect": "Allow",
+				"Principal": {
+					"Service": "cloudfront.amazonaws.com"
+				},
+				"Action": "s3:GetObject",
+				"Resource": "%s/*",
+				"Condition": {
+					"StringEquals": {
+						"AWS:SourceArn": "%s"
+					}
+				}
+			}]
+		}`, ImageS3Bucket.Arn, distribution.Arn),
+	})
+	if err != nil {
+		return err
+	}
+
+	ImageDistribution = distribution
+	return nil
+}
+
 func CreateSendImageQueue(ctx *pulumi.Context) error {
 	sqsSendImageQueue, err := sqs.NewQueue(ctx, "SQSSendImageQueue", &sqs.QueueArgs{
 		Name:                     pulumi.String("chat-gpt-send-image.fifo"),
@@ -247,3 +422,35 @@ func CreateLambdaEventSourceMapping(ctx *pulumi.Context) error {
 	}
 	return nil
 }
+
+// CreateSchedulerRule invokes the scheduler lambda every minute.
+func CreateSchedulerRule(ctx *pulumi.Context) error {
+	rule, err := cloudwatch.NewEventRule(ctx, "SchedulerEventRule", &cloudwatch.EventRuleArgs{
+		Name:               pulumi.String("chat-gpt-scheduler"),
+		ScheduleExpression: pulumi.String("rate(1 minute)"),
+	})
+	if err != nil {
+		return err
+	}
+
+	permission, err := lambda.NewPermission(ctx, "SchedulerEventRulePermission", &lambda.PermissionArgs{
+		Action:    pulumi.String("lambda:InvokeFunction"),
+		Function:  SchedulerHandlerLambdaFunction.Name,
+		Principal: pulumi.String("events.amazonaws.com"),
+		SourceArn: rule.Arn,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = cloudwatch.NewEventTarget(ctx, "SchedulerEventTarget", &cloudwatch.EventTargetArgs{
+		Rule: rule.Name,
+		Arn:  SchedulerHandlerLambdaFunction.Arn,
+	},
+		pulumi.DependsOn([]pulumi.Resource{permission}),
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
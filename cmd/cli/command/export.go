@@ -0,0 +1,114 @@
+package command
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/clients/s3"
+	"github.com/marlosl/gpt-telegram-bot/consts"
+	"github.com/marlosl/gpt-telegram-bot/services/export"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat        string
+	exportOutput        string
+	exportUserId        string
+	exportThreadId      string
+	exportRedactSecrets bool
+	exportRedactContent bool
+	exportRedactNames   bool
+
+	exportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Export the conversation of a chat.",
+		Long: "Export the stored conversation of a chat by its id as Markdown, JSON or HTML.\n" +
+			"The images the user created in the chat are included as links when IMAGE_BUCKET is set.",
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				fmt.Println("Please provide a chat id.")
+				cmd.Help()
+				return
+			}
+			chatId := args[0]
+
+			format, err := export.ParseFormat(exportFormat)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+
+			history, err := db.NewHistoryRepository()
+			if err != nil {
+				fmt.Printf("Can't open the history: %v\n", err)
+				return
+			}
+			gallery, err := db.NewGalleryRepository()
+			if err != nil {
+				fmt.Printf("Can't open the gallery: %v\n", err)
+				return
+			}
+
+			var storage *s3.S3Client
+			if bucket := os.Getenv(consts.ImageBucket); bucket != "" {
+				storage, err = s3.NewS3Client(bucket)
+				if err != nil {
+					fmt.Printf("Can't open the image bucket: %v\n", err)
+					return
+				}
+			}
+
+			userId := exportUserId
+			if userId == "" {
+				userId = chatId
+			}
+
+			conversation, err := export.Load(history, gallery, storage, chatId, exportThreadId, userId)
+			if err != nil {
+				fmt.Printf("Can't load the conversation: %v\n", err)
+				return
+			}
+
+			conversation = export.Redact(conversation, export.Options{
+				Secrets: exportRedactSecrets,
+				Content: exportRedactContent,
+				Names:   exportRedactNames,
+			})
+			content, err := export.Render(conversation, format)
+			if err != nil {
+				fmt.Printf("Can't render the conversation: %v\n", err)
+				return
+			}
+
+			output := exportOutput
+			if output == "" {
+				output = export.Filename(conversation, format)
+			}
+			if output == "-" {
+				os.Stdout.Write(content)
+				return
+			}
+
+			err = os.WriteFile(output, content, 0644)
+			if err != nil {
+				fmt.Printf("Can't write %s: %v\n", output, err)
+				return
+			}
+			fmt.Printf("Exported %d entries to %s.\n", len(conversation.Entries), output)
+		},
+	}
+)
+
+func init() {
+	exportCmd.Flags().StringVar(&exportFormat, "format", "md", "md, json or html")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "output file, - for the standard output")
+	exportCmd.Flags().StringVar(&exportThreadId, "thread", "", "thread to export, every thread of the chat by default")
+	exportCmd.Flags().StringVar(&exportUserId, "user", "", "user whose images are included, the chat id by default")
+	exportCmd.Flags().BoolVar(&exportRedactSecrets, "redact-secrets", true, "replace tokens and keys in the messages")
+	exportCmd.Flags().BoolVar(&exportRedactContent, "redact-content", false, "replace the text of every message")
+	exportCmd.Flags().BoolVar(&exportRedactNames, "redact-names", false, "replace the names of the participants")
+
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,93 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/marlosl/gpt-telegram-bot/services/embeddings"
+	"github.com/marlosl/gpt-telegram-bot/services/knowledge"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	knowledgeQuery string
+
+	knowledgeCmd = &cobra.Command{
+		Use:   "kb",
+		Short: "Manage the knowledge base.",
+	}
+
+	ingestKnowledgeCmd = &cobra.Command{
+		Use:   "ingest",
+		Short: "Ingest a directory of Markdown and text files.",
+		Long: "Ingest a directory of Markdown and text files into the knowledge base.\n" +
+			"Files ingested before are replaced. Without CACHE_TABLE the knowledge base is kept in memory, use --query to try it.",
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				fmt.Println("Please provide a directory.")
+				cmd.Help()
+				return
+			}
+
+			store, err := knowledge.NewStore()
+			if err != nil {
+				fmt.Printf("Can't open the knowledge base: %v\n", err)
+				return
+			}
+
+			embedder := embeddings.NewEmbedder()
+			summary, err := knowledge.Ingest(context.Background(), embedder, store, args[0])
+			if err != nil {
+				fmt.Printf("Can't ingest %s: %v\n", args[0], err)
+				return
+			}
+			fmt.Printf("Ingested %d chunks from %d files.\n", summary.Chunks, summary.Files)
+
+			if knowledgeQuery != "" {
+				searchKnowledge(embedder, store, knowledgeQuery)
+			}
+		},
+	}
+
+	searchKnowledgeCmd = &cobra.Command{
+		Use:   "search",
+		Short: "Search the knowledge base.",
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				fmt.Println("Please provide a question.")
+				cmd.Help()
+				return
+			}
+
+			store, err := knowledge.NewStore()
+			if err != nil {
+				fmt.Printf("Can't open the knowledge base: %v\n", err)
+				return
+			}
+			searchKnowledge(embeddings.NewEmbedder(), store, strings.Join(args, " "))
+		},
+	}
+)
+
+func searchKnowledge(embedder embeddings.Embedder, store knowledge.Store, question string) {
+	matches, err := knowledge.Retrieve(context.Background(), embedder, store, question, 5)
+	if err != nil {
+		fmt.Printf("Can't search the knowledge base: %v\n", err)
+		return
+	}
+
+	for _, match := range matches {
+		fmt.Printf("%.3f %s#%d\n", match.Score, match.Source, match.Index)
+	}
+}
+
+func init() {
+	ingestKnowledgeCmd.Flags().StringVar(&knowledgeQuery, "query", "", "search the knowledge base after ingesting")
+
+	knowledgeCmd.AddCommand(ingestKnowledgeCmd)
+	knowledgeCmd.AddCommand(searchKnowledgeCmd)
+
+	rootCmd.AddCommand(knowledgeCmd)
+}
@@ -0,0 +1,126 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/services/prompts"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	promptsUserId    string
+	promptsChatId    string
+	promptsOutput    string
+	promptsOverwrite bool
+
+	promptsCmd = &cobra.Command{
+		Use:   "prompts",
+		Short: "Manage the saved prompts.",
+	}
+
+	exportPromptsCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Export saved prompts as YAML.",
+		Long: "Export the personal prompts of a user and the team prompts of a chat as YAML.\n" +
+			"Use --user, --chat or both to choose the prompts.",
+		Run: func(cmd *cobra.Command, args []string) {
+			if promptsUserId == "" && promptsChatId == "" {
+				fmt.Println("Please provide a user or a chat id.")
+				cmd.Help()
+				return
+			}
+
+			repository, err := db.NewPromptRepository()
+			if err != nil {
+				fmt.Printf("Can't open the prompts: %v\n", err)
+				return
+			}
+
+			library, err := prompts.Export(repository, promptsUserId, promptsChatId)
+			if err != nil {
+				fmt.Printf("Can't load the prompts: %v\n", err)
+				return
+			}
+			content, err := library.Marshal()
+			if err != nil {
+				fmt.Printf("Can't render the prompts: %v\n", err)
+				return
+			}
+
+			if promptsOutput == "" || promptsOutput == "-" {
+				os.Stdout.Write(content)
+				return
+			}
+			err = os.WriteFile(promptsOutput, content, 0644)
+			if err != nil {
+				fmt.Printf("Can't write %s: %v\n", promptsOutput, err)
+				return
+			}
+			fmt.Printf("Exported %d prompts to %s.\n", len(library.Prompts), promptsOutput)
+		},
+	}
+
+	importPromptsCmd = &cobra.Command{
+		Use:   "import",
+		Short: "Import saved prompts from YAML.",
+		Long: "Import the prompts of a YAML file exported before, or written by hand:\n\n" +
+			"prompts:\n" +
+			"  - name: review-sql\n" +
+			"    scope: team\n" +
+			"    owner: \"-1001234567890\"\n" +
+			"    template: Review this SQL query and point out problems: {{input}}\n\n" +
+			"--user and --chat replace the owners of the personal and team prompts.",
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				fmt.Println("Please provide a YAML file.")
+				cmd.Help()
+				return
+			}
+
+			content, err := os.ReadFile(args[0])
+			if err != nil {
+				fmt.Printf("Can't read %s: %v\n", args[0], err)
+				return
+			}
+			library, err := prompts.ParseLibrary(content)
+			if err != nil {
+				fmt.Printf("Can't parse %s: %v\n", args[0], err)
+				return
+			}
+
+			repository, err := db.NewPromptRepository()
+			if err != nil {
+				fmt.Printf("Can't open the prompts: %v\n", err)
+				return
+			}
+
+			summary, err := prompts.Import(repository, library, promptsUserId, promptsChatId, promptsOverwrite)
+			if err != nil {
+				fmt.Printf("Can't import the prompts: %v\n", err)
+			}
+			fmt.Printf("Imported %d prompts.\n", summary.Saved)
+			if len(summary.Skipped) > 0 {
+				fmt.Printf("Skipped the existing prompts %s, use --overwrite to replace them.\n", strings.Join(summary.Skipped, ", "))
+			}
+		},
+	}
+)
+
+func init() {
+	exportPromptsCmd.Flags().StringVar(&promptsUserId, "user", "", "user whose personal prompts are exported")
+	exportPromptsCmd.Flags().StringVar(&promptsChatId, "chat", "", "chat whose team prompts are exported")
+	exportPromptsCmd.Flags().StringVarP(&promptsOutput, "output", "o", "", "output file, the standard output by default")
+
+	importPromptsCmd.Flags().StringVar(&promptsUserId, "user", "", "owner of the personal prompts")
+	importPromptsCmd.Flags().StringVar(&promptsChatId, "chat", "", "owner of the team prompts")
+	importPromptsCmd.Flags().BoolVar(&promptsOverwrite, "overwrite", false, "replace the existing prompts")
+
+	promptsCmd.AddCommand(exportPromptsCmd)
+	promptsCmd.AddCommand(importPromptsCmd)
+
+	rootCmd.AddCommand(promptsCmd)
+}
@@ -1,9 +1,11 @@
 package command
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+	"github.com/marlosl/gpt-telegram-bot/utils"
 
 	"github.com/spf13/cobra"
 )
@@ -25,7 +27,7 @@ var (
 			}
 
 			service := telegram.NewTextService()
-			service.SetWebhook(args[0], args[1])
+			setWebhook(service, args)
 		},
 	}
 
@@ -39,14 +41,49 @@ var (
 				return
 			}
 			service := telegram.NewImageService()
-			service.SetWebhook(args[0], args[1])
+			setWebhook(service, args)
+		},
+	}
+
+	webhookInfoCmd = &cobra.Command{
+		Use:       "info",
+		Short:     "Show Telegram Webhook info.",
+		Long:      "Show Telegram Webhook info.\nValid options are: text, image.",
+		ValidArgs: []string{"text", "image"},
+		Run: func(cmd *cobra.Command, args []string) {
+			service := telegram.NewTextService()
+			if len(args) > 0 && args[0] == "image" {
+				service = telegram.NewImageService()
+			}
+
+			info, err := service.GetWebhookInfo(context.Background())
+			if err != nil {
+				fmt.Printf("Can't get webhook info: %v\n", err)
+				return
+			}
+			fmt.Println(utils.SPrintJson(info))
 		},
 	}
 )
 
+func setWebhook(service *telegram.Telegram, args []string) {
+	token := ""
+	if len(args) > 1 {
+		token = args[1]
+	}
+
+	err := service.SetWebhook(context.Background(), args[0], token)
+	if err != nil {
+		fmt.Printf("Can't set webhook: %v\n", err)
+		return
+	}
+	fmt.Println("Webhook set.")
+}
+
 func init() {
 	telegramWebhookCmd.AddCommand(setTextWebhookCmd)
 	telegramWebhookCmd.AddCommand(setImageWebhookCmd)
+	telegramWebhookCmd.AddCommand(webhookInfoCmd)
 
 	rootCmd.AddCommand(telegramWebhookCmd)
 }
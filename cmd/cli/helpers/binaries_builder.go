@@ -27,6 +27,11 @@ var Binaries = []BinaryFile{
 		Filename:     "chat-gpt-send-image-handler",
 		HandlerDir:   "chatgptsendimage",
 	},
+	{
+		FunctionName: "chat-gpt-scheduler",
+		Filename:     "chat-gpt-scheduler-handler",
+		HandlerDir:   "chatgptscheduler",
+	},
 }
 
 func GetFunctionNames() []string {
@@ -22,13 +22,44 @@ const (
 	CloudfareApiToken = "CLOUDFLARE_API_TOKEN"
 	DnsZone           = "DNS_ZONE"
 	DnsRecord         = "DNS_RECORD"
+	FfmpegLayerArn    = "FFMPEG_LAYER_ARN"
 
 	CloudfareApiKey            = "CLOUDFLARE_API_KEY"
 	CloudfareApiEmail          = "CLOUDFLARE_API_EMAIL"
 	GptApiKey                  = "GPT_API_KEY"
+	GptVisionModel             = "GPT_VISION_MODEL"
+	ImageModel                 = "IMAGE_MODEL"
 	TelegramBotTextToken       = "TELEGRAM_BOT_TEXT_TOKEN"
 	TelegramBotImageToken      = "TELEGRAM_BOT_IMAGE_TOKEN"
 	CacheTable                 = "CACHE_TABLE"
 	SendImageQueue             = "SEND_IMAGE_QUEUE"
+	ImageBucket                = "IMAGE_BUCKET"
+	ImageBucketEndpoint        = "IMAGE_BUCKET_ENDPOINT"
+	ImageBucketPublicUrl       = "IMAGE_BUCKET_PUBLIC_URL"
 	TelegramWebhookTokenHeader = "x-telegram-bot-api-secret-token"
+
+	TranscriptionProvider = "TRANSCRIPTION_PROVIDER"
+	TranscriptionUrl      = "TRANSCRIPTION_URL"
+	MaxVoiceDuration      = "MAX_VOICE_DURATION"
+	TtsProvider           = "TTS_PROVIDER"
+	TtsUrl                = "TTS_URL"
+	TtsVoice              = "TTS_VOICE"
+	EmbeddingsProvider    = "EMBEDDINGS_PROVIDER"
+	EmbeddingsUrl         = "EMBEDDINGS_URL"
+	EmbeddingsModel       = "EMBEDDINGS_MODEL"
+	ModerationProvider    = "MODERATION_PROVIDER"
+	ModerationUrl         = "MODERATION_URL"
+	ModerationPolicy      = "MODERATION_POLICY"
+	ModerationKeywords    = "MODERATION_KEYWORDS"
+	ModerationStages      = "MODERATION_STAGES"
+	ModerationRefusal     = "MODERATION_REFUSAL"
+
+	LogLevel         = "LOG_LEVEL"
+	LogRedactContent = "LOG_REDACT_CONTENT"
+
+	OtelExporterEndpoint = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	MetricsNamespace     = "METRICS_NAMESPACE"
+
+	CorrelationIdHeader    = "X-Client-Request-Id"
+	CorrelationIdAttribute = "CorrelationId"
 )
@@ -7,4 +7,21 @@ var (
 	PARAMETER_TELEGRAM_WEBHOOK_TOKEN   = "/gpt-talk/token/telegram-webhook"
 	PARAMETER_SEND_IMAGE_BY_URL        = "/gpt-talk/send-image-by-url"
 	PARAMETER_GPT_MODEL                = "/gpt-talk/gpt-model"
+	PARAMETER_GPT_VISION_MODEL         = "/gpt-talk/gpt-vision-model"
+	PARAMETER_IMAGE_MODEL              = "/gpt-talk/image-model"
+	PARAMETER_TRANSCRIPTION_PROVIDER   = "/gpt-talk/transcription/provider"
+	PARAMETER_TRANSCRIPTION_URL        = "/gpt-talk/transcription/url"
+	PARAMETER_MAX_VOICE_DURATION       = "/gpt-talk/transcription/max-voice-duration"
+	PARAMETER_TTS_PROVIDER             = "/gpt-talk/tts/provider"
+	PARAMETER_TTS_URL                  = "/gpt-talk/tts/url"
+	PARAMETER_TTS_VOICE                = "/gpt-talk/tts/voice"
+	PARAMETER_EMBEDDINGS_PROVIDER      = "/gpt-talk/embeddings/provider"
+	PARAMETER_EMBEDDINGS_URL           = "/gpt-talk/embeddings/url"
+	PARAMETER_EMBEDDINGS_MODEL         = "/gpt-talk/embeddings/model"
+	PARAMETER_MODERATION_PROVIDER      = "/gpt-talk/moderation/provider"
+	PARAMETER_MODERATION_URL           = "/gpt-talk/moderation/url"
+	PARAMETER_MODERATION_POLICY        = "/gpt-talk/moderation/policy"
+	PARAMETER_MODERATION_KEYWORDS      = "/gpt-talk/moderation/keywords"
+	PARAMETER_MODERATION_STAGES        = "/gpt-talk/moderation/stages"
+	PARAMETER_MODERATION_REFUSAL       = "/gpt-talk/moderation/refusal"
 )
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/services/chatgpt"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	maxGeneratedImageSize = 50 * 1024 * 1024
+	imageDownloadTimeout  = time.Minute
+	galleryLimit          = 10
+)
+
+var imageDownloadClient = &http.Client{
+	Timeout: imageDownloadTimeout,
+}
+
+// archiveImages stores the generated images in the image bucket, records
+// them in the user's gallery and returns URLs that outlive the ones
+// returned by OpenAI. Without a bucket the original URLs are returned.
+func archiveImages(ctx context.Context, response *chatgpt.CreateImageResponse, userId string, chatId string, prompt string) ([]string, error) {
+	var urls []string
+
+	if imageStorage == nil {
+		for _, photo := range response.Data {
+			if photo.Url == "" {
+				return nil, errors.New("image storage is not configured")
+			}
+			urls = append(urls, photo.Url)
+		}
+		return urls, nil
+	}
+
+	created := response.Created
+	if created == 0 {
+		created = time.Now().Unix()
+	}
+
+	for i, photo := range response.Data {
+		content, err := readGeneratedImage(ctx, photo)
+		if err != nil {
+			return nil, err
+		}
+
+		contentType := http.DetectContentType(content)
+		key := fmt.Sprintf("images/%s/%d-%d%s", userId, created, i, imageExtension(contentType))
+
+		err = imageStorage.PutObject(key, bytes.NewReader(content), contentType, map[string]string{
+			"prompt": url.QueryEscape(prompt),
+			"user":   userId,
+			"chat":   chatId,
+			"model":  response.Model,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if galleryRepository != nil {
+			err = galleryRepository.SaveImage(&db.GalleryImage{
+				UserId:    userId,
+				ChatId:    chatId,
+				Key:       key,
+				Prompt:    prompt,
+				Model:     response.Model,
+				CreatedAt: created,
+			})
+			if err != nil {
+				slog.ErrorContext(ctx, "Error saving image to gallery", "error", err)
+			}
+		}
+
+		imageUrl, err := imageStorage.GetUrl(key)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, imageUrl)
+	}
+	return urls, nil
+}
+
+// readGeneratedImage returns the bytes of the image, downloading it from
+// OpenAI when the response has its URL.
+func readGeneratedImage(ctx context.Context, photo chatgpt.UrlReponse) ([]byte, error) {
+	if photo.B64Json != "" {
+		return base64.StdEncoding.DecodeString(photo.B64Json)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, photo.Url, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := imageDownloadClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image download failed with response code: %d", response.StatusCode)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(response.Body, maxGeneratedImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(content) > maxGeneratedImageSize {
+		return nil, fmt.Errorf("image is larger than %d bytes", maxGeneratedImageSize)
+	}
+	return content, nil
+}
+
+func imageExtension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/webp":
+		return ".webp"
+	}
+	return ".png"
+}
+
+func handleGalleryToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+
+	if imageStorage == nil || galleryRepository == nil || msg.Message.From == nil {
+		telegramService.SendMessage(ctx, "The gallery is not available", chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	images, err := galleryRepository.ListImages(fmt.Sprintf("%d", msg.Message.From.ID), galleryLimit)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing gallery", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	if len(images) == 0 {
+		telegramService.SendMessage(ctx, "You have no images yet, use /createimage to create one", chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	var lines []string
+	for i, image := range images {
+		imageUrl, err := imageStorage.GetUrl(image.Key)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error getting image url", "error", err)
+			continue
+		}
+
+		prompt := image.Prompt
+		if prompt == "" {
+			prompt = "(no prompt)"
+		}
+		lines = append(lines, fmt.Sprintf("%d. <a href=\"%s\">%s</a> - %s",
+			i+1,
+			html.EscapeString(imageUrl),
+			html.EscapeString(prompt),
+			time.Unix(image.CreatedAt, 0).UTC().Format("2006-01-02 15:04"),
+		))
+	}
+
+	telegramService.SendMessage(ctx, strings.Join(lines, "\n"), chatId, true)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
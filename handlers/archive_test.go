@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marlosl/gpt-telegram-bot/services/chatgpt"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadGeneratedImage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fox.png":
+			w.Write([]byte("png"))
+		case "/huge.png":
+			w.Write(bytes.Repeat([]byte{0}, maxGeneratedImageSize+1))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	content, err := readGeneratedImage(ctx, chatgpt.UrlReponse{B64Json: "cG5n"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("png"), content)
+
+	content, err = readGeneratedImage(ctx, chatgpt.UrlReponse{Url: server.URL + "/fox.png"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("png"), content)
+
+	_, err = readGeneratedImage(ctx, chatgpt.UrlReponse{Url: server.URL + "/huge.png"})
+	assert.EqualError(t, err, "image is larger than 52428800 bytes")
+
+	_, err = readGeneratedImage(ctx, chatgpt.UrlReponse{Url: server.URL + "/gone.png"})
+	assert.EqualError(t, err, "image download failed with response code: 404")
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/services/chatgpt"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	// DialogTimeout is how long a dialog waits for the next answer.
+	DialogTimeout        = 10 * time.Minute
+	dialogCallbackPrefix = "dialog:"
+
+	imagePromptState = "createimage.prompt"
+	imageSizeState   = "createimage.size"
+)
+
+// dialogState is a step of a multi-step dialog. Ask sends the question of
+// the step and Answer handles the reply, returning the next state, or an
+// empty one when the dialog is over. An error returned by Answer is sent to
+// the chat and the question is asked again, so states handle their own
+// failures when the dialog should end anyway.
+type dialogState struct {
+	Ask    func(params *telegram.MsgParams) error
+	Answer func(params *telegram.MsgParams) (string, error)
+}
+
+var dialogStates = map[string]dialogState{
+	imagePromptState: {
+		Ask:    askImagePrompt,
+		Answer: answerImagePrompt,
+	},
+	imageSizeState: {
+		Ask:    askImageSize,
+		Answer: answerImageSize,
+	},
+}
+
+// startDialog saves the chat status in the given state and asks its
+// question. The parameter is kept for the next states in LastParameter, and
+// the user who started the dialog is the only one answering it.
+func startDialog(ctx context.Context, msg *telegram.Message, state string, parameter string) error {
+	status := &telegram.ChatStatus{
+		ChatId:        msg.Chat.ID,
+		Status:        state,
+		LastParameter: parameter,
+		LastUpdate:    time.Now(),
+	}
+	if msg.From != nil {
+		status.UserId = msg.From.ID
+	}
+
+	err := saveChatStatus(status)
+	if err != nil {
+		return err
+	}
+	return dialogStates[state].Ask(dialogParams(ctx, msg, status, ""))
+}
+
+// continueDialog hands the answer to the state the chat is in, moving the
+// dialog to the next state. It reports false when the chat is not in a
+// dialog, or the message is not from the user in the dialog, so the answer
+// is handled as a regular message.
+func continueDialog(ctx context.Context, msg *telegram.Message, answer string) (bool, error) {
+	if statusRepository == nil {
+		return false, nil
+	}
+
+	chatId := fmt.Sprintf("%d", msg.Chat.ID)
+
+	var status telegram.ChatStatus
+	found, err := statusRepository.GetStatus(chatId, &status)
+	if err != nil || !found || !inDialog(&status, msg.From) {
+		return false, err
+	}
+
+	state, ok := dialogStates[status.Status]
+	if !ok {
+		slog.WarnContext(ctx, "Unknown dialog state, removing it", "status", status.Status)
+		return false, statusRepository.DeleteStatus(chatId)
+	}
+
+	params := dialogParams(ctx, msg, &status, answer)
+	next, err := state.Answer(params)
+	if err != nil {
+		telegramService.SendMessage(ctx, fmt.Sprintf("%v", err), chatId, false)
+		next = status.Status
+	}
+
+	if next == "" {
+		return true, statusRepository.DeleteStatus(chatId)
+	}
+
+	params.LastStatus = status.Status
+	status.Status = next
+	status.LastUpdate = time.Now()
+	err = saveChatStatus(&status)
+	if err != nil {
+		return true, err
+	}
+	return true, dialogStates[next].Ask(params)
+}
+
+// inDialog reports whether the user is the one who started the dialog.
+func inDialog(status *telegram.ChatStatus, from *telegram.From) bool {
+	if status.UserId == 0 {
+		return true
+	}
+	return from != nil && from.ID == status.UserId
+}
+
+func saveChatStatus(status *telegram.ChatStatus) error {
+	if statusRepository == nil {
+		return errors.New("statusRepository is not initialized")
+	}
+	return statusRepository.SaveStatus(fmt.Sprintf("%d", status.ChatId), status, status.LastUpdate.Add(DialogTimeout))
+}
+
+func dialogParams(ctx context.Context, msg *telegram.Message, status *telegram.ChatStatus, answer string) *telegram.MsgParams {
+	return &telegram.MsgParams{
+		Ctx:        ctx,
+		ChatId:     msg.Chat.ID,
+		ChatMsg:    msg.Text,
+		From:       msg.From,
+		FirstArg:   strings.TrimSpace(answer),
+		Status:     status,
+		LastStatus: status.Status,
+	}
+}
+
+// dialogButtons returns a keyboard answering the question of the current
+// state with one of the options.
+func dialogButtons(options []string) *telegram.InlineKeyboard {
+	var row []telegram.InlineKeyboardButton
+	for _, option := range options {
+		row = append(row, telegram.InlineKeyboardButton{
+			Text:         option,
+			CallbackData: dialogCallbackPrefix + option,
+		})
+	}
+	return &telegram.InlineKeyboard{
+		Buttons: [][]telegram.InlineKeyboardButton{row},
+	}
+}
+
+// handleCallbackQuery answers the buttons of dialog questions, replacing
+// the buttons with the chosen option, and the buttons of /history and
+// /prompts.
+func handleCallbackQuery(ctx context.Context, query *telegram.CallbackQuery) (events.APIGatewayProxyResponse, error) {
+	telegramService.SendTelegramCallbackQueryResponse(ctx, query.ID)
+
+	if query.Message != nil && query.Message.Chat != nil && threadRepository != nil && strings.HasPrefix(query.Data, threadCallbackPrefix) {
+		handleThreadCallbackQuery(ctx, query)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	if query.Message != nil && query.Message.Chat != nil && promptRepository != nil && strings.HasPrefix(query.Data, promptCallbackPrefix) {
+		handlePromptCallbackQuery(ctx, query)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	if query.Message == nil || query.Message.Chat == nil || !strings.HasPrefix(query.Data, dialogCallbackPrefix) {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	chatId := fmt.Sprintf("%d", query.Message.Chat.ID)
+	answer := strings.TrimPrefix(query.Data, dialogCallbackPrefix)
+
+	_, err := telegramService.Client.EditMessageText(ctx, &telegram.EditMessageTextRequest{
+		ChatId:    chatId,
+		MessageId: query.Message.MessageId,
+		Text:      query.Message.Text + " " + answer,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error removing the dialog buttons", "error", err)
+	}
+
+	msg := &telegram.Message{
+		Chat: query.Message.Chat,
+		From: query.From,
+		Text: answer,
+	}
+	handled, err := continueDialog(ctx, msg, answer)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error continuing dialog", "error", err)
+	}
+	if !handled && err == nil {
+		telegramService.SendMessage(ctx, "This question has expired, send the command again", chatId, false)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+// handleCancelToTelegram aborts the dialog the user is in.
+func handleCancelToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+	if statusRepository == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "statusRepository is not initialized",
+		}, nil
+	}
+
+	var status telegram.ChatStatus
+	found, err := statusRepository.GetStatus(chatId, &status)
+	found = found && inDialog(&status, msg.Message.From)
+	if err == nil && found {
+		err = statusRepository.DeleteStatus(chatId)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error cancelling dialog", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	if found {
+		telegramService.SendMessage(ctx, "Cancelled", chatId, false)
+	} else {
+		telegramService.SendMessage(ctx, "There is nothing to cancel", chatId, false)
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+func dialogMessage(params *telegram.MsgParams) *telegram.Message {
+	return &telegram.Message{
+		Chat: &telegram.Chat{ID: params.ChatId},
+		From: params.From,
+		Text: params.ChatMsg,
+	}
+}
+
+func askImagePrompt(params *telegram.MsgParams) error {
+	return telegramService.SendMessage(params.Ctx, "What should I draw? Send /cancel to stop.", fmt.Sprintf("%d", params.ChatId), false)
+}
+
+func answerImagePrompt(params *telegram.MsgParams) (string, error) {
+	prompt, options, err := chatgpt.ParseImageOptions(params.FirstArg)
+	if err != nil {
+		return "", err
+	}
+	if prompt == "" {
+		return "", errors.New("describe the image you want")
+	}
+
+	params.Status.LastParameter = params.FirstArg
+	if options.Size != "" {
+		generateImage(params.Ctx, dialogMessage(params), prompt, options)
+		return "", nil
+	}
+	return imageSizeState, nil
+}
+
+func askImageSize(params *telegram.MsgParams) error {
+	spec, err := chatgpt.GetImageModelSpec(chatGPT.ImageModel)
+	if err != nil {
+		return err
+	}
+	return telegramService.SendRepliedMessage(params.Ctx, "Which size?", fmt.Sprintf("%d", params.ChatId), dialogButtons(spec.Sizes))
+}
+
+func answerImageSize(params *telegram.MsgParams) (string, error) {
+	spec, err := chatgpt.GetImageModelSpec(chatGPT.ImageModel)
+	if err != nil {
+		return "", err
+	}
+
+	size := strings.ToLower(params.FirstArg)
+	valid := false
+	for _, value := range spec.Sizes {
+		valid = valid || value == size
+	}
+	if !valid {
+		return "", fmt.Errorf("choose one of the sizes: %s", strings.Join(spec.Sizes, ", "))
+	}
+
+	prompt, options, err := chatgpt.ParseImageOptions(params.Status.LastParameter)
+	if err != nil {
+		return "", err
+	}
+	options.Size = size
+
+	generateImage(params.Ctx, dialogMessage(params), prompt, options)
+	return "", nil
+}
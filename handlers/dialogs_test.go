@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/services/chatgpt"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useStatusRepository(t *testing.T) {
+	_, client := newTestTable(t)
+	previous := statusRepository
+	statusRepository = &db.StatusRepository{DBClient: client}
+	t.Cleanup(func() { statusRepository = previous })
+}
+
+func chatStatus(t *testing.T, chatId string) *telegram.ChatStatus {
+	var status telegram.ChatStatus
+	found, err := statusRepository.GetStatus(chatId, &status)
+	require.NoError(t, err)
+	if !found {
+		return nil
+	}
+	return &status
+}
+
+func dialogMessageFrom(chatId int64, text string) *telegram.Message {
+	return &telegram.Message{
+		Chat: &telegram.Chat{ID: chatId},
+		From: &telegram.From{ID: 7},
+		Text: text,
+	}
+}
+
+func TestImageDialog(t *testing.T) {
+	useStatusRepository(t)
+	recorder := useTelegram(t)
+	ctx := context.Background()
+
+	spec, err := chatgpt.GetImageModelSpec(chatGPT.ImageModel)
+	require.NoError(t, err)
+
+	require.NoError(t, startDialog(ctx, dialogMessageFrom(42, "/createimage"), imagePromptState, ""))
+	assert.Equal(t, []string{"What should I draw? Send /cancel to stop."}, recorder.texts())
+	assert.Equal(t, imagePromptState, chatStatus(t, "42").Status)
+
+	steps := []struct {
+		name          string
+		answer        string
+		wantTexts     []string
+		wantState     string
+		wantParameter string
+	}{
+		{
+			name:      "empty prompt",
+			answer:    " ",
+			wantTexts: []string{"describe the image you want", "What should I draw? Send /cancel to stop."},
+			wantState: imagePromptState,
+		},
+		{
+			name:          "prompt",
+			answer:        "a red fox",
+			wantTexts:     []string{"Which size?"},
+			wantState:     imageSizeState,
+			wantParameter: "a red fox",
+		},
+		{
+			name:          "invalid size",
+			answer:        "huge",
+			wantTexts:     []string{"choose one of the sizes: " + strings.Join(spec.Sizes, ", "), "Which size?"},
+			wantState:     imageSizeState,
+			wantParameter: "a red fox",
+		},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			handled, err := continueDialog(ctx, dialogMessageFrom(42, step.answer), step.answer)
+			require.NoError(t, err)
+			assert.True(t, handled)
+			assert.Equal(t, step.wantTexts, recorder.texts())
+
+			status := chatStatus(t, "42")
+			require.NotNil(t, status)
+			assert.Equal(t, step.wantState, status.Status)
+			assert.Equal(t, step.wantParameter, status.LastParameter)
+		})
+	}
+}
+
+func TestContinueDialogWithoutDialog(t *testing.T) {
+	useStatusRepository(t)
+	recorder := useTelegram(t)
+
+	handled, err := continueDialog(context.Background(), dialogMessageFrom(42, "hello"), "hello")
+	require.NoError(t, err)
+	assert.False(t, handled)
+	assert.Empty(t, recorder.texts())
+}
+
+func TestContinueDialogUnknownState(t *testing.T) {
+	useStatusRepository(t)
+	useTelegram(t)
+
+	require.NoError(t, saveChatStatus(&telegram.ChatStatus{ChatId: 42, Status: "removed.state", LastUpdate: time.Now()}))
+
+	handled, err := continueDialog(context.Background(), dialogMessageFrom(42, "hello"), "hello")
+	require.NoError(t, err)
+	assert.False(t, handled)
+	assert.Nil(t, chatStatus(t, "42"))
+}
+
+func TestCancelDialog(t *testing.T) {
+	useStatusRepository(t)
+	recorder := useTelegram(t)
+	ctx := context.Background()
+
+	msg := telegram.WebhookMessage{Message: dialogMessageFrom(42, "/cancel")}
+
+	require.NoError(t, startDialog(ctx, msg.Message, imagePromptState, ""))
+	recorder.texts()
+
+	response, err := handleCancelToTelegram(ctx, events.APIGatewayV2HTTPRequest{}, msg)
+	require.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, []string{"Cancelled"}, recorder.texts())
+	assert.Nil(t, chatStatus(t, "42"))
+
+	_, err = handleCancelToTelegram(ctx, events.APIGatewayV2HTTPRequest{}, msg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"There is nothing to cancel"}, recorder.texts())
+}
+
+func TestDialogOfAnotherUser(t *testing.T) {
+	useStatusRepository(t)
+	recorder := useTelegram(t)
+	ctx := context.Background()
+
+	require.NoError(t, startDialog(ctx, dialogMessageFrom(42, "/createimage"), imagePromptState, ""))
+	recorder.texts()
+	assert.Equal(t, int64(7), chatStatus(t, "42").UserId)
+
+	other := dialogMessageFrom(42, "a red fox")
+	other.From = &telegram.From{ID: 8}
+	handled, err := continueDialog(ctx, other, other.Text)
+	require.NoError(t, err)
+	assert.False(t, handled)
+
+	other.Text = "/cancel"
+	_, err = handleCancelToTelegram(ctx, events.APIGatewayV2HTTPRequest{}, telegram.WebhookMessage{Message: other})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"There is nothing to cancel"}, recorder.texts())
+	assert.Equal(t, imagePromptState, chatStatus(t, "42").Status)
+
+	handled, err = continueDialog(ctx, dialogMessageFrom(42, "a red fox"), "a red fox")
+	require.NoError(t, err)
+	assert.True(t, handled)
+	assert.Equal(t, imageSizeState, chatStatus(t, "42").Status)
+}
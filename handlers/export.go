@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/marlosl/gpt-telegram-bot/services/export"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// handleExportToTelegram answers /export [md|json|html] with the stored
+// conversation of the chat as a file. Tokens and keys in the messages are
+// always redacted.
+func handleExportToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+	cmd telegram.Command,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+
+	text, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+	format, err := export.ParseFormat(*text)
+	if err != nil {
+		telegramService.SendMessage(ctx, fmt.Sprintf("%v\nUsage: /export [md|json|html]", err), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	userId := ""
+	if msg.Message.From != nil {
+		userId = fmt.Sprintf("%d", msg.Message.From.ID)
+	}
+
+	thread, err := activeThread(ctx, chatId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading the thread", "error", err)
+	}
+	threadId := ""
+	if thread != nil {
+		threadId = thread.ThreadId
+	}
+
+	conversation, err := export.Load(historyRepository, galleryRepository, imageStorage, chatId, threadId, userId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading the conversation", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	if thread != nil {
+		conversation.Title = thread.Title
+	}
+
+	if len(conversation.Entries) == 0 {
+		telegramService.SendMessage(ctx, "There is no stored conversation to export", chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	conversation = export.Redact(conversation, export.Options{Secrets: true})
+	content, err := export.Render(conversation, format)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error rendering the conversation", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	stopAction := telegramService.StartChatAction(ctx, chatId, telegram.UploadDocumentAction)
+	defer stopAction()
+
+	caption := fmt.Sprintf("%d messages", len(conversation.Entries))
+	err = telegramService.SendDocument(ctx, bytes.NewReader(content), export.Filename(conversation, format), chatId, caption)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending the export", "error", err)
+		telegramService.SendMessage(ctx, fmt.Sprintf("Error while sending the export: %v", err), chatId, false)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
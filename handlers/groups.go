@@ -0,0 +1,478 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/services/chatgpt"
+	"github.com/marlosl/gpt-telegram-bot/services/moderation"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	groupHistoryLimit = 20
+	groupSystemPrompt = "You are a helpful assistant taking part in a Telegram group chat. " +
+		"Each user message starts with the name of the person who wrote it."
+	settingsUsage = "Usage:\n/settings commands all|<command>,<command>\n/settings quiet off|HH:MM-HH:MM [time zone]\n" +
+		"/settings tools all|none|<tool>,<tool>\n/settings tz <time zone>|utc"
+	privateSettingsUsage = "Usage: /settings tz <time zone>|utc, e.g. /settings tz America/Sao_Paulo"
+)
+
+// filterGroupMessage decides whether a group message is handled. The bot
+// answers when it is mentioned, replied to or sent one of its commands;
+// other text messages are only kept as context for the group conversation,
+// which requires the bot privacy mode to be disabled. The mention and the
+// @username suffix of commands are removed from the message.
+func filterGroupMessage(ctx context.Context, msg *telegram.Message) bool {
+	chatId := fmt.Sprintf("%d", msg.Chat.ID)
+
+	me, err := telegramService.GetMe(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting the bot user", "error", err)
+		return false
+	}
+
+	username := ""
+	if me.UserName != nil {
+		username = *me.UserName
+	}
+
+	addressed := msg.IsReplyTo(me.ID)
+	for _, field := range []*string{&msg.Text, &msg.Caption} {
+		if *field == "" {
+			continue
+		}
+
+		text, ok := telegram.AddressedCommand(*field, username)
+		if !ok {
+			return false
+		}
+		// A slash only addresses the bot in a command it knows, or in one
+		// sent to it as /command@username.
+		if strings.HasPrefix(text, "/") && (text != *field || telegram.GetCommand(&text) != telegram.None) {
+			addressed = true
+		}
+		if telegram.Mentions(text, username) {
+			addressed = true
+			text = telegram.StripMention(text, username)
+		}
+		*field = text
+	}
+
+	if !addressed {
+		if msg.Text != "" {
+			keepGroupContext(ctx, msg, chatId)
+		}
+		return false
+	}
+
+	command := groupCommand(msg)
+	if command == telegram.SettingsCommand || command == telegram.CancelCommand || command == telegram.ModerationCommand {
+		return true
+	}
+
+	settings, err := settingsRepository.GetSettings(chatId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading chat settings", "error", err)
+		return true
+	}
+
+	if inQuietHours(settings, time.Now()) {
+		slog.InfoContext(ctx, "Ignoring message during quiet hours", "chat_id", chatId)
+		return false
+	}
+
+	if command != telegram.None && !commandAllowed(settings, command) {
+		telegramService.SendMessage(ctx, fmt.Sprintf("%s is disabled in this group", command), chatId, false)
+		return false
+	}
+	return true
+}
+
+// keepGroupContext stores a message not addressed to the bot in the active
+// thread of the group. It does not start a thread, and messages blocked by
+// the moderation are not kept, as they would be sent to the model later.
+func keepGroupContext(ctx context.Context, msg *telegram.Message, chatId string) {
+	thread, err := activeThread(ctx, chatId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading the active thread", "error", err)
+		return
+	}
+	if thread == nil {
+		return
+	}
+
+	decision := checkModeration(ctx, chatId, msg.From, moderation.Prompts, msg.Text)
+	if decision.Action == moderation.Block {
+		slog.InfoContext(ctx, "Not keeping a group message blocked by moderation", "chat_id", chatId)
+		return
+	}
+	addHistoryMessage(ctx, msg, thread)
+}
+
+func groupCommand(msg *telegram.Message) telegram.Command {
+	if msg.Text != "" {
+		return telegram.GetCommand(&msg.Text)
+	}
+	return telegram.GetCommand(&msg.Caption)
+}
+
+// talkInGroup answers the message using the recent messages of the group
+// thread as context, labelling each one with the name of who wrote it.
+// Replying to an earlier answer of the bot continues the conversation from
+// that answer.
+func talkInGroup(ctx context.Context, msg *telegram.Message) (*chatgpt.ChatResponse, error) {
+	if historyRepository == nil {
+		return talk(ctx, msg)
+	}
+
+	thread := currentThread(ctx, fmt.Sprintf("%d", msg.Chat.ID))
+	history, err := threadHistory(ctx, msg, thread, groupHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []chatgpt.ChatMessage{
+		{
+			Role:    "system",
+			Content: groupSystemPrompt,
+		},
+	}
+	messages = append(messages, documentMessages(ctx, msg)...)
+	messages = append(messages, historyMessages(history, true)...)
+
+	text := msg.Text
+	if reply := msg.ReplyToMessage; reply != nil && !isBotMessage(ctx, reply) {
+		text = withQuote(text, speakerName(reply.From), quotedContent(ctx, reply))
+	}
+	messages = append(messages, chatgpt.ChatMessage{
+		Role:    "user",
+		Content: speakerName(msg.From) + ": " + text,
+	})
+
+	addHistoryMessage(ctx, msg, thread)
+	return converse(ctx, msg, thread, messages)
+}
+
+func speakerName(from *telegram.From) string {
+	if from == nil || from.FirstName == "" {
+		return "Someone"
+	}
+	return from.FirstName
+}
+
+func commandAllowed(settings *db.ChatSettings, cmd telegram.Command) bool {
+	if len(settings.AllowedCommands) == 0 {
+		return true
+	}
+
+	name := strings.TrimPrefix(string(cmd), "/")
+	for _, allowed := range settings.AllowedCommands {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// inQuietHours reports whether now falls in the quiet hours of the chat.
+// The window may cross midnight, e.g. 22:00-07:00.
+func inQuietHours(settings *db.ChatSettings, now time.Time) bool {
+	if settings.QuietHoursStart == "" || settings.QuietHoursEnd == "" {
+		return false
+	}
+
+	start, err := parseClock(settings.QuietHoursStart)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(settings.QuietHoursEnd)
+	if err != nil {
+		return false
+	}
+
+	location, err := time.LoadLocation(settings.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// parseClock returns the minutes since midnight of a HH:MM time.
+func parseClock(value string) (int, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+func handleSettingsToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+	cmd telegram.Command,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+	isGroup := msg.Message.Chat.IsGroup()
+	usage := settingsUsage
+	if !isGroup {
+		usage = privateSettingsUsage
+	}
+
+	settings, err := settingsRepository.GetSettings(chatId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading chat settings", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	text, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+	args := strings.Fields(*text)
+	if len(args) == 0 {
+		telegramService.SendMessage(ctx, describeSettings(settings, isGroup)+"\n\n"+usage, chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	if isGroup {
+		isAdmin := false
+		if msg.Message.From != nil {
+			isAdmin, err = telegramService.IsChatAdmin(ctx, chatId, msg.Message.From.ID)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error checking chat administrator", "error", err)
+			}
+		}
+		if !isAdmin {
+			telegramService.SendMessage(ctx, "Only group administrators can change the settings", chatId, false)
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+			}, nil
+		}
+	}
+
+	var attributes []string
+	setting := strings.ToLower(args[0])
+	switch {
+	case setting == "tz":
+		err = setTimeZone(settings, args[1:])
+		attributes = []string{db.TimeZoneSetting}
+	case !isGroup:
+		err = fmt.Errorf("%s is only available in groups", setting)
+	case setting == "commands":
+		err = setAllowedCommands(settings, args[1:])
+		attributes = []string{db.AllowedCommandsSetting}
+	case setting == "quiet":
+		err = setQuietHours(settings, args[1:])
+		attributes = []string{db.QuietHoursStartSetting, db.QuietHoursEndSetting}
+		if len(args) > 2 {
+			attributes = append(attributes, db.TimeZoneSetting)
+		}
+	case setting == "tools":
+		err = setAllowedTools(settings, args[1:])
+		attributes = []string{db.AllowedToolsSetting, db.ToolsDisabledSetting}
+	default:
+		err = fmt.Errorf("unknown setting %s", args[0])
+	}
+
+	if err != nil {
+		telegramService.SendMessage(ctx, fmt.Sprintf("%v\n%s", err, usage), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	err = settingsRepository.UpdateSettings(settings, attributes...)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving chat settings", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	telegramService.SendMessage(ctx, describeSettings(settings, isGroup), chatId, false)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+func setAllowedCommands(settings *db.ChatSettings, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("inform the allowed commands")
+	}
+
+	if len(args) == 1 && strings.EqualFold(args[0], "all") {
+		settings.AllowedCommands = nil
+		return nil
+	}
+
+	var allowed []string
+	for _, name := range strings.Split(strings.Join(args, ","), ",") {
+		name = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "/")
+		if name == "" {
+			continue
+		}
+		if !isGroupCommand(name) {
+			return fmt.Errorf("unknown command %s", name)
+		}
+		allowed = append(allowed, name)
+	}
+	settings.AllowedCommands = allowed
+	return nil
+}
+
+func isGroupCommand(name string) bool {
+	for _, cmd := range telegram.Commands {
+		if string(cmd) == "/"+name {
+			return true
+		}
+	}
+	return false
+}
+
+func setAllowedTools(settings *db.ChatSettings, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("inform the allowed tools")
+	}
+
+	settings.AllowedTools = nil
+	settings.ToolsDisabled = false
+	if len(args) == 1 && strings.EqualFold(args[0], "all") {
+		return nil
+	}
+	if len(args) == 1 && strings.EqualFold(args[0], "none") {
+		settings.ToolsDisabled = true
+		return nil
+	}
+
+	var allowed []string
+	for _, name := range strings.Split(strings.Join(args, ","), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !isToolName(name) {
+			return fmt.Errorf("unknown tool %s, the tools are %s", name, strings.Join(toolRegistry.Names(), ", "))
+		}
+		allowed = append(allowed, name)
+	}
+	settings.AllowedTools = allowed
+	return nil
+}
+
+func isToolName(name string) bool {
+	for _, tool := range toolRegistry.Names() {
+		if tool == name {
+			return true
+		}
+	}
+	return false
+}
+
+func setQuietHours(settings *db.ChatSettings, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("inform the quiet hours")
+	}
+
+	if strings.EqualFold(args[0], "off") {
+		settings.QuietHoursStart = ""
+		settings.QuietHoursEnd = ""
+		return nil
+	}
+
+	window := strings.Split(args[0], "-")
+	if len(window) != 2 {
+		return fmt.Errorf("invalid quiet hours %s", args[0])
+	}
+	for _, value := range window {
+		if _, err := parseClock(value); err != nil {
+			return fmt.Errorf("invalid time %s", value)
+		}
+	}
+
+	if len(args) > 1 {
+		if err := setTimeZone(settings, args[1:]); err != nil {
+			return err
+		}
+	}
+
+	settings.QuietHoursStart = window[0]
+	settings.QuietHoursEnd = window[1]
+	return nil
+}
+
+// setTimeZone sets the time zone of the chat, used by the quiet hours and
+// the schedules.
+func setTimeZone(settings *db.ChatSettings, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("inform the time zone")
+	}
+
+	if strings.EqualFold(args[0], "utc") {
+		settings.TimeZone = ""
+		return nil
+	}
+	if _, err := time.LoadLocation(args[0]); err != nil || args[0] == "" || strings.EqualFold(args[0], "local") {
+		return fmt.Errorf("unknown time zone %s", args[0])
+	}
+	settings.TimeZone = args[0]
+	return nil
+}
+
+func describeTimeZone(settings *db.ChatSettings) string {
+	if settings.TimeZone == "" {
+		return "UTC"
+	}
+	return settings.TimeZone
+}
+
+// describeSettings lists the settings of the chat. Private chats only have
+// a time zone.
+func describeSettings(settings *db.ChatSettings, isGroup bool) string {
+	if !isGroup {
+		return "Time zone: " + describeTimeZone(settings)
+	}
+
+	commands := "all"
+	if len(settings.AllowedCommands) > 0 {
+		commands = strings.Join(settings.AllowedCommands, ", ")
+	}
+
+	quiet := "off"
+	if settings.QuietHoursStart != "" && settings.QuietHoursEnd != "" {
+		quiet = fmt.Sprintf("%s-%s", settings.QuietHoursStart, settings.QuietHoursEnd)
+	}
+	tools := "all"
+	if settings.ToolsDisabled {
+		tools = "none"
+	} else if len(settings.AllowedTools) > 0 {
+		tools = strings.Join(settings.AllowedTools, ", ")
+	}
+	summarize := "off"
+	if settings.AutoSummarize {
+		summarize = "on"
+	}
+	return fmt.Sprintf("Allowed commands: %s\nQuiet hours: %s\nTime zone: %s\nTools: %s\nAuto summarize: %s",
+		commands, quiet, describeTimeZone(settings), tools, summarize)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/clients/db/dbtest"
+	"github.com/marlosl/gpt-telegram-bot/services/moderation"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useGroupRepositories points the repositories used by the group handlers
+// to an in-memory table for the test.
+func useGroupRepositories(t *testing.T) *dbtest.Server {
+	server, client := newTestTable(t)
+	settings, threads, history, records := settingsRepository, threadRepository, historyRepository, moderationRepository
+	settingsRepository = &db.SettingsRepository{DBClient: client}
+	threadRepository = &db.ThreadRepository{DBClient: client}
+	historyRepository = &db.HistoryRepository{DBClient: client}
+	moderationRepository = &db.ModerationRepository{DBClient: client}
+	t.Cleanup(func() {
+		settingsRepository, threadRepository, historyRepository, moderationRepository = settings, threads, history, records
+	})
+	return server
+}
+
+func groupMessage(text string) *telegram.Message {
+	return &telegram.Message{
+		Chat: &telegram.Chat{ID: -100, Type: telegram.GroupChat},
+		From: &telegram.From{ID: 7, FirstName: "Ana"},
+		Text: text,
+	}
+}
+
+func TestFilterGroupMessage(t *testing.T) {
+	useTelegram(t)
+	useGroupRepositories(t)
+
+	tests := []struct {
+		name     string
+		text     string
+		want     bool
+		wantText string
+	}{
+		{"command", "/createimage a fox", true, "/createimage a fox"},
+		{"command to the bot", "/createimage@GptBot a fox", true, "/createimage a fox"},
+		{"unknown command to the bot", "/help@GptBot", true, "/help"},
+		{"command to another bot", "/createimage@OtherBot a fox", false, "/createimage@OtherBot a fox"},
+		{"path", "/etc/hosts is broken again", false, "/etc/hosts is broken again"},
+		{"unknown command", "/help", false, "/help"},
+		{"mention", "@GptBot what time is it?", true, "what time is it?"},
+		{"chatter", "see you tomorrow", false, "see you tomorrow"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := groupMessage(tt.text)
+			assert.Equal(t, tt.want, filterGroupMessage(context.Background(), msg))
+			assert.Equal(t, tt.wantText, msg.Text)
+		})
+	}
+}
+
+func TestGroupChatterKeptInActiveThread(t *testing.T) {
+	recorder := useTelegram(t)
+	server := useGroupRepositories(t)
+	previous := moderationGate
+	keywords, err := moderation.ParseKeywords("credentials: password")
+	require.NoError(t, err)
+	moderationGate = &moderation.Gate{
+		Moderator: keywords,
+		Policy:    moderation.Policy{Default: moderation.Block},
+		Stages:    []moderation.Stage{moderation.Prompts},
+	}
+	t.Cleanup(func() { moderationGate = previous })
+	ctx := context.Background()
+
+	// Without an active thread the chatter is not kept and no thread is
+	// started for it.
+	assert.False(t, filterGroupMessage(ctx, groupMessage("see you tomorrow")))
+	assert.Empty(t, server.Items())
+
+	thread, err := threadRepository.CreateThread("-100", "")
+	require.NoError(t, err)
+	server.Put(dbtest.Item{
+		"PK":           {S: aws.String("CHAT#-100")},
+		"SK":           {S: aws.String(db.SETTINGS)},
+		"ChatId":       {S: aws.String("-100")},
+		"ActiveThread": {S: aws.String(thread.ThreadId)},
+	})
+
+	assert.False(t, filterGroupMessage(ctx, groupMessage("see you tomorrow")))
+	assert.False(t, filterGroupMessage(ctx, groupMessage("my password is hunter2")))
+
+	history, err := historyRepository.ListMessages("-100", thread.ThreadId, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "see you tomorrow", history[0].Content)
+	assert.Empty(t, recorder.texts())
+}
@@ -1,19 +1,33 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/clients/s3"
 	"github.com/marlosl/gpt-telegram-bot/clients/sqs"
 	"github.com/marlosl/gpt-telegram-bot/consts"
 	"github.com/marlosl/gpt-telegram-bot/services/chatgpt"
+	"github.com/marlosl/gpt-telegram-bot/services/embeddings"
+	"github.com/marlosl/gpt-telegram-bot/services/knowledge"
+	"github.com/marlosl/gpt-telegram-bot/services/moderation"
 	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+	"github.com/marlosl/gpt-telegram-bot/services/tools"
+	"github.com/marlosl/gpt-telegram-bot/services/web"
 	"github.com/marlosl/gpt-telegram-bot/utils/config"
+	"github.com/marlosl/gpt-telegram-bot/utils/logger"
+	"github.com/marlosl/gpt-telegram-bot/utils/metrics"
+	"github.com/marlosl/gpt-telegram-bot/utils/tracing"
 
 	"github.com/aws/aws-lambda-go/events"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Chat struct {
@@ -21,13 +35,31 @@ type Chat struct {
 }
 
 var (
-	chatGPT         *chatgpt.ChatGPT
-	sqsClient       *sqs.SQSClient
-	telegramService *telegram.Telegram
+	chatGPT              *chatgpt.ChatGPT
+	sqsClient            *sqs.SQSClient
+	telegramService      *telegram.Telegram
+	settingsRepository   *db.SettingsRepository
+	galleryRepository    *db.GalleryRepository
+	historyRepository    *db.HistoryRepository
+	inlineRepository     *db.InlineRepository
+	documentRepository   *db.DocumentRepository
+	scheduleRepository   *db.ScheduleRepository
+	statusRepository     *db.StatusRepository
+	threadRepository     *db.ThreadRepository
+	moderationRepository *db.ModerationRepository
+	promptRepository     *db.PromptRepository
+	embedder             embeddings.Embedder
+	knowledgeStore       knowledge.Store
+	toolRegistry         *chatgpt.ToolRegistry
+	webFetcher           *web.Fetcher
+	imageStorage         *s3.S3Client
+	moderationGate       *moderation.Gate
 )
 
 func init() {
 	config.NewConfig(config.SSM)
+	tracing.Init("chat-gpt-talk-handler")
+
 	if chatGPT == nil {
 		chatGPT = chatgpt.NewChatGPT()
 	}
@@ -40,6 +72,70 @@ func init() {
 	if telegramService == nil {
 		telegramService = telegram.NewTextService()
 	}
+
+	if settingsRepository == nil {
+		settingsRepository, _ = db.NewSettingsRepository()
+	}
+
+	if galleryRepository == nil {
+		galleryRepository, _ = db.NewGalleryRepository()
+	}
+
+	if historyRepository == nil {
+		historyRepository, _ = db.NewHistoryRepository()
+	}
+
+	if inlineRepository == nil {
+		inlineRepository, _ = db.NewInlineRepository()
+	}
+
+	if documentRepository == nil {
+		documentRepository, _ = db.NewDocumentRepository()
+	}
+
+	if scheduleRepository == nil {
+		scheduleRepository, _ = db.NewScheduleRepository()
+	}
+
+	if statusRepository == nil {
+		statusRepository, _ = db.NewStatusRepository()
+	}
+
+	if threadRepository == nil {
+		threadRepository, _ = db.NewThreadRepository()
+	}
+
+	if moderationRepository == nil {
+		moderationRepository, _ = db.NewModerationRepository()
+	}
+
+	if promptRepository == nil {
+		promptRepository, _ = db.NewPromptRepository()
+	}
+
+	if embedder == nil {
+		embedder = embeddings.NewEmbedder()
+	}
+
+	if knowledgeStore == nil {
+		knowledgeStore, _ = knowledge.NewStore()
+	}
+
+	if toolRegistry == nil {
+		toolRegistry = tools.NewRegistry(historyRepository)
+	}
+
+	if webFetcher == nil {
+		webFetcher = web.NewFetcher()
+	}
+
+	if moderationGate == nil {
+		moderationGate = moderation.NewGate()
+	}
+
+	if bucket := os.Getenv(consts.ImageBucket); imageStorage == nil && bucket != "" {
+		imageStorage, _ = s3.NewS3Client(bucket)
+	}
 }
 
 func handlePingPong(req events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
@@ -49,7 +145,7 @@ func handlePingPong(req events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyR
 	}, nil
 }
 
-func handleCommandChatTelegram(req events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+func handleCommandChatTelegram(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
 	var msg telegram.WebhookMessage
 
 	if req.Headers[consts.TelegramWebhookTokenHeader] != config.Store.TelegramWebhookToken {
@@ -61,7 +157,7 @@ func handleCommandChatTelegram(req events.APIGatewayV2HTTPRequest) (events.APIGa
 
 	err := checkServices()
 	if err != nil {
-		fmt.Println(err)
+		slog.ErrorContext(ctx, "Services are not available", "error", err)
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
 			Body:       err.Error(),
@@ -70,37 +166,190 @@ func handleCommandChatTelegram(req events.APIGatewayV2HTTPRequest) (events.APIGa
 
 	err = json.Unmarshal([]byte(req.Body), &msg)
 	if err != nil {
-		fmt.Println(err)
+		slog.ErrorContext(ctx, "Error unmarshalling update", "error", err)
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
 			Body:       err.Error(),
 		}, nil
 	}
 
-	updateId := fmt.Sprintf("%d", msg.UpdateId)
-	fmt.Printf("Validating if UpdateId exists: %s\n", updateId)
+	ctx = logger.WithCorrelationId(ctx, logger.NewCorrelationId(msg.UpdateId))
+	ctx, span := tracing.Start(ctx, "telegram.update", attribute.Int64("update_id", msg.UpdateId))
+	defer span.End()
 
+	updateId := fmt.Sprintf("%d", msg.UpdateId)
 	if telegramService.Cache.ItemExists(updateId) {
-		fmt.Println("UpdateId already exists")
+		metrics.Increment("DuplicateUpdates", nil)
+		slog.InfoContext(ctx, "Ignoring duplicated update", "update_id", updateId)
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusOK,
 		}, nil
 	}
 
-	fmt.Println("UpdateId does not exist, saving it")
 	telegramService.Cache.SaveItem(&updateId)
+	metrics.Increment("UpdatesReceived", metrics.Dimensions{"UpdateType": updateType(msg)})
+
+	if msg.CallbackQuery != nil {
+		return handleCallbackQuery(ctx, msg.CallbackQuery)
+	}
+
+	if msg.InlineQuery != nil {
+		return handleInlineQuery(ctx, msg.InlineQuery)
+	}
+
+	if msg.ChosenInlineResult != nil {
+		return handleChosenInlineResult(ctx, msg.ChosenInlineResult)
+	}
+
+	if msg.Message == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	if msg.Message.Chat.IsGroup() && !filterGroupMessage(ctx, msg.Message) {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	if isVoiceMessage(msg.Message) {
+		chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+		err = transcribeVoiceMessage(ctx, msg.Message, chatId)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error transcribing voice message", "error", err)
+			telegramService.SendMessage(ctx, fmt.Sprintf("Error while transcribing audio: %v", err), chatId, false)
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+			}, nil
+		}
+	}
+
+	if msg.Message.Text == "" && strings.HasPrefix(msg.Message.Caption, "/") {
+		msg.Message.Text = msg.Message.Caption
+	}
+
+	if msg.Message.Text == "" && isDocumentMessage(msg.Message) {
+		return handleDocumentToTelegram(ctx, req, msg)
+	}
+
+	if msg.Message.Text == "" && isPhotoMessage(msg.Message) {
+		return handlePhotoToChatTelegram(ctx, req, msg)
+	}
+
+	if msg.Message.Text == "" {
+		slog.DebugContext(ctx, "Message has no text, ignoring it")
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
 
 	command := telegram.GetCommand(&msg.Message.Text)
-	fmt.Printf("Command: %s\n", command)
+	slog.InfoContext(ctx, "Handling update", "update_id", updateId, "command", command)
+	metrics.Increment("Commands", metrics.Dimensions{"Command": commandName(command)})
+
+	if moderatedCommands[command] && !moderate(ctx, msg.Message, moderation.Prompts, msg.Message.Text) {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
 
 	switch command {
 	case telegram.CreateImageCommand:
-		return handleGenerateImageToTelegram(req, msg, command)
+		return handleGenerateImageToTelegram(ctx, req, msg, command)
+	case telegram.EditImageCommand, telegram.VariationCommand:
+		return handleEditImageToTelegram(ctx, req, msg, command)
+	case telegram.GalleryCommand:
+		return handleGalleryToTelegram(ctx, req, msg)
+	case telegram.VoiceModeCommand:
+		return handleVoiceModeToTelegram(ctx, req, msg, command)
+	case telegram.SettingsCommand:
+		return handleSettingsToTelegram(ctx, req, msg, command)
+	case telegram.DocsCommand:
+		return handleDocsToTelegram(ctx, req, msg)
+	case telegram.ForgetCommand:
+		return handleForgetToTelegram(ctx, req, msg, command)
+	case telegram.KnowledgeCommand:
+		return handleKnowledgeToTelegram(ctx, req, msg, command)
+	case telegram.SummarizeCommand:
+		return handleSummarizeToTelegram(ctx, req, msg, command)
+	case telegram.RemindCommand:
+		return handleRemindToTelegram(ctx, req, msg, command)
+	case telegram.ScheduleCommand:
+		return handleScheduleToTelegram(ctx, req, msg, command)
+	case telegram.SchedulesCommand:
+		return handleSchedulesToTelegram(ctx, req, msg, command)
+	case telegram.CancelCommand:
+		return handleCancelToTelegram(ctx, req, msg)
+	case telegram.ExportCommand:
+		return handleExportToTelegram(ctx, req, msg, command)
+	case telegram.ModerationCommand:
+		return handleModerationToTelegram(ctx, req, msg)
+	case telegram.SavePromptCommand:
+		return handleSavePromptToTelegram(ctx, req, msg, command)
+	case telegram.RunPromptCommand:
+		return handleRunPromptToTelegram(ctx, req, msg, command)
+	case telegram.PromptsCommand:
+		return handlePromptsToTelegram(ctx, req, msg, command)
+	case telegram.NewThreadCommand:
+		return handleNewThreadToTelegram(ctx, req, msg, command)
+	case telegram.HistoryCommand:
+		return handleThreadHistoryToTelegram(ctx, req, msg)
+	case telegram.SwitchCommand:
+		return handleSwitchThreadToTelegram(ctx, req, msg, command)
+	case telegram.RenameCommand:
+		return handleRenameThreadToTelegram(ctx, req, msg, command)
+	case telegram.DeleteCommand:
+		return handleDeleteThreadToTelegram(ctx, req, msg, command)
 	}
-	return handleTalkToChatTelegram(req, msg, command)
+
+	if command == telegram.None {
+		handled, err := continueDialog(ctx, msg.Message, msg.Message.Text)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error continuing dialog", "error", err)
+		}
+		if handled {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+			}, nil
+		}
+	}
+
+	if command == telegram.None && isAutoSummarized(ctx, msg.Message) {
+		return summarizeUrl(ctx, msg.Message, msg.Message.Urls()[0])
+	}
+	return handleTalkToChatTelegram(ctx, req, msg, command)
+}
+
+func updateType(msg telegram.WebhookMessage) string {
+	switch {
+	case msg.CallbackQuery != nil:
+		return "callback_query"
+	case msg.InlineQuery != nil:
+		return "inline_query"
+	case msg.ChosenInlineResult != nil:
+		return "chosen_inline_result"
+	case msg.Message == nil:
+		return "other"
+	case isVoiceMessage(msg.Message):
+		return "voice"
+	case isPhotoMessage(msg.Message):
+		return "photo"
+	case msg.Message.Document != nil:
+		return "document"
+	}
+	return "text"
+}
+
+func commandName(cmd telegram.Command) string {
+	if cmd == telegram.None {
+		return "none"
+	}
+	return strings.TrimPrefix(string(cmd), "/")
 }
 
 func handleTalkToChatTelegram(
+	ctx context.Context,
 	req events.APIGatewayV2HTTPRequest,
 	msg telegram.WebhookMessage,
 	cmd telegram.Command,
@@ -108,43 +357,62 @@ func handleTalkToChatTelegram(
 	events.APIGatewayProxyResponse,
 	error,
 ) {
-	fmt.Println("handleTalkToChatTelegram - start")
 	var err error
 	var response *chatgpt.ChatResponse
 
 	chatId := ""
+	if msg.Message != nil && msg.Message.Chat != nil {
+		chatId = fmt.Sprintf("%d", msg.Message.Chat.ID)
+	}
+
+	if cmd == telegram.None {
+		if fileId := replyImageFileId(msg.Message); fileId != "" {
+			return answerAboutImage(ctx, msg.Message, fileId, msg.Message.Text)
+		}
+	}
+
+	voiceMode := getVoiceMode(chatId, cmd)
+	stopAction := telegramService.StartChatAction(ctx, chatId, replyAction(voiceMode))
+	defer stopAction()
 
 	switch cmd {
 	case telegram.EditCommand:
-		fmt.Println("handleTalkToChatTelegram - EditCommand")
 		text, instruction := telegram.ParseMessage(cmd, &msg.Message.Text)
-		response, err = chatGPT.Edit(*instruction, *text)
+		response, err = chatGPT.Edit(ctx, *instruction, *text)
+	case telegram.SpeakCommand:
+		text, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+		response, err = chatGPT.Talk(ctx, *text)
 	case telegram.None:
-		fmt.Println("handleTalkToChatTelegram - None")
-		response, err = chatGPT.Talk(msg.Message.Text)
+		if msg.Message.Chat.IsGroup() {
+			response, err = talkInGroup(ctx, msg.Message)
+		} else {
+			response, err = talk(ctx, msg.Message)
+		}
 	}
 
 	if err != nil {
-		fmt.Println(err)
+		slog.ErrorContext(ctx, "Error talking to ChatGPT", "error", err)
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
 			Body:       err.Error(),
 		}, nil
 	}
 
-	if msg.Message != nil && msg.Message.Chat != nil {
-		chatId = fmt.Sprintf("%d", msg.Message.Chat.ID)
-	}
-
 	if len(response.Choices) == 0 {
-		telegramService.SendMessage("No Chat GPT response", chatId, false)
+		telegramService.SendMessage(ctx, "No Chat GPT response", chatId, false)
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusNotFound,
 		}, nil
 	}
 
 	for _, choice := range response.Choices {
-		telegramService.SendMessage(choice.Message.Content, chatId, true)
+		if !moderate(ctx, &telegram.Message{Chat: msg.Message.Chat}, moderation.Outputs, choice.Message.Content) {
+			continue
+		}
+		sent := sendReply(ctx, choice.Message.Content, chatId, voiceMode)
+		if cmd == telegram.None {
+			addHistoryAnswer(ctx, msg.Message, choice.Message.Content, sent)
+		}
 	}
 
 	return events.APIGatewayProxyResponse{
@@ -153,6 +421,7 @@ func handleTalkToChatTelegram(
 }
 
 func handleGenerateImageToTelegram(
+	ctx context.Context,
 	req events.APIGatewayV2HTTPRequest,
 	msg telegram.WebhookMessage,
 	cmd telegram.Command,
@@ -161,36 +430,92 @@ func handleGenerateImageToTelegram(
 	error,
 ) {
 	chatId := ""
+	if msg.Message != nil && msg.Message.Chat != nil {
+		chatId = fmt.Sprintf("%d", msg.Message.Chat.ID)
+	}
+
 	text, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+	prompt, options, err := chatgpt.ParseImageOptions(*text)
+	if err != nil {
+		telegramService.SendMessage(ctx, fmt.Sprintf("%v\nUsage: /createimage <prompt> [--n 2] [--size 1024x1024] [--quality hd] [--style vivid]", err), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	if prompt == "" {
+		err = startDialog(ctx, msg.Message, imagePromptState, "")
+		if err != nil {
+			slog.ErrorContext(ctx, "Error starting dialog", "error", err)
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       err.Error(),
+			}, nil
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	return generateImage(ctx, msg.Message, prompt, options)
+}
 
-	response, err := chatGPT.CreateImage(*text)
+func generateImage(ctx context.Context, msg *telegram.Message, prompt string, options *chatgpt.ImageOptions) (events.APIGatewayProxyResponse, error) {
+	chatId := fmt.Sprintf("%d", msg.Chat.ID)
+	if !moderate(ctx, msg, moderation.Images, prompt) {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	stopAction := telegramService.StartChatAction(ctx, chatId, telegram.UploadPhotoAction)
+	defer stopAction()
+
+	response, err := chatGPT.CreateImage(ctx, prompt, options)
 	if err != nil {
-		fmt.Println(err)
+		slog.ErrorContext(ctx, "Error creating image", "error", err)
+		telegramService.SendMessage(ctx, fmt.Sprintf("Error while creating image: %v", err), chatId, false)
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
 			Body:       err.Error(),
 		}, nil
 	}
 
-	if msg.Message != nil && msg.Message.Chat != nil {
-		chatId = fmt.Sprintf("%d", msg.Message.Chat.ID)
-	}
+	return deliverImages(ctx, response, msg, prompt)
+}
 
+func deliverImages(ctx context.Context, response *chatgpt.CreateImageResponse, msg *telegram.Message, caption string) (events.APIGatewayProxyResponse, error) {
+	chatId := fmt.Sprintf("%d", msg.Chat.ID)
 	if response == nil || len(response.Data) == 0 {
-		telegramService.SendMessage("No images were created", chatId, false)
+		telegramService.SendMessage(ctx, "No images were created", chatId, false)
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusNotFound,
 		}, nil
 	}
 
-	telegramService.SendMessage(fmt.Sprintf("Number of generated images: %d", len(response.Data)), chatId, true)
+	userId := chatId
+	if msg.From != nil {
+		userId = fmt.Sprintf("%d", msg.From.ID)
+	}
 
-	for i, photo := range response.Data {
-		if config.Store.SendImageByUrl {
-			telegramService.SendMessage(fmt.Sprintf("Image url: %s", photo.Url), chatId, true)
-		} else {
-			sendPhoto(telegramService, i, photo.Url, chatId)
+	urls, err := archiveImages(ctx, response, userId, chatId, caption)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error archiving images", "error", err)
+		telegramService.SendMessage(ctx, fmt.Sprintf("Error while storing images: %v", err), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	if config.Store.SendImageByUrl {
+		text := strings.Join(urls, "\n")
+		if caption != "" {
+			text = caption + "\n" + text
 		}
+		telegramService.SendMessage(ctx, text, chatId, false)
+	} else {
+		sendPhotos(ctx, telegramService, urls, chatId, caption)
 	}
 
 	return events.APIGatewayProxyResponse{
@@ -198,33 +523,52 @@ func handleGenerateImageToTelegram(
 	}, nil
 }
 
-func sendPhoto(t *telegram.Telegram, i int, url string, chatId string) {
-	fmt.Printf("Sending image %d: %s\n", i, url)
-	message := &telegram.ImageMessage{
-		ChatId:   chatId,
-		ImageUrl: url,
+// sendPhotos queues the images to be delivered as albums, in groups of at
+// most MaxMediaGroupSize images.
+func sendPhotos(ctx context.Context, t *telegram.Telegram, urls []string, chatId string, caption string) {
+	for start := 0; start < len(urls); start += telegram.MaxMediaGroupSize {
+		end := start + telegram.MaxMediaGroupSize
+		if end > len(urls) {
+			end = len(urls)
+		}
+
+		slog.DebugContext(ctx, "Queueing images", "start", start, "end", end-1)
+		message := &telegram.ImageMessage{
+			ChatId:    chatId,
+			ImageUrls: urls[start:end],
+			Caption:   caption,
+		}
+		caption = ""
+
+		err := sqsClient.SendMsg(ctx, message)
+		if err != nil {
+			metrics.Increment("JobFailures", metrics.Dimensions{"Job": "queue_images"})
+			t.SendMessage(ctx, fmt.Sprintf("Error while sending image: %v\n", err), chatId, true)
+		}
 	}
 
-	err := sqsClient.SendMsg(message)
+	depth, err := sqsClient.QueueDepth(ctx)
 	if err != nil {
-		t.SendMessage(fmt.Sprintf("Error while sending image: %v\n", err), chatId, true)
+		slog.WarnContext(ctx, "Error getting queue depth", "error", err)
+		return
 	}
+	metrics.Add("QueueDepth", metrics.Count, float64(depth), metrics.Dimensions{"Queue": "send_image"})
 }
 
-func handleTalkToChatGPT(req events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+func handleTalkToChatGPT(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
 	var chat Chat
 	err := json.Unmarshal([]byte(req.Body), &chat)
 	if err != nil {
-		fmt.Println(err)
+		slog.ErrorContext(ctx, "Error unmarshalling request", "error", err)
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
 			Body:       err.Error(),
 		}, nil
 	}
 
-	response, err := chatGPT.Talk(chat.Message)
+	response, err := chatGPT.Talk(ctx, chat.Message)
 	if err != nil {
-		fmt.Println(err)
+		slog.ErrorContext(ctx, "Error talking to ChatGPT", "error", err)
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
 			Body:       err.Error(),
@@ -257,5 +601,9 @@ func checkServices() error {
 		return errors.New("telegramService is not initialized")
 	}
 
+	if settingsRepository == nil {
+		return errors.New("settingsRepository is not initialized")
+	}
+
 	return nil
 }
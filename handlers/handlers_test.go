@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/clients/db/dbtest"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+	"github.com/marlosl/gpt-telegram-bot/utils/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// The package init loads the configuration from SSM unless it is already
+// set, and variables are initialized before it runs.
+var _ = func() bool {
+	config.Store = &config.Config{}
+	return true
+}()
+
+// newTestTable starts an in-memory table and returns a client using it.
+func newTestTable(t *testing.T) (*dbtest.Server, db.DBClient) {
+	server := dbtest.NewServer()
+	t.Cleanup(server.Close)
+	return server, db.DBClient{
+		TableName: aws.String(dbtest.TableName),
+		Session:   server.Session(),
+	}
+}
+
+// telegramRecorder answers every Bot API call, as the bot @GptBot, and
+// records the messages the handlers send.
+type telegramRecorder struct {
+	mutex    sync.Mutex
+	messages []telegram.SendMessageRequest
+}
+
+// useTelegram points the telegram service to a recorder for the test.
+func useTelegram(t *testing.T) *telegramRecorder {
+	recorder := &telegramRecorder{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/sendMessage") {
+			var request telegram.SendMessageRequest
+			json.NewDecoder(r.Body).Decode(&request)
+
+			recorder.mutex.Lock()
+			recorder.messages = append(recorder.messages, request)
+			recorder.mutex.Unlock()
+		}
+		if strings.HasSuffix(r.URL.Path, "/getMe") {
+			w.Write([]byte(`{"ok":true,"result":{"id":42,"is_bot":true,"first_name":"GPT","username":"GptBot"}}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"result":{"message_id":1}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	previous := telegramService
+	telegramService = &telegram.Telegram{Client: telegram.NewClient(server.URL)}
+	t.Cleanup(func() { telegramService = previous })
+	return recorder
+}
+
+// texts returns the texts sent since the last call.
+func (r *telegramRecorder) texts() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var texts []string
+	for _, message := range r.messages {
+		texts = append(texts, message.Text)
+	}
+	r.messages = nil
+	return texts
+}
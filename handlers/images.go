@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/marlosl/gpt-telegram-bot/services/chatgpt"
+	"github.com/marlosl/gpt-telegram-bot/services/moderation"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// handleEditImageToTelegram handles /editimage and /variation. The source
+// image is the photo the command replies to, or the photo sent with the
+// command as caption. When replying, an image sent with the command is used
+// as the edit mask.
+func handleEditImageToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+	cmd telegram.Command,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+
+	imageFileId, maskFileId := editImageSources(msg.Message)
+	if imageFileId == "" {
+		telegramService.SendMessage(ctx, fmt.Sprintf("Reply to a photo with %s to use it", cmd), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	text, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+	prompt, options, err := chatgpt.ParseImageOptions(*text)
+	if err == nil && cmd == telegram.EditImageCommand && prompt == "" {
+		err = fmt.Errorf("the prompt is empty")
+	}
+	if err != nil {
+		telegramService.SendMessage(ctx, fmt.Sprintf("%v\n%s", err, imageUsage(cmd)), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	if prompt != "" && !moderate(ctx, msg.Message, moderation.Images, prompt) {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	stopAction := telegramService.StartChatAction(ctx, chatId, telegram.UploadPhotoAction)
+	defer stopAction()
+
+	image, mask, err := downloadEditImages(ctx, imageFileId, maskFileId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error downloading images", "error", err)
+		telegramService.SendMessage(ctx, fmt.Sprintf("Error while reading the photo: %v", err), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	var response *chatgpt.CreateImageResponse
+	if cmd == telegram.VariationCommand {
+		response, err = chatGPT.CreateImageVariation(ctx, image, options)
+	} else {
+		response, err = chatGPT.EditImage(ctx, prompt, image, mask, options)
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Error editing image", "error", err)
+		telegramService.SendMessage(ctx, fmt.Sprintf("Error while creating image: %v", err), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	return deliverImages(ctx, response, msg.Message, prompt)
+}
+
+func editImageSources(msg *telegram.Message) (string, string) {
+	if msg.ReplyToMessage != nil {
+		if imageFileId := msg.ReplyToMessage.ImageFileId(); imageFileId != "" {
+			return imageFileId, msg.ImageFileId()
+		}
+	}
+	return msg.ImageFileId(), ""
+}
+
+func downloadEditImages(ctx context.Context, imageFileId string, maskFileId string) ([]byte, []byte, error) {
+	content, err := downloadTelegramFile(ctx, imageFileId, maxPhotoSize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	edge, err := chatgpt.PngEdge(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	image, err := chatgpt.PreparePng(content, edge)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if maskFileId == "" {
+		return image, nil, nil
+	}
+
+	content, err = downloadTelegramFile(ctx, maskFileId, maxPhotoSize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mask, err := chatgpt.PreparePng(content, edge)
+	if err != nil {
+		return nil, nil, err
+	}
+	return image, mask, nil
+}
+
+func imageUsage(cmd telegram.Command) string {
+	if cmd == telegram.VariationCommand {
+		return "Usage: reply to a photo with /variation [--n 2] [--size 1024x1024]"
+	}
+	return "Usage: reply to a photo with /editimage <prompt> [--n 2] [--size 1024x1024], optionally sending a PNG mask with it"
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/services/chatgpt"
+	"github.com/marlosl/gpt-telegram-bot/services/moderation"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	inlineMinQueryLength    = 3
+	inlineMaxTokens         = 256
+	inlineDescriptionLength = 100
+	inlineDebounce          = 700 * time.Millisecond
+	inlineAnswerResultId    = "answer"
+	inlineImageResultId     = "image"
+)
+
+// handleInlineQuery answers "@bot <prompt>" typed in any chat with a short
+// completion and a result that generates an image when chosen. Telegram
+// sends a query on every keystroke, so a query is only sent to the model
+// when no newer one arrived from the same user during the debounce time.
+func handleInlineQuery(ctx context.Context, query *telegram.InlineQuery) (events.APIGatewayProxyResponse, error) {
+	prompt := strings.TrimSpace(query.Query)
+	if len([]rune(prompt)) < inlineMinQueryLength || query.From == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	userId := fmt.Sprintf("%d", query.From.ID)
+	answer := cachedInlineAnswer(ctx, userId, prompt)
+	if answer == "" {
+		if !debounceInlineQuery(ctx, userId, query.ID) {
+			slog.DebugContext(ctx, "Skipping superseded inline query", "query_id", query.ID)
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+			}, nil
+		}
+
+		decision := checkModeration(ctx, userId, query.From, moderation.Prompts, prompt)
+		if decision.Action == moderation.Block {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+			}, nil
+		}
+		answer = completeInlineQuery(ctx, userId, prompt)
+	}
+
+	telegramService.AnswerInlineQuery(ctx, query.ID, inlineResults(prompt, answer))
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+func cachedInlineAnswer(ctx context.Context, userId string, prompt string) string {
+	if inlineRepository == nil {
+		return ""
+	}
+
+	result, err := inlineRepository.GetResult(userId, prompt)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading inline result", "error", err)
+		return ""
+	}
+	if result == nil {
+		return ""
+	}
+	return result.Content
+}
+
+// debounceInlineQuery waits for the user to stop typing and reports whether
+// the query is still the last one the user sent.
+func debounceInlineQuery(ctx context.Context, userId string, queryId string) bool {
+	if inlineRepository == nil {
+		return true
+	}
+
+	err := inlineRepository.MarkLatestQuery(userId, queryId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving inline query", "error", err)
+		return true
+	}
+
+	timer := time.NewTimer(inlineDebounce)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+	}
+
+	latest, err := inlineRepository.LatestQuery(userId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading inline query", "error", err)
+		return true
+	}
+	return latest == queryId
+}
+
+func completeInlineQuery(ctx context.Context, userId string, prompt string) string {
+	response, err := chatGPT.Complete(ctx, prompt, inlineMaxTokens)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error answering inline query", "error", err)
+		return ""
+	}
+	if len(response.Choices) == 0 {
+		return ""
+	}
+
+	answer := strings.TrimSpace(response.Choices[0].Message.Content)
+	if checkModeration(ctx, userId, nil, moderation.Outputs, answer).Action == moderation.Block {
+		return ""
+	}
+	if inlineRepository != nil && answer != "" {
+		err = inlineRepository.SaveResult(userId, prompt, answer)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error saving inline result", "error", err)
+		}
+	}
+	return answer
+}
+
+// inlineResults builds the answer result, when there is an answer, and the
+// image result. The image result carries a keyboard so Telegram reports the
+// inline message id of the sent message, which the image replaces later.
+func inlineResults(prompt string, answer string) []telegram.InlineQueryResultArticle {
+	var results []telegram.InlineQueryResultArticle
+	if answer != "" {
+		results = append(results, telegram.InlineQueryResultArticle{
+			Type:        "article",
+			ID:          inlineAnswerResultId,
+			Title:       "Answer",
+			Description: shorten(answer, inlineDescriptionLength),
+			InputMessageContent: telegram.InputTextMessageContent{
+				MessageText: fmt.Sprintf("%s\n\n%s", prompt, answer),
+			},
+		})
+	}
+
+	query := prompt
+	results = append(results, telegram.InlineQueryResultArticle{
+		Type:        "article",
+		ID:          inlineImageResultId,
+		Title:       "Generate image",
+		Description: shorten(prompt, inlineDescriptionLength),
+		InputMessageContent: telegram.InputTextMessageContent{
+			MessageText: "Generating image: " + prompt,
+		},
+		ReplyMarkup: &telegram.InlineKeyboard{
+			Buttons: [][]telegram.InlineKeyboardButton{
+				{
+					{
+						Text:                         "Try another prompt",
+						SwitchInlineQueryCurrentChat: &query,
+					},
+				},
+			},
+		},
+	})
+	return results
+}
+
+// handleChosenInlineResult generates the image of a chosen image result and
+// puts it in place of the placeholder message. Telegram only reports chosen
+// results when inline feedback is enabled for the bot in @BotFather.
+func handleChosenInlineResult(ctx context.Context, result *telegram.ChosenInlineResult) (events.APIGatewayProxyResponse, error) {
+	if result.ResultId != inlineImageResultId || result.InlineMessageId == "" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	prompt, options, err := chatgpt.ParseImageOptions(result.Query)
+	if err != nil {
+		telegramService.EditInlineMessage(ctx, result.InlineMessageId, err.Error())
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	response, err := chatGPT.CreateImage(ctx, prompt, options)
+	if err == nil && (response == nil || len(response.Data) == 0) {
+		err = fmt.Errorf("no images were created")
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error creating inline image", "error", err)
+		telegramService.EditInlineMessage(ctx, result.InlineMessageId, fmt.Sprintf("Error while creating image: %v", err))
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	userId := ""
+	if result.From != nil {
+		userId = fmt.Sprintf("%d", result.From.ID)
+	}
+
+	urls, err := archiveImages(ctx, response, userId, userId, prompt)
+	if err == nil && len(urls) == 0 {
+		err = fmt.Errorf("no images were stored")
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error archiving images", "error", err)
+		telegramService.EditInlineMessage(ctx, result.InlineMessageId, fmt.Sprintf("Error while storing images: %v", err))
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	telegramService.EditInlinePhoto(ctx, result.InlineMessageId, urls[0], prompt)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+func shorten(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length-1]) + "…"
+}
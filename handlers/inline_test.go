@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/clients/db/dbtest"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useInlineRepository(t *testing.T) *dbtest.Server {
+	server, client := newTestTable(t)
+	previous := inlineRepository
+	inlineRepository = &db.InlineRepository{DBClient: client}
+	t.Cleanup(func() { inlineRepository = previous })
+	return server
+}
+
+func TestCachedInlineAnswer(t *testing.T) {
+	useInlineRepository(t)
+	require.NoError(t, inlineRepository.SaveResult("1", "capital of france", "Paris"))
+
+	tests := []struct {
+		name   string
+		userId string
+		prompt string
+		want   string
+	}{
+		{"cached", "1", "capital of france", "Paris"},
+		{"other prompt", "1", "capital of spain", ""},
+		{"other user", "2", "capital of france", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, cachedInlineAnswer(context.Background(), tt.userId, tt.prompt))
+		})
+	}
+}
+
+func TestCachedInlineAnswerExpired(t *testing.T) {
+	server := useInlineRepository(t)
+	require.NoError(t, inlineRepository.SaveResult("1", "capital of france", "Paris"))
+	for _, item := range server.Items() {
+		item["ExpiresAt"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10))}
+		server.Put(item)
+	}
+
+	assert.Equal(t, "", cachedInlineAnswer(context.Background(), "1", "capital of france"))
+}
+
+func TestCachedInlineAnswerWithoutRepository(t *testing.T) {
+	previous := inlineRepository
+	inlineRepository = nil
+	t.Cleanup(func() { inlineRepository = previous })
+
+	assert.Equal(t, "", cachedInlineAnswer(context.Background(), "1", "capital of france"))
+	assert.True(t, debounceInlineQuery(context.Background(), "1", "q1"))
+}
+
+func TestDebounceInlineQuery(t *testing.T) {
+	useInlineRepository(t)
+
+	// The user types three queries in a row and another user one, only the
+	// last query of each user is answered.
+	queries := []struct {
+		userId  string
+		queryId string
+		delay   time.Duration
+		want    bool
+	}{
+		{"1", "q1", 0, false},
+		{"1", "q2", 100 * time.Millisecond, false},
+		{"2", "q3", 150 * time.Millisecond, true},
+		{"1", "q4", 200 * time.Millisecond, true},
+	}
+
+	results := make([]bool, len(queries))
+	var wg sync.WaitGroup
+	for i, query := range queries {
+		wg.Add(1)
+		go func(i int, userId string, queryId string, delay time.Duration) {
+			defer wg.Done()
+			time.Sleep(delay)
+			results[i] = debounceInlineQuery(context.Background(), userId, queryId)
+		}(i, query.userId, query.queryId, query.delay)
+	}
+	wg.Wait()
+
+	for i, query := range queries {
+		assert.Equal(t, query.want, results[i], query.queryId)
+	}
+}
+
+func TestDebounceInlineQueryCancelled(t *testing.T) {
+	useInlineRepository(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.False(t, debounceInlineQuery(ctx, "1", "q1"))
+}
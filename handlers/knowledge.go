@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/marlosl/gpt-telegram-bot/services/chatgpt"
+	"github.com/marlosl/gpt-telegram-bot/services/knowledge"
+	"github.com/marlosl/gpt-telegram-bot/services/moderation"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	knowledgeMatches = 5
+	knowledgePrompt  = "Answer the question using only the knowledge base excerpts below. " +
+		"Cite the excerpts you use as [1], [2] and so on. " +
+		"If the excerpts do not contain the answer, say that the knowledge base does not cover it.\n\n"
+)
+
+// handleKnowledgeToTelegram answers /kb <question> with the knowledge base
+// chunks closest to the question, listing the sources of the excerpts.
+func handleKnowledgeToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+	cmd telegram.Command,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+
+	question, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+	if *question == "" {
+		telegramService.SendMessage(ctx, "Usage: /kb <question>", chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	if knowledgeStore == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "knowledgeStore is not initialized",
+		}, nil
+	}
+
+	stopAction := telegramService.StartChatAction(ctx, chatId, telegram.TypingAction)
+	defer stopAction()
+
+	matches, err := knowledge.Retrieve(ctx, embedder, knowledgeStore, *question, knowledgeMatches)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error searching the knowledge base", "error", err)
+		telegramService.SendMessage(ctx, fmt.Sprintf("Error while searching the knowledge base: %v", err), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	if len(matches) == 0 {
+		telegramService.SendMessage(ctx, "The knowledge base is empty", chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	response, err := chatGPT.Converse(ctx, []chatgpt.ChatMessage{
+		{
+			Role:    "system",
+			Content: knowledgePrompt + knowledgeExcerpts(matches),
+		},
+		{
+			Role:    "user",
+			Content: *question,
+		},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error talking to ChatGPT", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	if len(response.Choices) == 0 {
+		telegramService.SendMessage(ctx, "No Chat GPT response", chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusNotFound,
+		}, nil
+	}
+
+	if !moderate(ctx, &telegram.Message{Chat: msg.Message.Chat}, moderation.Outputs, response.Choices[0].Message.Content) {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	answer := response.Choices[0].Message.Content + "\n\n" + knowledgeSources(matches)
+	telegramService.SendMessage(ctx, answer, chatId, false)
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+func knowledgeExcerpts(matches []knowledge.Match) string {
+	excerpts := make([]string, len(matches))
+	for i, match := range matches {
+		excerpts[i] = fmt.Sprintf("[%d] %s\n%s", i+1, match.Source, match.Content)
+	}
+	return strings.Join(excerpts, "\n\n")
+}
+
+func knowledgeSources(matches []knowledge.Match) string {
+	lines := []string{"Sources:"}
+	for i, match := range matches {
+		lines = append(lines, fmt.Sprintf("[%d] %s (part %d)", i+1, match.Source, match.Index+1))
+	}
+	return strings.Join(lines, "\n")
+}
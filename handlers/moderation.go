@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/services/moderation"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+	"github.com/marlosl/gpt-telegram-bot/utils/logger"
+	"github.com/marlosl/gpt-telegram-bot/utils/metrics"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	moderationAuditLimit = 10
+	maxModerationExcerpt = 200
+	moderationWarning    = "This was flagged for %s and recorded for the administrators"
+)
+
+// moderatedCommands are the commands whose text is sent to the model as a
+// prompt. Image prompts are checked when the image is created.
+var moderatedCommands = map[telegram.Command]bool{
+	telegram.None:             true,
+	telegram.EditCommand:      true,
+	telegram.SpeakCommand:     true,
+	telegram.KnowledgeCommand: true,
+	telegram.ScheduleCommand:  true,
+}
+
+// moderate checks the text of the message for the stage, refusing it when
+// the policy blocks it and warning the chat when the policy asks to. It
+// reports whether the text may go on.
+func moderate(ctx context.Context, msg *telegram.Message, stage moderation.Stage, text string) bool {
+	chatId := fmt.Sprintf("%d", msg.Chat.ID)
+	decision := checkModeration(ctx, chatId, msg.From, stage, text)
+
+	switch decision.Action {
+	case moderation.Block:
+		telegramService.SendMessage(ctx, moderationGate.Refusal, chatId, false)
+		return false
+	case moderation.Warn:
+		telegramService.SendMessage(ctx, fmt.Sprintf(moderationWarning, strings.Join(decision.Categories, ", ")), chatId, false)
+	}
+	return true
+}
+
+// checkModeration moderates the text and keeps an audit record of the
+// flagged ones that are not allowed by the policy. When the moderation
+// service fails the text is allowed, so an outage does not stop the bot.
+func checkModeration(ctx context.Context, chatId string, from *telegram.From, stage moderation.Stage, text string) *moderation.Decision {
+	decision, err := moderationGate.Check(ctx, stage, text)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error moderating text, allowing it", "stage", stage, "error", err)
+		metrics.Increment("ModerationFailures", metrics.Dimensions{"Stage": string(stage)})
+		return decision
+	}
+	if !decision.Flagged() {
+		return decision
+	}
+
+	slog.WarnContext(ctx, "Text flagged by moderation", "stage", stage, "action", decision.Action, "categories", decision.Categories)
+	metrics.Increment("ModerationFlagged", metrics.Dimensions{"Stage": string(stage), "Action": string(decision.Action)})
+	if decision.Action == moderation.Allow || moderationRepository == nil {
+		return decision
+	}
+
+	record := &db.ModerationRecord{
+		ChatId:     chatId,
+		Stage:      string(stage),
+		Action:     string(decision.Action),
+		Categories: decision.Categories,
+		Excerpt:    moderationExcerpt(text),
+	}
+	if from != nil {
+		record.UserId = fmt.Sprintf("%d", from.ID)
+		record.UserName = speakerName(from)
+	}
+	err = moderationRepository.AddRecord(record)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving moderation record", "error", err)
+	}
+	return decision
+}
+
+func moderationExcerpt(text string) string {
+	runes := []rune(strings.Join(strings.Fields(logger.Redact(text)), " "))
+	if len(runes) > maxModerationExcerpt {
+		return string(runes[:maxModerationExcerpt-1]) + "…"
+	}
+	return string(runes)
+}
+
+// handleModerationToTelegram answers /moderation with the last texts the
+// moderation blocked, warned about or logged in the chat. In groups only
+// administrators can see them.
+func handleModerationToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+	if moderationGate == nil {
+		telegramService.SendMessage(ctx, "Moderation is disabled", chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+	if moderationRepository == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "moderationRepository is not initialized",
+		}, nil
+	}
+
+	if msg.Message.Chat.IsGroup() {
+		isAdmin := false
+		if msg.Message.From != nil {
+			var err error
+			isAdmin, err = telegramService.IsChatAdmin(ctx, chatId, msg.Message.From.ID)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error checking chat administrator", "error", err)
+			}
+		}
+		if !isAdmin {
+			telegramService.SendMessage(ctx, "Only group administrators can see the moderation records", chatId, false)
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+			}, nil
+		}
+	}
+
+	records, err := moderationRepository.ListRecords(chatId, moderationAuditLimit)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing moderation records", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	telegramService.SendMessage(ctx, describeModeration(ctx, chatId, records), chatId, false)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+func describeModeration(ctx context.Context, chatId string, records []db.ModerationRecord) string {
+	stages := make([]string, len(moderationGate.Stages))
+	for i, stage := range moderationGate.Stages {
+		stages[i] = string(stage)
+	}
+	header := "Moderated stages: " + strings.Join(stages, ", ")
+	if len(records) == 0 {
+		return header + "\nNothing was flagged in this chat"
+	}
+
+	location := chatLocation(ctx, chatId)
+	lines := []string{header, "Last flagged texts:"}
+	for _, record := range records {
+		name := record.UserName
+		if name == "" {
+			name = "Assistant"
+		}
+		lines = append(lines, fmt.Sprintf("%s · %s · %s %s for %s\n%s",
+			time.Unix(0, record.CreatedAt).In(location).Format(scheduleLayout),
+			name,
+			record.Stage,
+			record.Action,
+			strings.Join(record.Categories, ", "),
+			record.Excerpt))
+	}
+	return strings.Join(lines, "\n\n")
+}
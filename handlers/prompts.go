@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/services/moderation"
+	"github.com/marlosl/gpt-telegram-bot/services/prompts"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	maxSavedPrompts      = 50
+	promptButtonsPerRow  = 2
+	promptCallbackPrefix = "prompt:"
+	teamFlag             = "--team"
+	saveUsage            = "Usage: /save [--team] <name> <template>\n" +
+		"{{input}} in the template is replaced by the input of /p, --team shares the prompt with the group"
+	promptUsage  = "Usage: /p <name> <input>, or reply to a message with /p <name> to use it as the input"
+	promptsUsage = "Usage: /prompts [delete [--team] <name>]"
+)
+
+// handleSavePromptToTelegram answers /save [--team] <name> <template>. Team
+// prompts are shared by the group, and only their author or an
+// administrator can replace them.
+func handleSavePromptToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+	cmd telegram.Command,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+	if promptRepository == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "promptRepository is not initialized",
+		}, nil
+	}
+	if msg.Message.From == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	text, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+	team, rest := cutTeamFlag(*text)
+	name, template, _ := strings.Cut(rest, " ")
+	template = strings.TrimSpace(template)
+	if template == "" && msg.Message.ReplyToMessage != nil {
+		template = quotedContent(ctx, msg.Message.ReplyToMessage)
+	}
+
+	scope, ownerId := promptOwner(msg.Message, team)
+	err := savePrompt(ctx, msg.Message, scope, ownerId, name, template)
+	if err != nil {
+		telegramService.SendMessage(ctx, fmt.Sprintf("%v\n%s", err, saveUsage), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	name, _ = prompts.NormalizeName(name)
+	telegramService.SendMessage(ctx, fmt.Sprintf("Saved the %s prompt %s, run it with /p %s <input>", scope, name, name), chatId, false)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+func savePrompt(ctx context.Context, msg *telegram.Message, scope string, ownerId string, name string, template string) error {
+	name, err := prompts.NormalizeName(name)
+	if err != nil {
+		return err
+	}
+	err = prompts.ValidateTemplate(template)
+	if err != nil {
+		return err
+	}
+
+	existing, err := promptRepository.GetPrompt(scope, ownerId, name)
+	if err != nil {
+		return err
+	}
+	userId := fmt.Sprintf("%d", msg.From.ID)
+	if existing == nil {
+		saved, err := promptRepository.ListPrompts(scope, ownerId)
+		if err != nil {
+			return err
+		}
+		if len(saved) >= maxSavedPrompts {
+			return fmt.Errorf("there are already %d saved prompts, remove one with /prompts delete <name>", maxSavedPrompts)
+		}
+		existing = &db.PromptTemplate{
+			Scope:     scope,
+			OwnerId:   ownerId,
+			Name:      name,
+			CreatedBy: userId,
+		}
+	} else if !canChangePrompt(ctx, msg, existing) {
+		return fmt.Errorf("the team prompt %s was saved by someone else, only administrators can replace it", name)
+	}
+
+	existing.Template = template
+	return promptRepository.SavePrompt(existing)
+}
+
+// canChangePrompt reports whether the user may replace or remove the
+// prompt. Personal prompts always belong to the user.
+func canChangePrompt(ctx context.Context, msg *telegram.Message, prompt *db.PromptTemplate) bool {
+	if prompt.Scope != db.TeamPrompt || prompt.CreatedBy == fmt.Sprintf("%d", msg.From.ID) {
+		return true
+	}
+
+	isAdmin, err := telegramService.IsChatAdmin(ctx, prompt.OwnerId, msg.From.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error checking chat administrator", "error", err)
+	}
+	return isAdmin
+}
+
+// promptOwner returns where a prompt is saved: the team of a group when
+// asked for, otherwise the user.
+func promptOwner(msg *telegram.Message, team bool) (string, string) {
+	if team && msg.Chat.IsGroup() {
+		return db.TeamPrompt, fmt.Sprintf("%d", msg.Chat.ID)
+	}
+	return db.PersonalPrompt, fmt.Sprintf("%d", msg.From.ID)
+}
+
+func cutTeamFlag(text string) (bool, string) {
+	text = strings.TrimSpace(text)
+	if rest, ok := strings.CutPrefix(text, teamFlag); ok && (rest == "" || rest[0] == ' ') {
+		return true, strings.TrimSpace(rest)
+	}
+	return false, text
+}
+
+// findPrompt returns the prompt by its name, preferring the personal
+// prompts of the user to the ones of the group.
+func findPrompt(msg *telegram.Message, name string) (*db.PromptTemplate, error) {
+	name, err := prompts.NormalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	prompt, err := promptRepository.GetPrompt(db.PersonalPrompt, fmt.Sprintf("%d", msg.From.ID), name)
+	if err != nil || prompt != nil || !msg.Chat.IsGroup() {
+		return prompt, err
+	}
+	return promptRepository.GetPrompt(db.TeamPrompt, fmt.Sprintf("%d", msg.Chat.ID), name)
+}
+
+// handleRunPromptToTelegram answers /p <name> <input>, sending the template
+// filled with the input as a regular message. Replying to a message uses
+// its content as the input.
+func handleRunPromptToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+	cmd telegram.Command,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+	if promptRepository == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "promptRepository is not initialized",
+		}, nil
+	}
+	if msg.Message.From == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	text, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+	name, input, _ := strings.Cut(*text, " ")
+	if name == "" {
+		telegramService.SendMessage(ctx, promptUsage, chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	prompt, err := findPrompt(msg.Message, name)
+	if err == nil && prompt == nil {
+		err = fmt.Errorf("there is no saved prompt %s, see /prompts", name)
+	}
+	if err != nil {
+		telegramService.SendMessage(ctx, fmt.Sprintf("%v\n%s", err, promptUsage), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	reply := msg.Message.ReplyToMessage
+	if strings.TrimSpace(input) == "" && reply != nil {
+		input = quotedContent(ctx, reply)
+		msg.Message.ReplyToMessage = nil
+	}
+
+	msg.Message.Text = prompts.Render(prompt.Template, input)
+	if !moderate(ctx, msg.Message, moderation.Prompts, msg.Message.Text) {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+	return handleTalkToChatTelegram(ctx, req, msg, telegram.None)
+}
+
+// handlePromptsToTelegram answers /prompts with the saved prompts as
+// buttons showing their templates, and /prompts delete <name>.
+func handlePromptsToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+	cmd telegram.Command,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+	if promptRepository == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "promptRepository is not initialized",
+		}, nil
+	}
+	if msg.Message.From == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	text, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+	args := strings.Fields(*text)
+	if len(args) > 0 {
+		if !strings.EqualFold(args[0], "delete") {
+			telegramService.SendMessage(ctx, promptsUsage, chatId, false)
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+			}, nil
+		}
+		return deletePrompt(ctx, msg.Message, strings.Join(args[1:], " "))
+	}
+
+	var saved []db.PromptTemplate
+	scopes := []bool{false}
+	if msg.Message.Chat.IsGroup() {
+		scopes = append(scopes, true)
+	}
+	for _, team := range scopes {
+		scope, ownerId := promptOwner(msg.Message, team)
+		list, err := promptRepository.ListPrompts(scope, ownerId)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error listing prompts", "error", err)
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       err.Error(),
+			}, nil
+		}
+		saved = append(saved, list...)
+	}
+
+	if len(saved) == 0 {
+		telegramService.SendMessage(ctx, "There are no saved prompts\n"+saveUsage, chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	var buttons [][]telegram.InlineKeyboardButton
+	for i, prompt := range saved {
+		if i%promptButtonsPerRow == 0 {
+			buttons = append(buttons, nil)
+		}
+		label := prompt.Name
+		if prompt.Scope == db.TeamPrompt {
+			label += " (team)"
+		}
+		buttons[len(buttons)-1] = append(buttons[len(buttons)-1], telegram.InlineKeyboardButton{
+			Text:         label,
+			CallbackData: promptCallbackPrefix + prompt.Scope + ":" + prompt.Name,
+		})
+	}
+
+	telegramService.SendRepliedMessage(ctx, "Saved prompts, choose one to see its template:", chatId, &telegram.InlineKeyboard{Buttons: buttons})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+func deletePrompt(ctx context.Context, msg *telegram.Message, arg string) (events.APIGatewayProxyResponse, error) {
+	chatId := fmt.Sprintf("%d", msg.Chat.ID)
+	team, name := cutTeamFlag(arg)
+	scope, ownerId := promptOwner(msg, team)
+
+	prompt, err := promptRepository.GetPrompt(scope, ownerId, strings.ToLower(name))
+	if err == nil && prompt == nil {
+		telegramService.SendMessage(ctx, fmt.Sprintf("There is no saved %s prompt %s\n%s", scope, name, promptsUsage), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+	if err == nil && !canChangePrompt(ctx, msg, prompt) {
+		telegramService.SendMessage(ctx, "Only administrators can remove team prompts saved by someone else", chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+	if err == nil {
+		err = promptRepository.DeletePrompt(scope, ownerId, prompt.Name)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error deleting prompt", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	telegramService.SendMessage(ctx, "Removed the prompt "+prompt.Name, chatId, false)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+// handlePromptCallbackQuery answers the buttons of /prompts with the
+// template of the chosen prompt.
+func handlePromptCallbackQuery(ctx context.Context, query *telegram.CallbackQuery) {
+	chatId := fmt.Sprintf("%d", query.Message.Chat.ID)
+	scope, name, _ := strings.Cut(strings.TrimPrefix(query.Data, promptCallbackPrefix), ":")
+
+	var prompt *db.PromptTemplate
+	var err error
+	switch {
+	case scope == db.TeamPrompt:
+		prompt, err = promptRepository.GetPrompt(scope, chatId, name)
+	case query.From != nil:
+		prompt, err = promptRepository.GetPrompt(scope, fmt.Sprintf("%d", query.From.ID), name)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading prompt", "error", err)
+		return
+	}
+	if prompt == nil {
+		telegramService.SendMessage(ctx, "This prompt no longer exists", chatId, false)
+		return
+	}
+
+	telegramService.SendMessage(ctx, fmt.Sprintf("%s (%s)\n\n%s\n\nRun it with /p %s <input>", prompt.Name, prompt.Scope, prompt.Template, prompt.Name), chatId, false)
+}
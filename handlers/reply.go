@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/services/chatgpt"
+	"github.com/marlosl/gpt-telegram-bot/services/documents"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+)
+
+const (
+	maxQuotedDocumentSize = 64 * 1024
+	branchHistoryLimit    = 100
+)
+
+// talk answers a plain message, continuing the conversation of the current
+// thread. A reply carries the quoted message as context, and replying to an
+// earlier answer of the bot continues the conversation from that answer
+// instead of the last message. Excerpts of the documents attached to the
+// chat are added when there are any.
+func talk(ctx context.Context, msg *telegram.Message) (*chatgpt.ChatResponse, error) {
+	messages := documentMessages(ctx, msg)
+
+	var thread *db.Thread
+	var history []db.HistoryMessage
+	if historyRepository != nil {
+		var err error
+		thread = currentThread(ctx, fmt.Sprintf("%d", msg.Chat.ID))
+		history, err = threadHistory(ctx, msg, thread, privateHistoryLimit)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, historyMessages(history, false)...)
+	}
+
+	text := msg.Text
+	reply := msg.ReplyToMessage
+	switch {
+	case reply == nil:
+	case isBotMessage(ctx, reply):
+		if len(history) == 0 {
+			messages = append(messages, chatgpt.ChatMessage{
+				Role:    "assistant",
+				Content: quotedContent(ctx, reply),
+			})
+		}
+	default:
+		text = withQuote(text, speakerName(reply.From), quotedContent(ctx, reply))
+	}
+
+	messages = append(messages, chatgpt.ChatMessage{
+		Role:    "user",
+		Content: text,
+	})
+
+	addHistoryMessage(ctx, msg, thread)
+	return converse(ctx, msg, thread, messages)
+}
+
+func isBotMessage(ctx context.Context, msg *telegram.Message) bool {
+	if msg.From == nil || !msg.From.IsBot {
+		return false
+	}
+
+	me, err := telegramService.GetMe(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting the bot user", "error", err)
+		return false
+	}
+	return msg.From.ID == me.ID
+}
+
+// quotedContent returns the text of the replied message: its text or
+// caption and the content of attached text documents.
+func quotedContent(ctx context.Context, reply *telegram.Message) string {
+	var parts []string
+	if reply.Text != "" {
+		parts = append(parts, reply.Text)
+	}
+	if reply.Caption != "" {
+		parts = append(parts, reply.Caption)
+	}
+
+	if document := reply.Document; document != nil {
+		parts = append(parts, quotedDocument(ctx, document))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func quotedDocument(ctx context.Context, document *telegram.Document) string {
+	if !documents.IsText(document.FileName, document.MimeType) {
+		return fmt.Sprintf("[Attached file %s (%s)]", document.FileName, document.MimeType)
+	}
+
+	content, err := downloadTelegramFile(ctx, document.FileId, maxQuotedDocumentSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error downloading quoted document", "error", err)
+		return fmt.Sprintf("[Attached file %s]", document.FileName)
+	}
+	return fmt.Sprintf("Content of %s:\n%s", document.FileName, content)
+}
+
+// withQuote appends the quoted message to the prompt, prefixing its lines
+// with "> " as in an e-mail reply.
+func withQuote(text string, author string, quoted string) string {
+	if quoted == "" {
+		return text
+	}
+
+	lines := strings.Split(quoted, "\n")
+	for i, line := range lines {
+		lines[i] = "> " + line
+	}
+	return fmt.Sprintf("%s\n\nQuoted message from %s:\n%s", text, author, strings.Join(lines, "\n"))
+}
+
+// branchHistory drops the messages after the replied answer, so the
+// conversation continues from it. When the answer is no longer in the
+// history it is used as the only previous message.
+func branchHistory(history []db.HistoryMessage, reply *telegram.Message) []db.HistoryMessage {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "assistant" && history[i].MessageId == reply.MessageId {
+			return history[:i+1]
+		}
+	}
+
+	return []db.HistoryMessage{
+		{
+			Role:    "assistant",
+			Content: reply.Text,
+		},
+	}
+}
+
+// replyImageFileId returns the image of the replied message, when the user
+// asks about a photo by replying to it.
+func replyImageFileId(msg *telegram.Message) string {
+	if msg.ReplyToMessage == nil || msg.ImageFileId() != "" {
+		return ""
+	}
+	return msg.ReplyToMessage.ImageFileId()
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/services/chatgpt"
+	"github.com/marlosl/gpt-telegram-bot/services/moderation"
+	"github.com/marlosl/gpt-telegram-bot/services/scheduler"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+	"github.com/marlosl/gpt-telegram-bot/utils/metrics"
+	"github.com/marlosl/gpt-telegram-bot/utils/tracing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const schedulePrompt = "This prompt runs on a schedule set by the chat and the answer is sent " +
+	"without anyone waiting for it. The current time is %s."
+
+// ScheduleHandler runs the reminders and scheduled prompts that are due.
+// It is triggered every minute by an EventBridge rule.
+func ScheduleHandler(ctx context.Context, event events.CloudWatchEvent) error {
+	tracing.Init("chat-gpt-scheduler-handler")
+	defer tracing.Flush(ctx)
+	defer metrics.Flush()
+
+	if scheduleRepository == nil {
+		return fmt.Errorf("scheduleRepository is not initialized")
+	}
+
+	schedules, err := scheduleRepository.ListDueSchedules(time.Now().Unix())
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing due schedules", "error", err)
+		return err
+	}
+
+	for _, schedule := range schedules {
+		runSchedule(ctx, schedule)
+	}
+	return nil
+}
+
+// runSchedule moves the schedule to its next run, or removes a reminder,
+// before sending anything, so a slow run is not picked up again by the
+// next invocation. A prompt is only moved, and a reminder only removed,
+// when no other invocation did it first, so overlapping invocations do not
+// send them twice.
+func runSchedule(ctx context.Context, schedule db.Schedule) {
+	ctx, span := tracing.Start(ctx, "schedule.run")
+	defer span.End()
+
+	dimensions := metrics.Dimensions{"Job": "schedule_" + schedule.Kind}
+	metrics.Increment("JobsProcessed", dimensions)
+
+	var err error
+	if schedule.Kind == db.ReminderSchedule {
+		var taken bool
+		taken, err = scheduleRepository.TakeSchedule(schedule.ChatId, schedule.ScheduleId)
+		if err == nil && !taken {
+			slog.InfoContext(ctx, "Skipping reminder sent by another invocation", "schedule_id", schedule.ScheduleId)
+		} else if err == nil {
+			err = telegramService.SendMessage(ctx, "Reminder: "+schedule.Text, schedule.ChatId, false)
+		}
+	} else {
+		err = runPromptSchedule(ctx, schedule)
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Error running schedule", "schedule_id", schedule.ScheduleId, "chat_id", schedule.ChatId, "error", err)
+		metrics.Increment("JobFailures", dimensions)
+	}
+}
+
+func runPromptSchedule(ctx context.Context, schedule db.Schedule) error {
+	now := time.Now()
+	location := chatLocation(ctx, schedule.ChatId)
+
+	next, err := scheduler.Next(schedule.Cron, now, location)
+	if err != nil {
+		return err
+	}
+	previous := schedule.NextRun
+	schedule.NextRun = next.Unix()
+	advanced, err := scheduleRepository.AdvanceSchedule(&schedule, previous)
+	if err != nil {
+		return err
+	}
+	if !advanced {
+		slog.InfoContext(ctx, "Skipping scheduled prompt run by another invocation", "schedule_id", schedule.ScheduleId)
+		return nil
+	}
+
+	if settingsRepository != nil {
+		if settings, err := settingsRepository.GetSettings(schedule.ChatId); err == nil && inQuietHours(settings, now) {
+			slog.InfoContext(ctx, "Skipping scheduled prompt in quiet hours", "schedule_id", schedule.ScheduleId)
+			return nil
+		}
+	}
+
+	chatId, err := strconv.ParseInt(schedule.ChatId, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	stopAction := telegramService.StartChatAction(ctx, schedule.ChatId, telegram.TypingAction)
+	defer stopAction()
+
+	msg := &telegram.Message{Chat: &telegram.Chat{ID: chatId}}
+	response, err := converse(ctx, msg, nil, []chatgpt.ChatMessage{
+		{
+			Role:    "system",
+			Content: fmt.Sprintf(schedulePrompt, now.In(location).Format(scheduleLayout)),
+		},
+		{
+			Role:    "user",
+			Content: schedule.Text,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(response.Choices) == 0 {
+		return fmt.Errorf("no response to the scheduled prompt")
+	}
+	if !moderate(ctx, msg, moderation.Outputs, response.Choices[0].Message.Content) {
+		return nil
+	}
+	return telegramService.SendMessage(ctx, response.Choices[0].Message.Content, schedule.ChatId, false)
+}
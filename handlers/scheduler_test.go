@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunReminderOnce(t *testing.T) {
+	recorder := useTelegram(t)
+	server, client := newTestTable(t)
+	previous := scheduleRepository
+	scheduleRepository = &db.ScheduleRepository{DBClient: client}
+	t.Cleanup(func() { scheduleRepository = previous })
+
+	reminder := db.Schedule{
+		ScheduleId: "1",
+		ChatId:     "100",
+		Kind:       db.ReminderSchedule,
+		Text:       "call mom",
+		NextRun:    time.Now().Unix(),
+	}
+	require.NoError(t, scheduleRepository.SaveSchedule(&reminder))
+
+	// Overlapping invocations both list the reminder as due.
+	runSchedule(context.Background(), reminder)
+	runSchedule(context.Background(), reminder)
+
+	assert.Equal(t, []string{"Reminder: call mom"}, recorder.texts())
+	assert.Empty(t, server.Items())
+}
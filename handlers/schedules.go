@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/services/scheduler"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	maxChatSchedules = 10
+	remindUsage      = "Usage: /remind <when> <text>\n" +
+		"when is a delay (45m, 2h30m, 3d), a time (18:30), tomorrow 09:00 or 2026-12-24 20:00\n" +
+		"Times use the chat time zone, set it with /settings tz <time zone>"
+	scheduleUsage = "Usage: /schedule <cron> <prompt>\n" +
+		"e.g. /schedule 0 8 * * 1-5 Summarize the main news of the day\n" +
+		"/schedule @daily Suggest a word of the day\n" +
+		"The cron uses the chat time zone, set it with /settings tz <time zone>, " +
+		"or start the cron with CRON_TZ=<time zone> to use another one"
+	schedulesUsage = "Usage: /schedules [cancel <number>|all]"
+	scheduleLayout = "2006-01-02 15:04 MST"
+)
+
+// handleRemindToTelegram answers /remind <when> <text>, sending the text
+// back to the chat when it is due.
+func handleRemindToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+	cmd telegram.Command,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+
+	text, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+	if *text == "" {
+		telegramService.SendMessage(ctx, remindUsage, chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	location := chatLocation(ctx, chatId)
+	due, reminder, err := scheduler.ParseWhen(*text, time.Now(), location)
+	if err != nil {
+		telegramService.SendMessage(ctx, fmt.Sprintf("%v\n%s", err, remindUsage), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	return saveSchedule(ctx, &db.Schedule{
+		ChatId:  chatId,
+		Kind:    db.ReminderSchedule,
+		Text:    reminder,
+		NextRun: due.Unix(),
+	}, location)
+}
+
+// handleScheduleToTelegram answers /schedule <cron> <prompt>, sending the
+// answer to the prompt every time the cron expression fires.
+func handleScheduleToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+	cmd telegram.Command,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+
+	text, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+	if *text == "" {
+		telegramService.SendMessage(ctx, scheduleUsage, chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	spec, prompt, err := scheduler.ParseCron(*text)
+	if err != nil {
+		telegramService.SendMessage(ctx, fmt.Sprintf("%v\n%s", err, scheduleUsage), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	location := chatLocation(ctx, chatId)
+	next, err := scheduler.Next(spec, time.Now(), location)
+	if err != nil {
+		telegramService.SendMessage(ctx, fmt.Sprintf("%v\n%s", err, scheduleUsage), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	return saveSchedule(ctx, &db.Schedule{
+		ChatId:  chatId,
+		Kind:    db.PromptSchedule,
+		Text:    prompt,
+		Cron:    spec,
+		NextRun: next.Unix(),
+	}, location)
+}
+
+func saveSchedule(ctx context.Context, schedule *db.Schedule, location *time.Location) (events.APIGatewayProxyResponse, error) {
+	if scheduleRepository == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "scheduleRepository is not initialized",
+		}, nil
+	}
+
+	schedules, err := scheduleRepository.ListSchedules(schedule.ChatId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing schedules", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	if len(schedules) >= maxChatSchedules {
+		telegramService.SendMessage(ctx, fmt.Sprintf("The chat already has %d schedules, cancel one with /schedules cancel <number>", len(schedules)), schedule.ChatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	now := time.Now()
+	schedule.ScheduleId = strconv.FormatInt(now.UnixNano(), 36)
+	schedule.CreatedAt = now.Unix()
+
+	err = scheduleRepository.SaveSchedule(schedule)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving schedule", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	next := time.Unix(schedule.NextRun, 0).In(location).Format(scheduleLayout)
+	if schedule.Kind == db.ReminderSchedule {
+		telegramService.SendMessage(ctx, "Reminder set for "+next, schedule.ChatId, false)
+	} else {
+		telegramService.SendMessage(ctx, "Prompt scheduled, the first run is at "+next, schedule.ChatId, false)
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+// handleSchedulesToTelegram lists the reminders and scheduled prompts of
+// the chat, and cancels them with /schedules cancel <number>|all.
+func handleSchedulesToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+	cmd telegram.Command,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+	if scheduleRepository == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "scheduleRepository is not initialized",
+		}, nil
+	}
+
+	schedules, err := scheduleRepository.ListSchedules(chatId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing schedules", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	text, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+	args := strings.Fields(*text)
+	if len(args) == 0 {
+		telegramService.SendMessage(ctx, describeSchedules(schedules, chatLocation(ctx, chatId)), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	if len(args) != 2 || !strings.EqualFold(args[0], "cancel") {
+		telegramService.SendMessage(ctx, schedulesUsage, chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	selected := schedules
+	if !strings.EqualFold(args[1], "all") {
+		number, err := strconv.Atoi(args[1])
+		if err != nil || number < 1 || number > len(schedules) {
+			telegramService.SendMessage(ctx, fmt.Sprintf("No schedule number %s\n%s", args[1], schedulesUsage), chatId, false)
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+			}, nil
+		}
+		selected = schedules[number-1 : number]
+	}
+
+	cancelled := 0
+	for _, schedule := range selected {
+		err = scheduleRepository.DeleteSchedule(chatId, schedule.ScheduleId)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error deleting schedule", "error", err)
+			telegramService.SendMessage(ctx, fmt.Sprintf("Error while cancelling %s: %v", shorten(schedule.Text, 40), err), chatId, false)
+			continue
+		}
+		cancelled++
+	}
+
+	telegramService.SendMessage(ctx, fmt.Sprintf("Cancelled %d of %d schedules", cancelled, len(selected)), chatId, false)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+func describeSchedules(schedules []db.Schedule, location *time.Location) string {
+	if len(schedules) == 0 {
+		return "No reminders or scheduled prompts\n\n" + remindUsage + "\n\n" + scheduleUsage
+	}
+
+	lines := []string{"Schedules:"}
+	for i, schedule := range schedules {
+		next := time.Unix(schedule.NextRun, 0).In(location).Format(scheduleLayout)
+		if schedule.Kind == db.ReminderSchedule {
+			lines = append(lines, fmt.Sprintf("%d. Reminder at %s: %s", i+1, next, shorten(schedule.Text, 80)))
+		} else {
+			lines = append(lines, fmt.Sprintf("%d. %s, next at %s: %s", i+1, schedule.Cron, next, shorten(schedule.Text, 80)))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n\n" + schedulesUsage
+}
+
+// chatLocation returns the time zone set with /settings tz, or UTC.
+func chatLocation(ctx context.Context, chatId string) *time.Location {
+	if settingsRepository == nil {
+		return time.UTC
+	}
+
+	settings, err := settingsRepository.GetSettings(chatId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading chat settings", "error", err)
+		return time.UTC
+	}
+
+	location, err := time.LoadLocation(settings.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
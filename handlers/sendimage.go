@@ -4,10 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 
+	"github.com/marlosl/gpt-telegram-bot/consts"
 	"github.com/marlosl/gpt-telegram-bot/services/telegram"
-	"github.com/marlosl/gpt-telegram-bot/utils"
 	"github.com/marlosl/gpt-telegram-bot/utils/config"
+	"github.com/marlosl/gpt-telegram-bot/utils/logger"
+	"github.com/marlosl/gpt-telegram-bot/utils/metrics"
+	"github.com/marlosl/gpt-telegram-bot/utils/tracing"
 
 	"github.com/aws/aws-lambda-go/events"
 )
@@ -18,30 +22,76 @@ type SQSMessage struct {
 
 func SendImageHandler(ctx context.Context, sqsEvent events.SQSEvent) error {
 	config.NewConfig(config.SSM)
+	tracing.Init("chat-gpt-send-image-handler")
+	defer tracing.Flush(ctx)
+	defer metrics.Flush()
+
 	telegramService := telegram.NewTextService()
 	for _, message := range sqsEvent.Records {
-		fmt.Printf("The message %s for event source %s = %s \n", message.MessageId, message.EventSource, message.Body)
+		sendImageMessage(messageContext(ctx, message), telegramService, message)
+	}
 
-		var imgMsg telegram.ImageMessage
-		err := json.Unmarshal([]byte(message.Body), &imgMsg)
-		if err != nil {
-			fmt.Printf("Can't unmarshal sqsMessage: %v \n", err)
-			continue
-		}
+	return nil
+}
 
-		fmt.Printf("Unmarshal signal: %s \n", utils.SPrintJson(imgMsg))
+// messageContext returns a context carrying the correlation id the webhook
+// attached to the queued message.
+func messageContext(ctx context.Context, message events.SQSMessage) context.Context {
+	if attribute, ok := message.MessageAttributes[consts.CorrelationIdAttribute]; ok && attribute.StringValue != nil {
+		return logger.WithCorrelationId(ctx, *attribute.StringValue)
+	}
+	return ctx
+}
 
-		if imgMsg.ImageUrl == "" {
-			fmt.Println("ImageUrl is empty")
-			continue
-		}
+func sendImageMessage(ctx context.Context, telegramService *telegram.Telegram, message events.SQSMessage) {
+	ctx, span := tracing.Start(ctx, "sqs.send_image")
+	defer span.End()
+
+	slog.InfoContext(ctx, "Received message", "message_id", message.MessageId, "event_source", message.EventSource)
 
-		err = telegramService.SendPhotoGet(imgMsg.ImageUrl, imgMsg.ChatId)
+	var imgMsg telegram.ImageMessage
+	err := json.Unmarshal([]byte(message.Body), &imgMsg)
+	if err != nil {
+		slog.ErrorContext(ctx, "Can't unmarshal sqsMessage", "message_id", message.MessageId, "error", err)
+		metrics.Increment("JobFailures", metrics.Dimensions{"Job": "send_image"})
+		return
+	}
+
+	urls := imgMsg.Urls()
+	if len(urls) == 0 {
+		slog.WarnContext(ctx, "ImageUrls is empty", "message_id", message.MessageId)
+		return
+	}
+
+	stopAction := telegramService.StartChatAction(ctx, imgMsg.ChatId, telegram.UploadPhotoAction)
+	defer stopAction()
+
+	err = telegramService.SendMediaGroup(ctx, urls, imgMsg.ChatId, imgMsg.Caption)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending album, sending images one by one", "error", err)
+		err = sendPhotosOneByOne(ctx, telegramService, urls, imgMsg)
+	}
+
+	dimensions := metrics.Dimensions{"Job": "send_image"}
+	metrics.Increment("JobsProcessed", dimensions)
+	if err != nil {
+		metrics.Increment("JobFailures", dimensions)
+		telegramService.SendMessage(ctx, fmt.Sprintf("Error while sending image: %v\n", err), imgMsg.ChatId, true)
+	}
+	slog.InfoContext(ctx, "Images sent", "chat_id", imgMsg.ChatId, "count", len(urls))
+}
+
+func sendPhotosOneByOne(ctx context.Context, telegramService *telegram.Telegram, urls []string, imgMsg telegram.ImageMessage) error {
+	var lastErr error
+	caption := imgMsg.Caption
+	for _, url := range urls {
+		err := telegramService.SendPhotoByUrl(ctx, url, imgMsg.ChatId, caption)
 		if err != nil {
-			telegramService.SendMessage(fmt.Sprintf("Error while sending image: %v\n", err), imgMsg.ChatId, true)
+			slog.ErrorContext(ctx, "Error sending image", "error", err)
+			lastErr = err
+			continue
 		}
-		fmt.Println("Image sent")
+		caption = ""
 	}
-
-	return nil
+	return lastErr
 }
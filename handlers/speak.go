@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/services/speech"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	VoiceModeOff  = "off"
+	VoiceModeBoth = "both"
+	VoiceModeOnly = "only"
+)
+
+var synthesizer speech.Synthesizer
+
+func handleVoiceModeToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+	cmd telegram.Command,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+	text, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+	mode := strings.ToLower(*text)
+
+	settings, err := settingsRepository.GetSettings(chatId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading chat settings", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	switch mode {
+	case "":
+		current := settings.VoiceMode
+		if current == "" {
+			current = VoiceModeOff
+		}
+		telegramService.SendMessage(ctx, fmt.Sprintf("Voice mode is %s. Use /voicemode off|both|only to change it.", current), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	case VoiceModeOff, VoiceModeBoth, VoiceModeOnly:
+	default:
+		telegramService.SendMessage(ctx, "Usage: /voicemode off|both|only", chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	settings.VoiceMode = mode
+	err = settingsRepository.UpdateSettings(settings, db.VoiceModeSetting)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving chat settings", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	telegramService.SendMessage(ctx, fmt.Sprintf("Voice mode set to %s", mode), chatId, false)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+// getVoiceMode returns how replies are delivered to the chat. The /speak
+// command always answers with a voice note regardless of the chat setting.
+func getVoiceMode(chatId string, cmd telegram.Command) string {
+	if cmd == telegram.SpeakCommand {
+		return VoiceModeOnly
+	}
+
+	settings, err := settingsRepository.GetSettings(chatId)
+	if err != nil || settings.VoiceMode == "" {
+		return VoiceModeOff
+	}
+	return settings.VoiceMode
+}
+
+// replyAction is the chat action shown while the answer is prepared.
+func replyAction(voiceMode string) telegram.ChatAction {
+	if voiceMode == VoiceModeOnly {
+		return telegram.RecordVoiceAction
+	}
+	return telegram.TypingAction
+}
+
+// sendReply delivers the answer according to the voice mode. It returns the
+// text message that was sent, if any, so it can be referenced later.
+func sendReply(ctx context.Context, text string, chatId string, voiceMode string) *telegram.Message {
+	var sent *telegram.Message
+	if voiceMode == VoiceModeOff || voiceMode == VoiceModeBoth {
+		sent, _ = telegramService.SendTextMessage(ctx, text, chatId, true)
+	}
+
+	if voiceMode == VoiceModeOff {
+		return sent
+	}
+
+	err := sendVoiceReply(ctx, text, chatId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending voice reply", "error", err)
+		if voiceMode == VoiceModeOnly {
+			sent, _ = telegramService.SendTextMessage(ctx, text, chatId, true)
+		}
+	}
+	return sent
+}
+
+func sendVoiceReply(ctx context.Context, text string, chatId string) error {
+	if synthesizer == nil {
+		synthesizer = speech.NewSynthesizer()
+	}
+
+	audio, format, err := synthesizer.Synthesize(ctx, text)
+	if err != nil {
+		return err
+	}
+
+	voice, err := speech.EncodeOggOpus(audio, format)
+	if err != nil {
+		return err
+	}
+
+	return telegramService.SendVoice(ctx, bytes.NewReader(voice), chatId, "")
+}
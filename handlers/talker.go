@@ -1,16 +1,24 @@
 package handlers
 
 import (
-	"fmt"
+	"context"
+	"log/slog"
 	"net/http"
+	"strings"
 
-	"github.com/marlosl/gpt-telegram-bot/utils"
+	"github.com/marlosl/gpt-telegram-bot/consts"
+	"github.com/marlosl/gpt-telegram-bot/utils/logger"
+	"github.com/marlosl/gpt-telegram-bot/utils/metrics"
+	"github.com/marlosl/gpt-telegram-bot/utils/tracing"
 
 	"github.com/aws/aws-lambda-go/events"
 )
 
-func Router(req events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
-	fmt.Printf("Request: %s", utils.SPrintJson(req))
+func Router(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	defer tracing.Flush(ctx)
+	defer metrics.Flush()
+
+	slog.InfoContext(ctx, "Request", "method", req.RequestContext.HTTP.Method, "path", req.RawPath, "headers", redactHeaders(req.Headers))
 	if req.RequestContext.HTTP.Method == "GET" {
 
 		if req.RawPath == "/ping" {
@@ -19,10 +27,10 @@ func Router(req events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse,
 	}
 	if req.RequestContext.HTTP.Method == "POST" {
 		if req.RawPath == "/gpt" {
-			return handleTalkToChatGPT(req)
+			return handleTalkToChatGPT(ctx, req)
 		}
 		if req.RawPath == "/telegram-bot" {
-			return handleCommandChatTelegram(req)
+			return handleCommandChatTelegram(ctx, req)
 		}
 	}
 
@@ -31,3 +39,16 @@ func Router(req events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse,
 		Body:       http.StatusText(http.StatusMethodNotAllowed),
 	}, nil
 }
+
+// redactHeaders returns a copy of the request headers without the webhook
+// secret, so requests can be logged safely.
+func redactHeaders(headers map[string]string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for key, value := range headers {
+		if strings.EqualFold(key, consts.TelegramWebhookTokenHeader) || strings.EqualFold(key, "authorization") {
+			value = logger.Redacted
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
@@ -0,0 +1,609 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/services/chatgpt"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	privateHistoryLimit  = 20
+	threadPageSize       = 8
+	maxThreadSearch      = 10
+	maxThreadTitle       = 60
+	threadTitleMaxTokens = 20
+	threadCallbackPrefix = "thread:"
+	threadTitlePrompt    = "Write a title of at most six words for a conversation that starts with the messages below. " +
+		"Answer only with the title, without quotes.\n\nUser: %s\n\nAssistant: %s"
+	switchUsage = "Usage: /switch <number>|<title>, the numbers are shown by /history"
+	renameUsage = "Usage: /rename <title>"
+	deleteUsage = "Usage: /delete [number|title], without arguments the current conversation is deleted"
+)
+
+// activeThread returns the thread the chat is talking in, or nil when it
+// has none, e.g. after the thread was deleted or expired.
+func activeThread(ctx context.Context, chatId string) (*db.Thread, error) {
+	if threadRepository == nil || settingsRepository == nil {
+		return nil, nil
+	}
+
+	settings, err := settingsRepository.GetSettings(chatId)
+	if err != nil || settings.ActiveThread == "" {
+		return nil, err
+	}
+
+	thread, err := threadRepository.GetThread(chatId, settings.ActiveThread)
+	if err != nil || thread == nil || thread.ExpiresAt < time.Now().Unix() {
+		return nil, err
+	}
+	return thread, nil
+}
+
+// currentThread returns the active thread of the chat, starting a new one
+// when there is none. It returns nil when threads are not available, and
+// the chat is then answered without history.
+func currentThread(ctx context.Context, chatId string) *db.Thread {
+	thread, err := activeThread(ctx, chatId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading the active thread", "error", err)
+		return nil
+	}
+	if thread != nil || threadRepository == nil {
+		return thread
+	}
+
+	thread, err = startThread(chatId, "")
+	if err != nil {
+		slog.ErrorContext(ctx, "Error starting a thread", "error", err)
+		return nil
+	}
+	return thread
+}
+
+// startThread creates a thread and makes it the active one of the chat.
+func startThread(chatId string, title string) (*db.Thread, error) {
+	thread, err := threadRepository.CreateThread(chatId, title)
+	if err != nil {
+		return nil, err
+	}
+	return thread, setActiveThread(chatId, thread.ThreadId)
+}
+
+func setActiveThread(chatId string, threadId string) error {
+	return settingsRepository.UpdateSettings(&db.ChatSettings{
+		ChatId:       chatId,
+		ActiveThread: threadId,
+	}, db.ActiveThreadSetting)
+}
+
+func threadId(thread *db.Thread) string {
+	if thread == nil {
+		return ""
+	}
+	return thread.ThreadId
+}
+
+// threadHistory returns the last messages of the thread, or none without a
+// thread. Replying to an earlier answer of the bot continues the
+// conversation from that answer.
+func threadHistory(ctx context.Context, msg *telegram.Message, thread *db.Thread, limit int) ([]db.HistoryMessage, error) {
+	if thread == nil {
+		return nil, nil
+	}
+
+	reply := msg.ReplyToMessage
+	branch := reply != nil && isBotMessage(ctx, reply)
+
+	fetch := int64(limit)
+	if branch {
+		fetch = branchHistoryLimit
+	}
+
+	history, err := historyRepository.ListMessages(fmt.Sprintf("%d", msg.Chat.ID), thread.ThreadId, fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	if branch {
+		history = branchHistory(history, reply)
+	}
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	return history, nil
+}
+
+// addHistoryMessage records the message in the thread. Without a thread
+// the message is not kept.
+func addHistoryMessage(ctx context.Context, msg *telegram.Message, thread *db.Thread) {
+	if historyRepository == nil || thread == nil {
+		return
+	}
+
+	err := historyRepository.AddMessage(&db.HistoryMessage{
+		ChatId:    fmt.Sprintf("%d", msg.Chat.ID),
+		ThreadId:  thread.ThreadId,
+		MessageId: msg.MessageId,
+		Role:      "user",
+		Name:      speakerName(msg.From),
+		Content:   msg.Text,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving message", "error", err)
+	}
+}
+
+// addHistoryAnswer records the answer of the bot, with the id of the
+// message that delivered it so later replies can branch from it, and keeps
+// the thread alive. A thread without a title is named after its first
+// exchange.
+func addHistoryAnswer(ctx context.Context, msg *telegram.Message, content string, sent *telegram.Message) {
+	if historyRepository == nil {
+		return
+	}
+
+	chatId := fmt.Sprintf("%d", msg.Chat.ID)
+	thread := currentThread(ctx, chatId)
+	if thread == nil {
+		return
+	}
+
+	answer := &db.HistoryMessage{
+		ChatId:   chatId,
+		ThreadId: thread.ThreadId,
+		Role:     "assistant",
+		Content:  content,
+	}
+	if sent != nil {
+		answer.MessageId = sent.MessageId
+	}
+
+	err := historyRepository.AddMessage(answer)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving answer", "error", err)
+	}
+
+	if thread.Title == "" {
+		thread.Title = threadTitle(ctx, msg.Text, content)
+	}
+	thread.UpdatedAt = time.Now().Unix()
+	err = threadRepository.SaveThread(thread)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving thread", "error", err)
+	}
+}
+
+// threadTitle asks for a short title for the exchange, falling back to the
+// beginning of the message.
+func threadTitle(ctx context.Context, question string, answer string) string {
+	response, err := chatGPT.Complete(ctx, fmt.Sprintf(threadTitlePrompt, question, answer), threadTitleMaxTokens)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error generating the thread title", "error", err)
+	}
+
+	title := question
+	if err == nil && len(response.Choices) > 0 {
+		title = strings.Trim(strings.TrimSpace(response.Choices[0].Message.Content), "\"'.")
+	}
+	return truncateTitle(title)
+}
+
+func truncateTitle(title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	runes := []rune(title)
+	if len(runes) > maxThreadTitle {
+		return strings.TrimSpace(string(runes[:maxThreadTitle-1])) + "…"
+	}
+	return title
+}
+
+func displayTitle(thread db.Thread) string {
+	if thread.Title == "" {
+		return "Untitled"
+	}
+	return thread.Title
+}
+
+// handleNewThreadToTelegram answers /new [title], starting a conversation
+// without the previous messages.
+func handleNewThreadToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+	cmd telegram.Command,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+	if threadRepository == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "threadRepository is not initialized",
+		}, nil
+	}
+
+	text, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+	title := truncateTitle(*text)
+
+	_, err := startThread(chatId, title)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error starting a thread", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	reply := "Started a new conversation"
+	if title != "" {
+		reply += ": " + title
+	}
+	telegramService.SendMessage(ctx, reply, chatId, false)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+// handleThreadHistoryToTelegram answers /history with the recent threads of
+// the chat as buttons that switch to them.
+func handleThreadHistoryToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+	if threadRepository == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "threadRepository is not initialized",
+		}, nil
+	}
+
+	text, keyboard, err := threadPage(ctx, chatId, 0, "")
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing threads", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	if keyboard == nil {
+		telegramService.SendMessage(ctx, text, chatId, false)
+	} else {
+		telegramService.SendRepliedMessage(ctx, text, chatId, keyboard)
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+// threadPage describes a page of threads, numbered from offset+1, with a
+// button to switch to each of them and one for the next page.
+func threadPage(ctx context.Context, chatId string, offset int, after string) (string, *telegram.InlineKeyboard, error) {
+	threads, next, err := threadRepository.ListThreads(chatId, threadPageSize, after)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(threads) == 0 && offset == 0 {
+		return "There are no stored conversations, send a message or /new to start one", nil, nil
+	}
+
+	active, err := activeThread(ctx, chatId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading the active thread", "error", err)
+	}
+
+	var lines []string
+	var buttons [][]telegram.InlineKeyboardButton
+	for i, thread := range threads {
+		marker := ""
+		if active != nil && active.ThreadId == thread.ThreadId {
+			marker = " (current)"
+		}
+		updated := time.Unix(thread.UpdatedAt, 0).In(chatLocation(ctx, chatId)).Format(scheduleLayout)
+		lines = append(lines, fmt.Sprintf("%d. %s%s, %s", offset+i+1, displayTitle(thread), marker, updated))
+		buttons = append(buttons, []telegram.InlineKeyboardButton{
+			{
+				Text:         fmt.Sprintf("%d. %s", offset+i+1, displayTitle(thread)),
+				CallbackData: threadCallbackPrefix + "switch:" + thread.ThreadId,
+			},
+		})
+	}
+	if next != "" {
+		buttons = append(buttons, []telegram.InlineKeyboardButton{
+			{
+				Text:         "More",
+				CallbackData: fmt.Sprintf("%spage:%d:%s", threadCallbackPrefix, offset+len(threads), next),
+			},
+		})
+	}
+
+	text := "Conversations:\n" + strings.Join(lines, "\n")
+	if len(lines) == 0 {
+		text = "There are no more conversations"
+	}
+	return text, &telegram.InlineKeyboard{Buttons: buttons}, nil
+}
+
+// handleThreadCallbackQuery answers the buttons of /history, switching to
+// the chosen thread or showing the next page.
+func handleThreadCallbackQuery(ctx context.Context, query *telegram.CallbackQuery) {
+	chatId := fmt.Sprintf("%d", query.Message.Chat.ID)
+	action, arg, _ := strings.Cut(strings.TrimPrefix(query.Data, threadCallbackPrefix), ":")
+
+	switch action {
+	case "switch":
+		thread, err := threadRepository.GetThread(chatId, arg)
+		if err == nil && thread != nil {
+			err = switchThread(ctx, chatId, thread)
+		} else if err == nil {
+			telegramService.SendMessage(ctx, "This conversation no longer exists", chatId, false)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Error switching thread", "error", err)
+		}
+	case "page":
+		offsetArg, after, _ := strings.Cut(arg, ":")
+		offset, _ := strconv.Atoi(offsetArg)
+		text, keyboard, err := threadPage(ctx, chatId, offset, after)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error listing threads", "error", err)
+			return
+		}
+		_, err = telegramService.Client.EditMessageText(ctx, &telegram.EditMessageTextRequest{
+			ChatId:      chatId,
+			MessageId:   query.Message.MessageId,
+			Text:        text,
+			ReplyMarkup: keyboard,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error showing the next threads", "error", err)
+		}
+	}
+}
+
+func switchThread(ctx context.Context, chatId string, thread *db.Thread) error {
+	err := setActiveThread(chatId, thread.ThreadId)
+	if err != nil {
+		return err
+	}
+
+	// Switching keeps the thread from expiring while it is in use.
+	err = threadRepository.SaveThread(thread)
+	if err != nil {
+		return err
+	}
+	return telegramService.SendMessage(ctx, "Switched to "+displayTitle(*thread), chatId, false)
+}
+
+// findThread returns the thread by its number in /history or by its title,
+// looking at the most recent threads of the chat.
+func findThread(chatId string, arg string) (*db.Thread, error) {
+	number, err := strconv.Atoi(arg)
+	if err != nil {
+		number = 0
+	}
+
+	after := ""
+	position := 0
+	for page := 0; page < maxThreadSearch; page++ {
+		threads, next, err := threadRepository.ListThreads(chatId, threadPageSize, after)
+		if err != nil {
+			return nil, err
+		}
+		for _, thread := range threads {
+			position++
+			if position == number || (number == 0 && strings.EqualFold(thread.Title, arg)) {
+				return &thread, nil
+			}
+		}
+		if next == "" {
+			break
+		}
+		after = next
+	}
+	return nil, nil
+}
+
+// handleSwitchThreadToTelegram answers /switch <number>|<title>, resuming
+// an earlier thread.
+func handleSwitchThreadToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+	cmd telegram.Command,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+	if threadRepository == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "threadRepository is not initialized",
+		}, nil
+	}
+
+	text, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+	if *text == "" {
+		telegramService.SendMessage(ctx, switchUsage, chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	thread, err := findThread(chatId, *text)
+	if err == nil && thread == nil {
+		telegramService.SendMessage(ctx, fmt.Sprintf("No conversation matches %s\n%s", *text, switchUsage), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+	if err == nil {
+		err = switchThread(ctx, chatId, thread)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error switching thread", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+// handleRenameThreadToTelegram answers /rename <title>, renaming the
+// current thread.
+func handleRenameThreadToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+	cmd telegram.Command,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+	if threadRepository == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "threadRepository is not initialized",
+		}, nil
+	}
+
+	text, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+	title := truncateTitle(*text)
+	if title == "" {
+		telegramService.SendMessage(ctx, renameUsage, chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	thread, err := activeThread(ctx, chatId)
+	if err == nil && thread == nil {
+		telegramService.SendMessage(ctx, "There is no conversation to rename, send a message or /new to start one", chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+	if err == nil {
+		thread.Title = title
+		err = threadRepository.SaveThread(thread)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error renaming thread", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	telegramService.SendMessage(ctx, "Renamed the conversation to "+title, chatId, false)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+// handleDeleteThreadToTelegram answers /delete [number|title], removing a
+// thread and its messages. Without arguments the current thread is
+// removed, and the next message starts a new one.
+func handleDeleteThreadToTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+	cmd telegram.Command,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	chatId := fmt.Sprintf("%d", msg.Message.Chat.ID)
+	if threadRepository == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "threadRepository is not initialized",
+		}, nil
+	}
+
+	text, _ := telegram.ParseMessage(cmd, &msg.Message.Text)
+
+	active, err := activeThread(ctx, chatId)
+	thread := active
+	if err == nil && *text != "" {
+		thread, err = findThread(chatId, *text)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading thread", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	if thread == nil {
+		reply := "There is no conversation to delete"
+		if *text != "" {
+			reply = fmt.Sprintf("No conversation matches %s\n%s", *text, deleteUsage)
+		}
+		telegramService.SendMessage(ctx, reply, chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	if historyRepository != nil {
+		err = historyRepository.DeleteMessages(chatId, thread.ThreadId)
+	}
+	if err == nil {
+		err = threadRepository.DeleteThread(chatId, thread.ThreadId)
+	}
+	if err == nil && active != nil && active.ThreadId == thread.ThreadId {
+		err = setActiveThread(chatId, "")
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error deleting thread", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	telegramService.SendMessage(ctx, "Deleted "+displayTitle(*thread), chatId, false)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+// historyMessages converts the stored messages to chat messages. In groups
+// each user message is labelled with the name of who wrote it.
+func historyMessages(history []db.HistoryMessage, named bool) []chatgpt.ChatMessage {
+	var messages []chatgpt.ChatMessage
+	for _, message := range history {
+		content := message.Content
+		if named && message.Role == "user" {
+			content = message.Name + ": " + content
+		}
+		messages = append(messages, chatgpt.ChatMessage{
+			Role:    message.Role,
+			Content: content,
+		})
+	}
+	return messages
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/services/chatgpt"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+	"github.com/marlosl/gpt-telegram-bot/services/tools"
+)
+
+// converse sends the conversation to the model, letting it call the tools
+// allowed in the chat. Without tools a single message goes through Talk,
+// which keeps its handling of stop sequences. The tools only see the
+// messages of the thread, if any.
+func converse(ctx context.Context, msg *telegram.Message, thread *db.Thread, messages []chatgpt.ChatMessage) (*chatgpt.ChatResponse, error) {
+	chatId := fmt.Sprintf("%d", msg.Chat.ID)
+
+	allowed, enabled := chatTools(ctx, chatId)
+	if enabled && toolRegistry != nil {
+		ctx = tools.WithThreadId(tools.WithChatId(ctx, chatId), threadId(thread))
+		return chatGPT.ConverseWithTools(ctx, messages, toolRegistry, allowed)
+	}
+
+	if len(messages) == 1 {
+		return chatGPT.Talk(ctx, messages[0].Content)
+	}
+	return chatGPT.Converse(ctx, messages)
+}
+
+// chatTools returns the tools allowed in the chat, nil meaning all of them,
+// and whether tools are enabled at all.
+func chatTools(ctx context.Context, chatId string) ([]string, bool) {
+	if settingsRepository == nil {
+		return nil, true
+	}
+
+	settings, err := settingsRepository.GetSettings(chatId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading chat settings", "error", err)
+		return nil, true
+	}
+	return settings.AllowedTools, !settings.ToolsDisabled
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/marlosl/gpt-telegram-bot/services/moderation"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	defaultVisionPrompt = "Describe this image."
+	maxPhotoSize        = 20 * 1024 * 1024
+)
+
+func isPhotoMessage(msg *telegram.Message) bool {
+	return msg != nil && len(msg.Photo) > 0
+}
+
+func handlePhotoToChatTelegram(
+	ctx context.Context,
+	req events.APIGatewayV2HTTPRequest,
+	msg telegram.WebhookMessage,
+) (
+	events.APIGatewayProxyResponse,
+	error,
+) {
+	if !moderate(ctx, msg.Message, moderation.Prompts, msg.Message.Caption) {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+	return answerAboutImage(ctx, msg.Message, msg.Message.LargestPhoto().FileId, msg.Message.Caption)
+}
+
+// answerAboutImage asks the vision model about the image, using the prompt
+// or a default one asking to describe it.
+func answerAboutImage(ctx context.Context, msg *telegram.Message, fileId string, prompt string) (events.APIGatewayProxyResponse, error) {
+	chatId := fmt.Sprintf("%d", msg.Chat.ID)
+
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		prompt = defaultVisionPrompt
+	}
+
+	voiceMode := getVoiceMode(chatId, telegram.None)
+	stopAction := telegramService.StartChatAction(ctx, chatId, replyAction(voiceMode))
+	defer stopAction()
+
+	image, err := downloadTelegramFile(ctx, fileId, maxPhotoSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error downloading photo", "error", err)
+		telegramService.SendMessage(ctx, fmt.Sprintf("Error while reading the photo: %v", err), chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	response, err := chatGPT.TalkWithImage(ctx, prompt, image, http.DetectContentType(image))
+	if err != nil {
+		slog.ErrorContext(ctx, "Error describing image", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	if response == nil || len(response.Choices) == 0 {
+		telegramService.SendMessage(ctx, "No Chat GPT response", chatId, false)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusNotFound,
+		}, nil
+	}
+
+	for _, choice := range response.Choices {
+		if !moderate(ctx, &telegram.Message{Chat: msg.Chat}, moderation.Outputs, choice.Message.Content) {
+			continue
+		}
+		sendReply(ctx, choice.Message.Content, chatId, voiceMode)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+func downloadTelegramFile(ctx context.Context, fileId string, maxSize int64) ([]byte, error) {
+	file, err := telegramService.GetFile(ctx, fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := telegramService.DownloadFile(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(content)) > maxSize {
+		return nil, fmt.Errorf("file is larger than %d bytes", maxSize)
+	}
+	return content, nil
+}
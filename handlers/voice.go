@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/marlosl/gpt-telegram-bot/services/speech"
+	"github.com/marlosl/gpt-telegram-bot/services/telegram"
+	"github.com/marlosl/gpt-telegram-bot/utils/config"
+)
+
+var transcriber speech.Transcriber
+
+func isVoiceMessage(msg *telegram.Message) bool {
+	return msg != nil && (msg.Voice != nil || msg.Audio != nil)
+}
+
+// transcribeVoiceMessage replaces the message text with the transcript of
+// its voice note or audio file, so it can be handled as if it were typed.
+func transcribeVoiceMessage(ctx context.Context, msg *telegram.Message, chatId string) error {
+	fileId, duration, filename := voiceFileInfo(msg)
+
+	maxDuration := config.Store.MaxVoiceDuration
+	if maxDuration > 0 && duration > maxDuration {
+		return fmt.Errorf("audio is too long (%ds), the maximum duration is %ds", duration, maxDuration)
+	}
+
+	file, err := telegramService.GetFile(ctx, fileId)
+	if err != nil {
+		return err
+	}
+
+	if filename == "" {
+		filename = path.Base(file.FilePath)
+	}
+
+	audio, err := telegramService.DownloadFile(ctx, file)
+	if err != nil {
+		return err
+	}
+	defer audio.Close()
+
+	if transcriber == nil {
+		transcriber = speech.NewTranscriber()
+	}
+
+	text, err := transcriber.Transcribe(ctx, filename, audio)
+	if err != nil {
+		return err
+	}
+
+	telegramService.SendMessage(ctx, fmt.Sprintf("🎤 %s", text), chatId, false)
+	msg.Text = text
+	return nil
+}
+
+func voiceFileInfo(msg *telegram.Message) (string, int, string) {
+	if msg.Voice != nil {
+		return msg.Voice.FileId, msg.Voice.Duration, ""
+	}
+	return msg.Audio.FileId, msg.Audio.Duration, msg.Audio.FileName
+}
@@ -1,21 +1,39 @@
 package chatgpt
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/marlosl/gpt-telegram-bot/consts"
 	"github.com/marlosl/gpt-telegram-bot/utils"
 	"github.com/marlosl/gpt-telegram-bot/utils/config"
+	"github.com/marlosl/gpt-telegram-bot/utils/logger"
+	"github.com/marlosl/gpt-telegram-bot/utils/tracing"
 
 	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultVisionModel = "gpt-4o"
+	editModel          = "text-davinci-edit-001"
 )
 
 type ChatGPT struct {
 	ApiKey         string
 	GptModel       string
+	VisionModel    string
 	ChatUrl        string
 	CreateImageUrl string
+	EditImageUrl   string
+	VariationUrl   string
+	ImageModel     string
 	EditUrl        string
 }
 
@@ -23,15 +41,31 @@ func (c *ChatGPT) InitApi() {
 	config.NewConfig(config.SSM)
 	c.ApiKey = config.Store.GptApiKey
 	c.GptModel = config.Store.GptModel
+	c.VisionModel = config.Store.GptVisionModel
+	if c.VisionModel == "" {
+		c.VisionModel = defaultVisionModel
+	}
 	c.ChatUrl = "https://api.openai.com/v1/chat/completions"
 	c.CreateImageUrl = "https://api.openai.com/v1/images/generations"
+	c.EditImageUrl = "https://api.openai.com/v1/images/edits"
+	c.VariationUrl = "https://api.openai.com/v1/images/variations"
+	c.ImageModel = config.Store.ImageModel
+	if c.ImageModel == "" {
+		c.ImageModel = DallE2
+	}
 	c.EditUrl = "https://api.openai.com/v1/edits"
 }
 
-func (c *ChatGPT) CreateRequest() *resty.Request {
+// CreateRequest creates a request bound to ctx. The correlation id carried
+// by ctx is sent as X-Client-Request-Id, so OpenAI logs can be matched to ours.
+func (c *ChatGPT) CreateRequest(ctx context.Context) *resty.Request {
 	client := resty.New()
 	client.SetTimeout(5 * time.Minute)
-	return client.R().
+	req := client.R().SetContext(ctx)
+	if id := logger.CorrelationId(ctx); id != "" {
+		req.SetHeader(consts.CorrelationIdHeader, id)
+	}
+	return req.
 		SetAuthToken(c.ApiKey).
 		SetHeader("accept", "*/*").
 		SetHeader("accept-encoding", "gzip, deflate, br").
@@ -52,35 +86,120 @@ func (c *ChatGPT) isSuccess(r *resty.Response) bool {
 	return r != nil && r.StatusCode() >= 200 && r.StatusCode() <= 299
 }
 
-func (c *ChatGPT) Talk(message string) (*ChatResponse, error) {
-	fmt.Printf("Talk: %s\n", message)
-	resp, err := c.CreateRequest().
+// requestError describes a failed request with its response code and the
+// message of the error body, when there is one.
+func requestError(kind string, resp *resty.Response) error {
+	body := ErrorResponse{}
+	if json.Unmarshal(resp.Body(), &body) == nil && body.Error.Message != "" {
+		return fmt.Errorf("%s request failed with response code: %d: %s", kind, resp.StatusCode(), body.Error.Message)
+	}
+	return fmt.Errorf("%s request failed with response code: %d", kind, resp.StatusCode())
+}
+
+func (c *ChatGPT) Talk(ctx context.Context, message string) (response *ChatResponse, err error) {
+	ctx, span := tracing.Start(ctx, "openai.chat", attribute.String("model", c.GptModel))
+	defer func() { tracing.End(span, err) }()
+	defer recordChatMetrics(c.GptModel, time.Now(), &response, &err)
+
+	slog.DebugContext(ctx, "Talk", "prompt", message)
+	resp, err := c.CreateRequest(ctx).
 		SetResult(ChatResponse{}).
 		SetBody(c.CreateChatRequest(message)).
 		Post(c.ChatUrl)
 
-	utils.PrintRestyDebug(resp, err)
-	if err != nil || !c.isSuccess(resp) {
+	utils.PrintRestyDebug(ctx, resp, err)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.isSuccess(resp) {
+		return nil, requestError("chat", resp)
+	}
+	return resp.Result().(*ChatResponse), nil
+}
+
+// TalkWithImage asks the vision model about an image, using the message as
+// the prompt.
+func (c *ChatGPT) TalkWithImage(ctx context.Context, message string, image []byte, mimeType string) (response *ChatResponse, err error) {
+	ctx, span := tracing.Start(ctx, "openai.vision", attribute.String("model", c.VisionModel))
+	defer func() { tracing.End(span, err) }()
+	defer recordChatMetrics(c.VisionModel, time.Now(), &response, &err)
 
+	resp, err := c.CreateRequest(ctx).
+		SetResult(ChatResponse{}).
+		SetBody(c.CreateVisionRequest(message, image, mimeType)).
+		Post(c.ChatUrl)
+
+	utils.PrintRestyDebug(ctx, resp, err)
+	if err != nil {
 		return nil, err
 	}
+
+	if !c.isSuccess(resp) {
+		return nil, requestError("vision", resp)
+	}
 	return resp.Result().(*ChatResponse), nil
 }
 
-func (c *ChatGPT) Edit(instruction, message string) (*ChatResponse, error) {
-	resp, err := c.CreateRequest().
+func (c *ChatGPT) Edit(ctx context.Context, instruction, message string) (response *ChatResponse, err error) {
+	ctx, span := tracing.Start(ctx, "openai.edit")
+	defer func() { tracing.End(span, err) }()
+	defer recordChatMetrics(editModel, time.Now(), &response, &err)
+
+	resp, err := c.CreateRequest(ctx).
 		SetResult(ChatResponse{}).
 		SetBody(c.CreateEditRequest(instruction, message)).
 		Post(c.ChatUrl)
 
-	utils.PrintRestyDebug(resp, err)
-	if err != nil || !c.isSuccess(resp) {
+	utils.PrintRestyDebug(ctx, resp, err)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.isSuccess(resp) {
+		return nil, requestError("edit", resp)
+	}
+	return resp.Result().(*ChatResponse), nil
+}
+
+// Converse continues a conversation, sending the previous messages along
+// with the last one so the model can answer in context.
+func (c *ChatGPT) Converse(ctx context.Context, messages []ChatMessage) (*ChatResponse, error) {
+	return c.chat(ctx, ChatRequest{
+		Model:    c.GptModel,
+		Messages: messages,
+	})
+}
 
+func (c *ChatGPT) chat(ctx context.Context, request ChatRequest) (response *ChatResponse, err error) {
+	ctx, span := tracing.Start(ctx, "openai.chat", attribute.String("model", request.Model))
+	defer func() { tracing.End(span, err) }()
+	defer recordChatMetrics(request.Model, time.Now(), &response, &err)
+
+	resp, err := c.CreateRequest(ctx).
+		SetResult(ChatResponse{}).
+		SetBody(request).
+		Post(c.ChatUrl)
+
+	utils.PrintRestyDebug(ctx, resp, err)
+	if err != nil {
 		return nil, err
 	}
+
+	if !c.isSuccess(resp) {
+		return nil, requestError("chat", resp)
+	}
 	return resp.Result().(*ChatResponse), nil
 }
 
+// Complete answers the message with at most maxTokens tokens, for places
+// that need a fast and short answer such as inline queries.
+func (c *ChatGPT) Complete(ctx context.Context, message string, maxTokens int) (*ChatResponse, error) {
+	request := c.CreateChatRequest(message)
+	request.MaxTokens = &maxTokens
+	return c.chat(ctx, request)
+}
+
 func (c *ChatGPT) CreateChatRequest(message string) ChatRequest {
 	var stop string
 	var req ChatRequest
@@ -116,38 +235,150 @@ func (c *ChatGPT) CreateChatRequest(message string) ChatRequest {
 		}
 	}
 
-	fmt.Printf("ChatRequest: %s\n", utils.SPrintJson(req))
+	slog.Debug("ChatRequest", "model", req.Model, "messages", len(req.Messages))
 	return req
 }
 
+func (c *ChatGPT) CreateVisionRequest(message string, image []byte, mimeType string) ChatRequest {
+	return ChatRequest{
+		Model: c.VisionModel,
+		Messages: []ChatMessage{
+			{
+				Role: "user",
+				Parts: []ContentPart{
+					NewTextPart(message),
+					NewImagePart(image, mimeType),
+				},
+			},
+		},
+	}
+}
+
 func (c *ChatGPT) CreateEditRequest(instruction, message string) EditRequest {
 	return EditRequest{
-		Model:       "text-davinci-edit-001",
+		Model:       editModel,
 		Input:       message,
 		Instruction: instruction,
 	}
 }
 
-func (c *ChatGPT) CreateImage(message string) (*CreateImageResponse, error) {
-	resp, err := c.CreateRequest().
+func (c *ChatGPT) CreateImage(ctx context.Context, message string, options *ImageOptions) (response *CreateImageResponse, err error) {
+	model, err := c.validateImageOptions(GenerateOperation, options)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := tracing.Start(ctx, "openai.image.generate", attribute.String("model", model))
+	defer func() { tracing.End(span, err) }()
+	defer recordImageMetrics(model, GenerateOperation, options, time.Now(), &response, &err)
+
+	resp, err := c.CreateRequest(ctx).
 		SetResult(CreateImageResponse{}).
-		SetBody(c.CreateImageRequest(message)).
+		SetBody(c.CreateImageRequest(model, message, options)).
 		Post(c.CreateImageUrl)
 
-	utils.PrintRestyDebug(resp, err)
-	if err != nil || !c.isSuccess(resp) {
+	return c.parseImageResponse(ctx, model, resp, err)
+}
+
+func (c *ChatGPT) CreateImageRequest(model string, message string, options *ImageOptions) CreateImageRequest {
+	return CreateImageRequest{
+		Model:   model,
+		Prompt:  message,
+		N:       options.N,
+		Size:    options.Size,
+		Quality: options.Quality,
+		Style:   options.Style,
+	}
+}
+
+// EditImage edits the image following the prompt. The mask is optional for
+// models that can edit without one; its transparent areas mark what to change.
+func (c *ChatGPT) EditImage(ctx context.Context, prompt string, image []byte, mask []byte, options *ImageOptions) (response *CreateImageResponse, err error) {
+	model, err := c.validateImageOptions(EditOperation, options)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, _ := GetImageModelSpec(model)
+	if spec.RequiresMask && len(mask) == 0 {
+		return nil, fmt.Errorf("%s requires a mask: send a PNG file with transparent areas along with the command", model)
+	}
+
+	ctx, span := tracing.Start(ctx, "openai.image.edit", attribute.String("model", model))
+	defer func() { tracing.End(span, err) }()
+	defer recordImageMetrics(model, EditOperation, options, time.Now(), &response, &err)
 
+	req := c.CreateRequest(ctx).
+		SetResult(CreateImageResponse{}).
+		SetFileReader("image", "image.png", bytes.NewReader(image)).
+		SetFormData(map[string]string{
+			"model":  model,
+			"prompt": prompt,
+			"n":      strconv.Itoa(options.N),
+			"size":   options.Size,
+		})
+	if len(mask) > 0 {
+		req.SetFileReader("mask", "mask.png", bytes.NewReader(mask))
+	}
+
+	resp, err := req.Post(c.EditImageUrl)
+	return c.parseImageResponse(ctx, model, resp, err)
+}
+
+func (c *ChatGPT) CreateImageVariation(ctx context.Context, image []byte, options *ImageOptions) (response *CreateImageResponse, err error) {
+	model, err := c.validateImageOptions(VariationOperation, options)
+	if err != nil {
 		return nil, err
 	}
-	return resp.Result().(*CreateImageResponse), nil
+
+	ctx, span := tracing.Start(ctx, "openai.image.variation", attribute.String("model", model))
+	defer func() { tracing.End(span, err) }()
+	defer recordImageMetrics(model, VariationOperation, options, time.Now(), &response, &err)
+
+	resp, err := c.CreateRequest(ctx).
+		SetResult(CreateImageResponse{}).
+		SetFileReader("image", "image.png", bytes.NewReader(image)).
+		SetFormData(map[string]string{
+			"model": model,
+			"n":     strconv.Itoa(options.N),
+			"size":  options.Size,
+		}).
+		Post(c.VariationUrl)
+
+	return c.parseImageResponse(ctx, model, resp, err)
 }
 
-func (c *ChatGPT) CreateImageRequest(message string) CreateImageRequest {
-	return CreateImageRequest{
-		Prompt: message,
-		N:      2,
-		Size:   "1024x1024",
+// imageModelFor returns the configured image model, or DALL-E 2 when the
+// configured one does not support the operation.
+func (c *ChatGPT) imageModelFor(operation ImageOperation) string {
+	spec, err := GetImageModelSpec(c.ImageModel)
+	if err == nil && containsOperation(spec.Operations, operation) {
+		return c.ImageModel
+	}
+	return DallE2
+}
+
+func (c *ChatGPT) validateImageOptions(operation ImageOperation, options *ImageOptions) (string, error) {
+	model := c.imageModelFor(operation)
+	spec, err := GetImageModelSpec(model)
+	if err != nil {
+		return "", err
+	}
+	return model, spec.Validate(model, operation, options)
+}
+
+func (c *ChatGPT) parseImageResponse(ctx context.Context, model string, resp *resty.Response, err error) (*CreateImageResponse, error) {
+	utils.PrintRestyDebug(ctx, resp, err)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.isSuccess(resp) {
+		return nil, requestError("image", resp)
 	}
+	response := resp.Result().(*CreateImageResponse)
+	response.Model = model
+	return response, nil
 }
 
 func NewChatGPT() *ChatGPT {
@@ -0,0 +1,73 @@
+package chatgpt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailedRequestsReturnErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`))
+	}))
+	defer server.Close()
+
+	c := &ChatGPT{GptModel: "gpt-test", VisionModel: "gpt-test", ChatUrl: server.URL}
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() (*ChatResponse, error)
+		want string
+	}{
+		{
+			name: "talk",
+			call: func() (*ChatResponse, error) { return c.Talk(ctx, "hello") },
+			want: "chat request failed with response code: 429: Rate limit reached",
+		},
+		{
+			name: "talk with image",
+			call: func() (*ChatResponse, error) {
+				return c.TalkWithImage(ctx, "what is this?", []byte("image"), "image/png")
+			},
+			want: "vision request failed with response code: 429: Rate limit reached",
+		},
+		{
+			name: "edit",
+			call: func() (*ChatResponse, error) { return c.Edit(ctx, "fix it", "helo") },
+			want: "edit request failed with response code: 429: Rate limit reached",
+		},
+		{
+			name: "converse",
+			call: func() (*ChatResponse, error) {
+				return c.Converse(ctx, []ChatMessage{{Role: "user", Content: "hello"}})
+			},
+			want: "chat request failed with response code: 429: Rate limit reached",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := tt.call()
+			assert.Nil(t, response)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestRequestErrorWithoutBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	c := &ChatGPT{VisionModel: "gpt-test", ChatUrl: server.URL}
+	response, err := c.TalkWithImage(context.Background(), "what is this?", []byte("image"), "image/png")
+	assert.Nil(t, response)
+	assert.EqualError(t, err, "vision request failed with response code: 502")
+}
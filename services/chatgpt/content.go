@@ -0,0 +1,101 @@
+package chatgpt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	TextPart     = "text"
+	ImageUrlPart = "image_url"
+)
+
+type chatMessageJson struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content"`
+	ToolCalls  []ToolCall      `json:"tool_calls,omitempty"`
+	ToolCallId string          `json:"tool_call_id,omitempty"`
+}
+
+// MarshalJSON sends the content as an array of parts when the message is
+// multimodal and as a plain string otherwise.
+func (m ChatMessage) MarshalJSON() ([]byte, error) {
+	var content interface{} = m.Content
+	if len(m.Parts) > 0 {
+		content = m.Parts
+	}
+
+	raw, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(chatMessageJson{
+		Role:       m.Role,
+		Content:    raw,
+		ToolCalls:  m.ToolCalls,
+		ToolCallId: m.ToolCallId,
+	})
+}
+
+func (m *ChatMessage) UnmarshalJSON(data []byte) error {
+	var msg chatMessageJson
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+
+	m.Role = msg.Role
+	m.Content = ""
+	m.Parts = nil
+	m.ToolCalls = msg.ToolCalls
+	m.ToolCallId = msg.ToolCallId
+
+	content := strings.TrimSpace(string(msg.Content))
+	if content == "" || content == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(content, "[") {
+		if err := json.Unmarshal(msg.Content, &m.Parts); err != nil {
+			return err
+		}
+		m.Content = m.Text()
+		return nil
+	}
+	return json.Unmarshal(msg.Content, &m.Content)
+}
+
+// Text returns the textual content of the message, joining the text parts
+// of multimodal messages.
+func (m *ChatMessage) Text() string {
+	if len(m.Parts) == 0 {
+		return m.Content
+	}
+
+	var texts []string
+	for _, part := range m.Parts {
+		if part.Type == TextPart {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func NewTextPart(text string) ContentPart {
+	return ContentPart{
+		Type: TextPart,
+		Text: text,
+	}
+}
+
+// NewImagePart embeds the image as a data URL, so the model never needs to
+// fetch a URL that carries the Telegram bot token.
+func NewImagePart(image []byte, mimeType string) ContentPart {
+	return ContentPart{
+		Type: ImageUrlPart,
+		ImageUrl: &ImageUrl{
+			Url: fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(image)),
+		},
+	}
+}
@@ -0,0 +1,223 @@
+package chatgpt
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+type ImageOperation string
+
+const (
+	GenerateOperation  ImageOperation = "generate"
+	EditOperation      ImageOperation = "edit"
+	VariationOperation ImageOperation = "variation"
+
+	DallE2     = "dall-e-2"
+	DallE3     = "dall-e-3"
+	GptImage1  = "gpt-image-1"
+	defaultN   = 2
+	maxPngEdge = 1024
+)
+
+type ImageOptions struct {
+	N       int
+	Size    string
+	Quality string
+	Style   string
+}
+
+type ImageModelSpec struct {
+	Sizes        []string
+	MaxN         int
+	Qualities    []string
+	Styles       []string
+	Operations   []ImageOperation
+	RequiresMask bool
+}
+
+var ImageModels = map[string]ImageModelSpec{
+	DallE2: {
+		Sizes:        []string{"256x256", "512x512", "1024x1024"},
+		MaxN:         10,
+		Operations:   []ImageOperation{GenerateOperation, EditOperation, VariationOperation},
+		RequiresMask: true,
+	},
+	DallE3: {
+		Sizes:      []string{"1024x1024", "1792x1024", "1024x1792"},
+		MaxN:       1,
+		Qualities:  []string{"standard", "hd"},
+		Styles:     []string{"vivid", "natural"},
+		Operations: []ImageOperation{GenerateOperation},
+	},
+	GptImage1: {
+		Sizes:      []string{"1024x1024", "1536x1024", "1024x1536", "auto"},
+		MaxN:       10,
+		Qualities:  []string{"low", "medium", "high", "auto"},
+		Operations: []ImageOperation{GenerateOperation, EditOperation},
+	},
+}
+
+// ParseImageOptions splits the command text into the prompt and the
+// --n, --size, --quality and --style options, in any position.
+func ParseImageOptions(text string) (string, *ImageOptions, error) {
+	options := &ImageOptions{}
+	var prompt []string
+
+	fields := strings.Fields(text)
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if !strings.HasPrefix(field, "--") {
+			prompt = append(prompt, field)
+			continue
+		}
+
+		if i+1 >= len(fields) {
+			return "", nil, fmt.Errorf("missing value for %s", field)
+		}
+		value := fields[i+1]
+		i++
+
+		switch field {
+		case "--n":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return "", nil, fmt.Errorf("invalid value for --n: %s", value)
+			}
+			options.N = n
+		case "--size":
+			options.Size = value
+		case "--quality":
+			options.Quality = value
+		case "--style":
+			options.Style = value
+		default:
+			return "", nil, fmt.Errorf("unknown option %s", field)
+		}
+	}
+
+	return strings.Join(prompt, " "), options, nil
+}
+
+// Validate checks the options against the model and fills the defaults
+// for the values that were not informed.
+func (s ImageModelSpec) Validate(model string, operation ImageOperation, options *ImageOptions) error {
+	if !containsOperation(s.Operations, operation) {
+		return fmt.Errorf("%s does not support image %s", model, operation)
+	}
+
+	if options.N == 0 {
+		options.N = defaultN
+		if options.N > s.MaxN {
+			options.N = s.MaxN
+		}
+	}
+	if options.N > s.MaxN {
+		return fmt.Errorf("%s allows at most %d images", model, s.MaxN)
+	}
+
+	if options.Size == "" {
+		options.Size = "1024x1024"
+	}
+	if !containsString(s.Sizes, options.Size) {
+		return fmt.Errorf("%s supports the sizes: %s", model, strings.Join(s.Sizes, ", "))
+	}
+
+	if options.Quality != "" && !containsString(s.Qualities, options.Quality) {
+		return fmt.Errorf("%s supports the qualities: %s", model, joinOrNone(s.Qualities))
+	}
+
+	if options.Style != "" && !containsString(s.Styles, options.Style) {
+		return fmt.Errorf("%s supports the styles: %s", model, joinOrNone(s.Styles))
+	}
+	return nil
+}
+
+func GetImageModelSpec(model string) (ImageModelSpec, error) {
+	spec, ok := ImageModels[model]
+	if !ok {
+		return ImageModelSpec{}, fmt.Errorf("unknown image model %s", model)
+	}
+	return spec, nil
+}
+
+// PreparePng center-crops the image to a square no larger than edge pixels
+// and encodes it as PNG, the format required by the edit and variation APIs.
+func PreparePng(content []byte, edge int) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	if edge <= 0 || edge > side {
+		edge = side
+	}
+
+	offsetX := bounds.Min.X + (bounds.Dx()-side)/2
+	offsetY := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	square := image.NewNRGBA(image.Rect(0, 0, edge, edge))
+	for y := 0; y < edge; y++ {
+		for x := 0; x < edge; x++ {
+			square.Set(x, y, img.At(offsetX+x*side/edge, offsetY+y*side/edge))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, square); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PngEdge returns the edge used by PreparePng for the image, so masks can
+// be resized to match it.
+func PngEdge(content []byte) (int, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return 0, err
+	}
+
+	edge := cfg.Width
+	if cfg.Height < edge {
+		edge = cfg.Height
+	}
+	if edge > maxPngEdge {
+		edge = maxPngEdge
+	}
+	return edge, nil
+}
+
+func containsOperation(operations []ImageOperation, operation ImageOperation) bool {
+	for _, o := range operations {
+		if o == operation {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func joinOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
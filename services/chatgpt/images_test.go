@@ -0,0 +1,220 @@
+package chatgpt
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImageOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		wantPrompt  string
+		wantOptions *ImageOptions
+		wantErr     string
+	}{
+		{
+			name:        "prompt only",
+			text:        "a red fox in the snow",
+			wantPrompt:  "a red fox in the snow",
+			wantOptions: &ImageOptions{},
+		},
+		{
+			name:        "options in any position",
+			text:        "--size 512x512 a red fox --n 3 in the snow --quality hd --style natural",
+			wantPrompt:  "a red fox in the snow",
+			wantOptions: &ImageOptions{N: 3, Size: "512x512", Quality: "hd", Style: "natural"},
+		},
+		{
+			name:    "missing value",
+			text:    "a red fox --size",
+			wantErr: "missing value for --size",
+		},
+		{
+			name:    "invalid n",
+			text:    "a red fox --n zero",
+			wantErr: "invalid value for --n: zero",
+		},
+		{
+			name:    "negative n",
+			text:    "a red fox --n -1",
+			wantErr: "invalid value for --n: -1",
+		},
+		{
+			name:    "unknown option",
+			text:    "a red fox --seed 42",
+			wantErr: "unknown option --seed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt, options, err := ParseImageOptions(tt.text)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPrompt, prompt)
+			assert.Equal(t, tt.wantOptions, options)
+		})
+	}
+}
+
+func TestValidateImageOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		model       string
+		operation   ImageOperation
+		options     ImageOptions
+		wantOptions ImageOptions
+		wantErr     string
+	}{
+		{
+			name:        "defaults",
+			model:       DallE2,
+			operation:   GenerateOperation,
+			wantOptions: ImageOptions{N: 2, Size: "1024x1024"},
+		},
+		{
+			name:        "default n limited by the model",
+			model:       DallE3,
+			operation:   GenerateOperation,
+			wantOptions: ImageOptions{N: 1, Size: "1024x1024"},
+		},
+		{
+			name:      "too many images",
+			model:     DallE3,
+			operation: GenerateOperation,
+			options:   ImageOptions{N: 2},
+			wantErr:   "dall-e-3 allows at most 1 images",
+		},
+		{
+			name:      "unsupported operation",
+			model:     DallE3,
+			operation: VariationOperation,
+			wantErr:   "dall-e-3 does not support image variation",
+		},
+		{
+			name:      "unsupported size",
+			model:     DallE2,
+			operation: GenerateOperation,
+			options:   ImageOptions{Size: "1792x1024"},
+			wantErr:   "dall-e-2 supports the sizes: 256x256, 512x512, 1024x1024",
+		},
+		{
+			name:      "model without qualities",
+			model:     DallE2,
+			operation: GenerateOperation,
+			options:   ImageOptions{Quality: "hd"},
+			wantErr:   "dall-e-2 supports the qualities: none",
+		},
+		{
+			name:        "supported style",
+			model:       DallE3,
+			operation:   GenerateOperation,
+			options:     ImageOptions{Style: "vivid", Quality: "hd", Size: "1792x1024"},
+			wantOptions: ImageOptions{N: 1, Style: "vivid", Quality: "hd", Size: "1792x1024"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := GetImageModelSpec(tt.model)
+			require.NoError(t, err)
+
+			options := tt.options
+			err = spec.Validate(tt.model, tt.operation, &options)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantOptions, options)
+		})
+	}
+}
+
+func encodeTestImage(t *testing.T, width int, height int, asJpeg bool) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if asJpeg {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestPreparePng(t *testing.T) {
+	tests := []struct {
+		name     string
+		width    int
+		height   int
+		asJpeg   bool
+		edge     int
+		wantEdge int
+	}{
+		{"square kept", 64, 64, false, 1024, 64},
+		{"landscape cropped", 120, 80, false, 1024, 80},
+		{"portrait cropped", 80, 120, false, 1024, 80},
+		{"downscaled", 200, 200, false, 50, 50},
+		{"jpeg converted", 60, 40, true, 1024, 40},
+		{"no edge", 30, 50, false, 0, 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := encodeTestImage(t, tt.width, tt.height, tt.asJpeg)
+
+			prepared, err := PreparePng(content, tt.edge)
+			require.NoError(t, err)
+
+			img, format, err := image.Decode(bytes.NewReader(prepared))
+			require.NoError(t, err)
+			assert.Equal(t, "png", format)
+			assert.Equal(t, tt.wantEdge, img.Bounds().Dx())
+			assert.Equal(t, tt.wantEdge, img.Bounds().Dy())
+		})
+	}
+}
+
+func TestPreparePngRejectsInvalidImages(t *testing.T) {
+	_, err := PreparePng([]byte("not an image"), 1024)
+	assert.Error(t, err)
+}
+
+func TestPngEdge(t *testing.T) {
+	tests := []struct {
+		name   string
+		width  int
+		height int
+		want   int
+	}{
+		{"smaller side", 300, 200, 200},
+		{"capped", 1200, 1100, maxPngEdge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edge, err := PngEdge(encodeTestImage(t, tt.width, tt.height, false))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, edge)
+		})
+	}
+}
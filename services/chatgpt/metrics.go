@@ -0,0 +1,99 @@
+package chatgpt
+
+import (
+	"strings"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/utils/metrics"
+)
+
+// tokenPrices holds the USD price per million prompt and completion tokens.
+// Models missing from the table are not included in the cost metric.
+var tokenPrices = map[string][2]float64{
+	"gpt-4o":        {2.50, 10.00},
+	"gpt-4o-mini":   {0.15, 0.60},
+	"gpt-4-turbo":   {10.00, 30.00},
+	"gpt-4":         {30.00, 60.00},
+	"gpt-3.5-turbo": {0.50, 1.50},
+}
+
+// imagePrices holds the USD price per image by model, quality and size.
+var imagePrices = map[string]float64{
+	DallE2 + "/256x256":            0.016,
+	DallE2 + "/512x512":            0.018,
+	DallE2 + "/1024x1024":          0.020,
+	DallE3 + "/standard/1024x1024": 0.040,
+	DallE3 + "/standard/1792x1024": 0.080,
+	DallE3 + "/standard/1024x1792": 0.080,
+	DallE3 + "/hd/1024x1024":       0.080,
+	DallE3 + "/hd/1792x1024":       0.120,
+	DallE3 + "/hd/1024x1792":       0.120,
+}
+
+func recordChatMetrics(model string, start time.Time, response **ChatResponse, err *error) {
+	dimensions := metrics.Dimensions{"Model": model}
+	metrics.Since("LLMLatency", start, dimensions)
+
+	if *err != nil || *response == nil {
+		metrics.Increment("LLMErrors", dimensions)
+		return
+	}
+
+	usage := (*response).Usage
+	metrics.Add("LLMPromptTokens", metrics.Count, float64(usage.PromptTokens), dimensions)
+	metrics.Add("LLMCompletionTokens", metrics.Count, float64(usage.CompletionTokens), dimensions)
+
+	if price, ok := tokenPrice(model); ok {
+		cost := (float64(usage.PromptTokens)*price[0] + float64(usage.CompletionTokens)*price[1]) / 1e6
+		metrics.Add("EstimatedCostUSD", metrics.None, cost, dimensions)
+	}
+}
+
+// tokenPrice matches dated snapshots (e.g. gpt-4o-2024-08-06) to the price
+// of their base model.
+func tokenPrice(model string) ([2]float64, bool) {
+	if price, ok := tokenPrices[model]; ok {
+		return price, true
+	}
+
+	base := ""
+	for name := range tokenPrices {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(base) {
+			base = name
+		}
+	}
+	price, ok := tokenPrices[base]
+	return price, ok
+}
+
+func recordImageMetrics(model string, operation ImageOperation, options *ImageOptions, start time.Time, response **CreateImageResponse, err *error) {
+	dimensions := metrics.Dimensions{
+		"Model":     model,
+		"Operation": string(operation),
+	}
+	metrics.Since("ImageLatency", start, dimensions)
+
+	if *err != nil || *response == nil {
+		metrics.Increment("ImageErrors", dimensions)
+		return
+	}
+
+	images := len((*response).Data)
+	metrics.Add("ImagesGenerated", metrics.Count, float64(images), dimensions)
+
+	if price, ok := imagePrices[imagePriceKey(model, options)]; ok {
+		metrics.Add("EstimatedCostUSD", metrics.None, price*float64(images), metrics.Dimensions{"Model": model})
+	}
+}
+
+func imagePriceKey(model string, options *ImageOptions) string {
+	if model != DallE3 {
+		return model + "/" + options.Size
+	}
+
+	quality := options.Quality
+	if quality == "" {
+		quality = "standard"
+	}
+	return model + "/" + quality + "/" + options.Size
+}
@@ -1,8 +1,46 @@
 package chatgpt
 
+import "encoding/json"
+
 type ChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string        `json:"role"`
+	Content    string        `json:"content"`
+	Parts      []ContentPart `json:"-"`
+	ToolCalls  []ToolCall    `json:"-"`
+	ToolCallId string        `json:"-"`
+}
+
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageUrl *ImageUrl `json:"image_url,omitempty"`
+}
+
+type ImageUrl struct {
+	Url    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
 }
 
 type ChatRequest struct {
@@ -12,11 +50,13 @@ type ChatRequest struct {
 	TopP             *int          `json:"top_p,omitempty"`
 	N                *int          `json:"n,omitempty"`
 	Stream           *bool         `json:"stream,omitempty"`
-	Stop             *string     `json:"stop,omitempty"`
+	Stop             *string       `json:"stop,omitempty"`
 	MaxTokens        *int          `json:"max_tokens,omitempty"`
 	PresencePenalty  *float32      `json:"presence_penalty,omitempty"`
 	FrequencyPenalty *float32      `json:"frequency_penalty,omitempty"`
 	User             *string       `json:"user,omitempty"`
+	Tools            []Tool        `json:"tools,omitempty"`
+	ToolChoice       string        `json:"tool_choice,omitempty"`
 }
 
 type EditRequest struct {
@@ -49,16 +89,30 @@ type ChatResponse struct {
 }
 
 type CreateImageRequest struct {
-	Prompt string `json:"prompt"`
-	N      int    `json:"n"`
-	Size   string `json:"size"`
+	Model   string `json:"model,omitempty"`
+	Prompt  string `json:"prompt"`
+	N       int    `json:"n"`
+	Size    string `json:"size"`
+	Quality string `json:"quality,omitempty"`
+	Style   string `json:"style,omitempty"`
 }
 
 type UrlReponse struct {
-	Url string `json:"url"`
+	Url     string `json:"url,omitempty"`
+	B64Json string `json:"b64_json,omitempty"`
 }
 
 type CreateImageResponse struct {
 	Created int64        `json:"created"`
 	Data    []UrlReponse `json:"data"`
+	Model   string       `json:"-"`
+}
+
+// ErrorResponse is the body OpenAI sends along with a failed request.
+type ErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    any    `json:"code"`
+	} `json:"error"`
 }
@@ -0,0 +1,143 @@
+package chatgpt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/marlosl/gpt-telegram-bot/utils/metrics"
+)
+
+// MaxToolIterations bounds the rounds of tool calls of a single answer, so
+// a model that keeps calling tools cannot loop forever.
+const MaxToolIterations = 5
+
+// ToolHandler runs a tool with the JSON arguments chosen by the model and
+// returns the result that is sent back to it.
+type ToolHandler func(ctx context.Context, arguments json.RawMessage) (string, error)
+
+type ToolDefinition struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments object.
+	Parameters json.RawMessage
+	Handler    ToolHandler
+}
+
+type ToolRegistry struct {
+	tools map[string]ToolDefinition
+	names []string
+}
+
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools: map[string]ToolDefinition{},
+	}
+}
+
+func (r *ToolRegistry) Register(tool ToolDefinition) {
+	if _, ok := r.tools[tool.Name]; !ok {
+		r.names = append(r.names, tool.Name)
+	}
+	r.tools[tool.Name] = tool
+}
+
+// Names returns the names of the registered tools in registration order.
+func (r *ToolRegistry) Names() []string {
+	return r.names
+}
+
+// Tools returns the tool descriptions sent to the model, limited to the
+// allowed names. A nil allowlist allows every tool.
+func (r *ToolRegistry) Tools(allowed []string) []Tool {
+	var tools []Tool
+	for _, name := range r.names {
+		if allowed != nil && !containsString(allowed, name) {
+			continue
+		}
+
+		tool := r.tools[name]
+		tools = append(tools, Tool{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return tools
+}
+
+// Call runs the tool requested by the model if it is in the allowed names;
+// a nil allowlist allows every tool. Errors are returned to the model as
+// the result, so it can correct the arguments or answer anyway.
+func (r *ToolRegistry) Call(ctx context.Context, call ToolCall, allowed []string) string {
+	tool, ok := r.tools[call.Function.Name]
+	if !ok {
+		return fmt.Sprintf("error: unknown tool %s", call.Function.Name)
+	}
+	if allowed != nil && !containsString(allowed, tool.Name) {
+		slog.WarnContext(ctx, "Model called a tool that is not allowed", "tool", tool.Name)
+		return "error: tool not allowed"
+	}
+
+	arguments := json.RawMessage(call.Function.Arguments)
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+
+	result, err := tool.Handler(ctx, arguments)
+	metrics.Increment("ToolCalls", metrics.Dimensions{"Tool": tool.Name})
+	if err != nil {
+		slog.WarnContext(ctx, "Tool call failed", "tool", tool.Name, "error", err)
+		return "error: " + err.Error()
+	}
+	return result
+}
+
+// ConverseWithTools continues the conversation letting the model call the
+// allowed tools. The calls are run and their results fed back until the
+// model answers, for at most MaxToolIterations rounds; the last request
+// forbids tools so an answer is always produced.
+func (c *ChatGPT) ConverseWithTools(ctx context.Context, messages []ChatMessage, registry *ToolRegistry, allowed []string) (*ChatResponse, error) {
+	tools := registry.Tools(allowed)
+	if len(tools) == 0 {
+		return c.Converse(ctx, messages)
+	}
+
+	for i := 0; i < MaxToolIterations; i++ {
+		response, err := c.chat(ctx, ChatRequest{
+			Model:    c.GptModel,
+			Messages: messages,
+			Tools:    tools,
+		})
+		if err != nil || len(response.Choices) == 0 {
+			return response, err
+		}
+
+		message := response.Choices[0].Message
+		if len(message.ToolCalls) == 0 {
+			return response, nil
+		}
+
+		messages = append(messages, message)
+		for _, call := range message.ToolCalls {
+			slog.DebugContext(ctx, "Calling tool", "tool", call.Function.Name)
+			messages = append(messages, ChatMessage{
+				Role:       "tool",
+				Content:    registry.Call(ctx, call, allowed),
+				ToolCallId: call.ID,
+			})
+		}
+	}
+
+	slog.WarnContext(ctx, "Tool iterations exhausted", "max", MaxToolIterations)
+	return c.chat(ctx, ChatRequest{
+		Model:      c.GptModel,
+		Messages:   messages,
+		Tools:      tools,
+		ToolChoice: "none",
+	})
+}
@@ -0,0 +1,87 @@
+package chatgpt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testToolRegistry(called *[]string) *ToolRegistry {
+	registry := NewToolRegistry()
+	for _, name := range []string{"time", "memory"} {
+		name := name
+		registry.Register(ToolDefinition{
+			Name:       name,
+			Parameters: json.RawMessage(`{"type":"object"}`),
+			Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+				*called = append(*called, name)
+				return name + " result", nil
+			},
+		})
+	}
+	return registry
+}
+
+func toolCall(name string) ToolCall {
+	return ToolCall{
+		ID:       "call-" + name,
+		Type:     "function",
+		Function: FunctionCall{Name: name, Arguments: "{}"},
+	}
+}
+
+func TestToolRegistryCall(t *testing.T) {
+	var called []string
+	registry := testToolRegistry(&called)
+	ctx := context.Background()
+
+	assert.Equal(t, "time result", registry.Call(ctx, toolCall("time"), nil))
+	assert.Equal(t, "time result", registry.Call(ctx, toolCall("time"), []string{"time"}))
+	assert.Equal(t, "error: tool not allowed", registry.Call(ctx, toolCall("memory"), []string{"time"}))
+	assert.Equal(t, "error: tool not allowed", registry.Call(ctx, toolCall("memory"), []string{}))
+	assert.Equal(t, "error: unknown tool weather", registry.Call(ctx, toolCall("weather"), nil))
+	assert.Equal(t, []string{"time", "time"}, called)
+}
+
+func TestConverseWithToolsRejectsDisallowedTools(t *testing.T) {
+	var requests []ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		requests = append(requests, request)
+
+		message := ChatMessage{Role: "assistant", Content: "It is noon"}
+		if len(requests) == 1 {
+			message = ChatMessage{
+				Role:      "assistant",
+				ToolCalls: []ToolCall{toolCall("memory"), toolCall("time")},
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ChatResponse{Choices: []Choice{{Message: message}}})
+	}))
+	defer server.Close()
+
+	var called []string
+	c := &ChatGPT{GptModel: "gpt-test", ChatUrl: server.URL}
+	response, err := c.ConverseWithTools(context.Background(),
+		[]ChatMessage{{Role: "user", Content: "what time is it?"}},
+		testToolRegistry(&called), []string{"time"})
+	require.NoError(t, err)
+	assert.Equal(t, "It is noon", response.Choices[0].Message.Content)
+	assert.Equal(t, []string{"time"}, called)
+
+	require.Len(t, requests, 2)
+	require.Len(t, requests[0].Tools, 1)
+	assert.Equal(t, "time", requests[0].Tools[0].Function.Name)
+
+	results := requests[1].Messages[2:]
+	require.Len(t, results, 2)
+	assert.Equal(t, ChatMessage{Role: "tool", Content: "error: tool not allowed", ToolCallId: "call-memory"}, results[0])
+	assert.Equal(t, ChatMessage{Role: "tool", Content: "time result", ToolCallId: "call-time"}, results[1])
+}
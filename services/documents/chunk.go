@@ -0,0 +1,101 @@
+package documents
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}]{3,}`)
+
+// Chunk splits the text in pieces of at most size characters, breaking at
+// line ends when possible so paragraphs stay together.
+func Chunk(text string, size int) []string {
+	var chunks []string
+	var current strings.Builder
+	length := 0
+
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+		length = 0
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		runes := []rune(line)
+		if length+len(runes) > size {
+			flush()
+		}
+
+		for len(runes) > size {
+			chunks = append(chunks, string(runes[:size]))
+			runes = runes[size:]
+		}
+		current.WriteString(string(runes))
+		length += len(runes)
+	}
+	flush()
+	return chunks
+}
+
+// Select returns the indexes of the chunks that best match the question,
+// best first. Chunks are scored by the question words they contain, rare
+// words counting more. When no chunk matches, the first chunks are returned
+// so questions such as "summarize it" still see the start of the document.
+func Select(question string, chunks []string, limit int) []int {
+	terms := words(question)
+	chunkTerms := make([]map[string]int, len(chunks))
+	frequency := map[string]int{}
+	for i, chunk := range chunks {
+		chunkTerms[i] = map[string]int{}
+		for _, term := range wordPattern.FindAllString(strings.ToLower(chunk), -1) {
+			if chunkTerms[i][term] == 0 {
+				frequency[term]++
+			}
+			chunkTerms[i][term]++
+		}
+	}
+
+	scores := make([]float64, len(chunks))
+	matched := false
+	for i := range chunks {
+		for term := range terms {
+			count := chunkTerms[i][term]
+			if count == 0 {
+				continue
+			}
+			idf := math.Log(1 + float64(len(chunks))/float64(frequency[term]))
+			scores[i] += math.Log(1+float64(count)) * idf
+			matched = true
+		}
+	}
+
+	indexes := make([]int, len(chunks))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	if matched {
+		sort.SliceStable(indexes, func(a, b int) bool {
+			return scores[indexes[a]] > scores[indexes[b]]
+		})
+		for len(indexes) > 0 && scores[indexes[len(indexes)-1]] == 0 {
+			indexes = indexes[:len(indexes)-1]
+		}
+	}
+
+	if len(indexes) > limit {
+		indexes = indexes[:limit]
+	}
+	return indexes
+}
+
+func words(text string) map[string]bool {
+	terms := map[string]bool{}
+	for _, term := range wordPattern.FindAllString(strings.ToLower(text), -1) {
+		terms[term] = true
+	}
+	return terms
+}
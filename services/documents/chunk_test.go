@@ -0,0 +1,58 @@
+package documents
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunk(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		size int
+		want []string
+	}{
+		{"empty", "", 10, nil},
+		{"blank", " \n \n", 10, nil},
+		{"fits", "  hello world  ", 20, []string{"hello world"}},
+		{"breaks at line ends", "aaaa\nbbbb\ncccc\n", 10, []string{"aaaa\nbbbb", "cccc"}},
+		{"splits long lines", "abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"long line after a short one", "ab\ncdefgh\nij", 4, []string{"ab", "cdef", "gh", "ij"}},
+		{"counts runes", "ééééé", 2, []string{"éé", "éé", "é"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Chunk(tt.text, tt.size))
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	chunks := []string{
+		"The cat sat on the mat.",
+		"Dogs bark loudly at night.",
+		"The cat and the dogs are friends.",
+		"Nothing related here.",
+	}
+
+	tests := []struct {
+		name     string
+		question string
+		limit    int
+		want     []int
+	}{
+		{"single term", "Where is the cat?", 5, []int{0, 2}},
+		{"best match first", "why do dogs bark", 5, []int{1, 2}},
+		{"limit", "cat", 1, []int{0}},
+		{"no match returns the first chunks", "summarize it", 2, []int{0, 1}},
+		{"short words ignored", "is it on", 3, []int{0, 1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Select(tt.question, chunks, tt.limit))
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package documents
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// MaxTextLength bounds the characters kept from a document.
+const MaxTextLength = 200000
+
+// maxDocxXmlSize bounds the uncompressed word/document.xml read from a
+// DOCX, so a small archive cannot expand to gigabytes.
+const maxDocxXmlSize = 50 << 20
+
+const (
+	pdfMimeType  = "application/pdf"
+	docxMimeType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+)
+
+var ErrUnsupported = errors.New("unsupported document type")
+
+var textExtensions = map[string]bool{
+	".txt":  true,
+	".md":   true,
+	".csv":  true,
+	".json": true,
+	".xml":  true,
+	".yaml": true,
+	".yml":  true,
+	".toml": true,
+	".ini":  true,
+	".log":  true,
+	".html": true,
+	".go":   true,
+	".py":   true,
+	".js":   true,
+	".ts":   true,
+	".java": true,
+	".kt":   true,
+	".c":    true,
+	".h":    true,
+	".cpp":  true,
+	".cs":   true,
+	".rb":   true,
+	".rs":   true,
+	".php":  true,
+	".sh":   true,
+	".sql":  true,
+}
+
+// IsText reports whether the document is plain text, such as Markdown or
+// source code, by its MIME type or its extension.
+func IsText(name string, mimeType string) bool {
+	if strings.HasPrefix(mimeType, "text/") ||
+		mimeType == "application/json" ||
+		mimeType == "application/xml" ||
+		mimeType == "application/x-yaml" {
+		return true
+	}
+	return textExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// IsSupported reports whether Extract can read the document.
+func IsSupported(name string, mimeType string) bool {
+	return isPdf(name, mimeType) || isDocx(name, mimeType) || IsText(name, mimeType)
+}
+
+// Extract returns the text of a PDF, DOCX or plain text document, truncated
+// to MaxTextLength characters.
+func Extract(name string, mimeType string, content []byte) (string, error) {
+	var text string
+	var err error
+
+	switch {
+	case isPdf(name, mimeType):
+		text, err = extractPdf(content)
+	case isDocx(name, mimeType):
+		text, err = extractDocx(content)
+	case IsText(name, mimeType):
+		if !utf8.Valid(content) {
+			return "", fmt.Errorf("%s is not UTF-8 text", name)
+		}
+		text = string(content)
+	default:
+		return "", ErrUnsupported
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return "", fmt.Errorf("no text found in %s", name)
+	}
+
+	runes := []rune(text)
+	if len(runes) > MaxTextLength {
+		text = string(runes[:MaxTextLength])
+	}
+	return text, nil
+}
+
+func isPdf(name string, mimeType string) bool {
+	return mimeType == pdfMimeType || strings.EqualFold(path.Ext(name), ".pdf")
+}
+
+func isDocx(name string, mimeType string) bool {
+	return mimeType == docxMimeType || strings.EqualFold(path.Ext(name), ".docx")
+}
+
+func extractPdf(content []byte) (text string, err error) {
+	// The PDF reader panics on some malformed files.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", err
+	}
+
+	plain, err := reader.GetPlainText()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := io.ReadAll(plain)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// extractDocx reads the text of word/document.xml, keeping paragraphs,
+// line breaks and tabs.
+func extractDocx(content []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", err
+	}
+
+	for _, file := range archive.File {
+		if file.Name != "word/document.xml" {
+			continue
+		}
+		if file.UncompressedSize64 > maxDocxXmlSize {
+			return "", fmt.Errorf("word/document.xml is larger than %d MB", maxDocxXmlSize>>20)
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			return "", err
+		}
+		defer reader.Close()
+		return readDocxText(io.LimitReader(reader, maxDocxXmlSize))
+	}
+	return "", errors.New("word/document.xml not found")
+}
+
+// readDocxText stops once MaxTextLength characters are read, as the rest
+// would be dropped by Extract.
+func readDocxText(reader io.Reader) (string, error) {
+	var text strings.Builder
+	length := 0
+	write := func(s string) {
+		text.WriteString(s)
+		length += utf8.RuneCountInString(s)
+	}
+	inText := false
+
+	decoder := xml.NewDecoder(reader)
+	for length < MaxTextLength {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				write("\t")
+			case "br", "cr":
+				write("\n")
+			}
+		case xml.EndElement:
+			switch element.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				write("\n")
+			}
+		case xml.CharData:
+			if inText {
+				write(string(element))
+			}
+		}
+	}
+	return text.String(), nil
+}
@@ -0,0 +1,120 @@
+package documents
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func docx(t *testing.T, document string) []byte {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	file, err := archive.Create("word/document.xml")
+	require.NoError(t, err)
+	_, err = file.Write([]byte(document))
+	require.NoError(t, err)
+	require.NoError(t, archive.Close())
+	return buf.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	document := `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:body>
+<w:p><w:r><w:t>First</w:t></w:r><w:r><w:tab/><w:t>paragraph</w:t></w:r></w:p>
+<w:p><w:r><w:t>Second</w:t><w:br/><w:t>line</w:t></w:r></w:p>
+</w:body>
+</w:document>`
+
+	tests := []struct {
+		name     string
+		file     string
+		mimeType string
+		content  []byte
+		want     string
+		wantErr  string
+	}{
+		{"markdown by extension", "notes.md", "", []byte("# Title\r\n\r\nText\n"), "# Title\n\nText", ""},
+		{"text by mime type", "notes", "text/plain", []byte(" hello "), "hello", ""},
+		{"json", "data", "application/json", []byte(`{"a": 1}`), `{"a": 1}`, ""},
+		{"docx", "report.docx", "", docx(t, document), "First\tparagraph\nSecond\nline", ""},
+		{"invalid utf-8", "notes.txt", "", []byte{0xff, 0xfe}, "", "notes.txt is not UTF-8 text"},
+		{"empty", "notes.txt", "", []byte("  \n"), "", "no text found in notes.txt"},
+		{"unsupported", "photo.png", "image/png", []byte("png"), "", ErrUnsupported.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := Extract(tt.file, tt.mimeType, tt.content)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, text)
+		})
+	}
+}
+
+func TestExtractLongDocx(t *testing.T) {
+	paragraph := "<w:p><w:r><w:t>" + strings.Repeat("a", 1000) + "</w:t></w:r></w:p>"
+	document := "<w:document><w:body>" + strings.Repeat(paragraph, 300) + "</w:body></w:document>"
+
+	text, err := Extract("long.docx", "", docx(t, document))
+	require.NoError(t, err)
+	assert.Equal(t, MaxTextLength, len(text))
+}
+
+func TestExtractDocxTooLarge(t *testing.T) {
+	document := []byte("<w:document><w:body><w:p><w:r><w:t>bomb</w:t></w:r></w:p></w:body></w:document>")
+	var compressed bytes.Buffer
+	writer, err := flate.NewWriter(&compressed, flate.BestCompression)
+	require.NoError(t, err)
+	_, err = writer.Write(document)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	// The archive declares a size over the limit, as a zip bomb would.
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	file, err := archive.CreateRaw(&zip.FileHeader{
+		Name:               "word/document.xml",
+		Method:             zip.Deflate,
+		CompressedSize64:   uint64(compressed.Len()),
+		UncompressedSize64: maxDocxXmlSize + 1,
+	})
+	require.NoError(t, err)
+	_, err = file.Write(compressed.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, archive.Close())
+
+	_, err = Extract("bomb.docx", "", buf.Bytes())
+	assert.EqualError(t, err, "word/document.xml is larger than 50 MB")
+}
+
+func TestIsSupported(t *testing.T) {
+	tests := []struct {
+		file     string
+		mimeType string
+		want     bool
+	}{
+		{"paper.pdf", "", true},
+		{"paper", "application/pdf", true},
+		{"report.DOCX", "", true},
+		{"main.go", "", true},
+		{"page.html", "text/html", true},
+		{"photo.jpg", "image/jpeg", false},
+		{"archive.zip", "application/zip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsSupported(tt.file, tt.mimeType))
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+	"github.com/marlosl/gpt-telegram-bot/utils"
+	"github.com/marlosl/gpt-telegram-bot/utils/config"
+	"github.com/marlosl/gpt-telegram-bot/utils/logger"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type EmbeddingProvider string
+
+const (
+	OpenAIProvider EmbeddingProvider = "openai"
+	OllamaProvider EmbeddingProvider = "ollama"
+
+	openAIEmbeddingsUrl   = "https://api.openai.com/v1/embeddings"
+	defaultOpenAIModel    = "text-embedding-3-small"
+	defaultOllamaUrl      = "http://localhost:11434"
+	defaultOllamaModel    = "nomic-embed-text"
+	maxEmbeddingBatchSize = 64
+)
+
+type Embedder interface {
+	// Embed returns one vector for each text, in the same order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+type OpenAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type OpenAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+type OllamaEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type OllamaEmbeddingResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// NewEmbedder returns the embedding provider selected by the configuration,
+// falling back to OpenAI embeddings when no provider is set.
+func NewEmbedder() Embedder {
+	config.NewConfig(config.SSM)
+
+	model := config.Store.EmbeddingsModel
+	switch EmbeddingProvider(strings.ToLower(config.Store.EmbeddingsProvider)) {
+	case OllamaProvider:
+		url := config.Store.EmbeddingsUrl
+		if url == "" {
+			url = defaultOllamaUrl
+		}
+		if model == "" {
+			model = defaultOllamaModel
+		}
+		return &OllamaEmbedder{
+			Url:   strings.TrimSuffix(url, "/") + "/api/embed",
+			Model: model,
+		}
+	}
+
+	url := config.Store.EmbeddingsUrl
+	if url == "" {
+		url = openAIEmbeddingsUrl
+	}
+	if model == "" {
+		model = defaultOpenAIModel
+	}
+	return &OpenAIEmbedder{
+		ApiKey: config.Store.GptApiKey,
+		Url:    url,
+		Model:  model,
+	}
+}
+
+// OpenAIEmbedder talks to the OpenAI embeddings endpoint, or to any server
+// compatible with it.
+type OpenAIEmbedder struct {
+	ApiKey string
+	Url    string
+	Model  string
+}
+
+func (o *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return inBatches(texts, func(batch []string) ([][]float32, error) {
+		resp, err := newRequest(ctx).
+			SetAuthToken(o.ApiKey).
+			SetResult(OpenAIEmbeddingResponse{}).
+			SetBody(OpenAIEmbeddingRequest{
+				Model: o.Model,
+				Input: batch,
+			}).
+			Post(o.Url)
+
+		if err := checkResponse(ctx, resp, err); err != nil {
+			return nil, err
+		}
+
+		data := resp.Result().(*OpenAIEmbeddingResponse).Data
+		vectors := make([][]float32, len(batch))
+		for _, item := range data {
+			if item.Index < 0 || item.Index >= len(vectors) {
+				return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+			}
+			vectors[item.Index] = item.Embedding
+		}
+		return vectors, nil
+	})
+}
+
+// OllamaEmbedder talks to a local Ollama server.
+type OllamaEmbedder struct {
+	Url   string
+	Model string
+}
+
+func (o *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return inBatches(texts, func(batch []string) ([][]float32, error) {
+		resp, err := newRequest(ctx).
+			SetResult(OllamaEmbeddingResponse{}).
+			SetBody(OllamaEmbeddingRequest{
+				Model: o.Model,
+				Input: batch,
+			}).
+			Post(o.Url)
+
+		if err := checkResponse(ctx, resp, err); err != nil {
+			return nil, err
+		}
+		return resp.Result().(*OllamaEmbeddingResponse).Embeddings, nil
+	})
+}
+
+func inBatches(texts []string, embed func(batch []string) ([][]float32, error)) ([][]float32, error) {
+	var vectors [][]float32
+	for start := 0; start < len(texts); start += maxEmbeddingBatchSize {
+		end := start + maxEmbeddingBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+
+		batch, err := embed(texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(batch))
+		}
+		for _, vector := range batch {
+			if len(vector) == 0 {
+				return nil, fmt.Errorf("empty embedding returned")
+			}
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+func newRequest(ctx context.Context) *resty.Request {
+	client := resty.New()
+	client.SetTimeout(time.Minute)
+	req := client.R().
+		SetContext(ctx).
+		SetHeader("content-type", "application/json").
+		EnableTrace()
+	if id := logger.CorrelationId(ctx); id != "" {
+		req.SetHeader(consts.CorrelationIdHeader, id)
+	}
+	return req
+}
+
+func checkResponse(ctx context.Context, resp *resty.Response, err error) error {
+	utils.PrintRestyDebug(ctx, resp, err)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode() < 200 || resp.StatusCode() > 299 {
+		return fmt.Errorf("embedding request failed with response code: %d", resp.StatusCode())
+	}
+	return nil
+}
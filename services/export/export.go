@@ -0,0 +1,243 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/clients/s3"
+	"github.com/marlosl/gpt-telegram-bot/utils/logger"
+)
+
+type Format string
+
+const (
+	Markdown Format = "md"
+	JSON     Format = "json"
+	HTML     Format = "html"
+
+	maxExportImages = 100
+	timeLayout      = "2006-01-02 15:04:05 MST"
+)
+
+var Formats = []Format{Markdown, JSON, HTML}
+
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Role     string    `json:"role"`
+	Name     string    `json:"name,omitempty"`
+	Content  string    `json:"content,omitempty"`
+	ImageUrl string    `json:"imageUrl,omitempty"`
+}
+
+type Conversation struct {
+	ChatId     string    `json:"chatId"`
+	Title      string    `json:"title,omitempty"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Entries    []Entry   `json:"entries"`
+}
+
+// Options select what is hidden from the export. Secrets replaces tokens
+// and keys, Content replaces every message text and Names replaces the
+// speakers with numbered participants.
+type Options struct {
+	Secrets bool
+	Content bool
+	Names   bool
+}
+
+func ParseFormat(value string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(value, ".")) {
+	case "", "md", "markdown":
+		return Markdown, nil
+	case "json":
+		return JSON, nil
+	case "html", "htm":
+		return HTML, nil
+	}
+	return "", fmt.Errorf("unknown format %s, use md, json or html", value)
+}
+
+// Load collects the stored history of the thread, or of the whole chat
+// without a thread id, and the images the user created in the chat, in
+// chronological order. Without a user id, or without storage to resolve
+// the image links, the images are left out.
+func Load(history *db.HistoryRepository, gallery *db.GalleryRepository, storage *s3.S3Client, chatId string, threadId string, userId string) (*Conversation, error) {
+	conversation := &Conversation{
+		ChatId:     chatId,
+		ExportedAt: time.Now().UTC(),
+	}
+
+	if history != nil {
+		messages, err := history.ListAllMessages(chatId, threadId)
+		if err != nil {
+			return nil, err
+		}
+		for _, message := range messages {
+			conversation.Entries = append(conversation.Entries, Entry{
+				Time:    time.Unix(0, message.CreatedAt).UTC(),
+				Role:    message.Role,
+				Name:    message.Name,
+				Content: message.Content,
+			})
+		}
+	}
+
+	if gallery != nil && storage != nil && userId != "" {
+		images, err := gallery.ListImages(userId, maxExportImages)
+		if err != nil {
+			return nil, err
+		}
+		for _, image := range images {
+			if image.ChatId != chatId {
+				continue
+			}
+			imageUrl, err := storage.GetUrl(image.Key)
+			if err != nil {
+				return nil, err
+			}
+			conversation.Entries = append(conversation.Entries, Entry{
+				Time:     time.Unix(image.CreatedAt, 0).UTC(),
+				Role:     "assistant",
+				Content:  image.Prompt,
+				ImageUrl: imageUrl,
+			})
+		}
+	}
+
+	sort.SliceStable(conversation.Entries, func(i, j int) bool {
+		return conversation.Entries[i].Time.Before(conversation.Entries[j].Time)
+	})
+	return conversation, nil
+}
+
+// Redact returns a copy of the conversation with the selected parts hidden.
+func Redact(conversation *Conversation, options Options) *Conversation {
+	redacted := *conversation
+	redacted.Entries = make([]Entry, len(conversation.Entries))
+
+	participants := map[string]string{}
+	for i, entry := range conversation.Entries {
+		if options.Secrets {
+			entry.Content = logger.Redact(entry.Content)
+		}
+		if options.Content && entry.Content != "" {
+			entry.Content = logger.Redacted
+		}
+		if options.Names && entry.Name != "" {
+			if _, ok := participants[entry.Name]; !ok {
+				participants[entry.Name] = fmt.Sprintf("Participant %d", len(participants)+1)
+			}
+			entry.Name = participants[entry.Name]
+		}
+		redacted.Entries[i] = entry
+	}
+	return &redacted
+}
+
+func Filename(conversation *Conversation, format Format) string {
+	return fmt.Sprintf("chat-%s-%s.%s", conversation.ChatId, conversation.ExportedAt.Format("20060102-150405"), format)
+}
+
+func Render(conversation *Conversation, format Format) ([]byte, error) {
+	switch format {
+	case Markdown:
+		return renderMarkdown(conversation), nil
+	case JSON:
+		return renderJSON(conversation)
+	case HTML:
+		return renderHTML(conversation), nil
+	}
+	return nil, fmt.Errorf("unknown format %s", format)
+}
+
+func renderJSON(conversation *Conversation) ([]byte, error) {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(conversation)
+	return out.Bytes(), err
+}
+
+func title(conversation *Conversation) string {
+	if conversation.Title != "" {
+		return conversation.Title
+	}
+	return "Chat " + conversation.ChatId
+}
+
+func speaker(entry Entry) string {
+	if entry.Name != "" {
+		return entry.Name
+	}
+	switch entry.Role {
+	case "assistant":
+		return "Assistant"
+	case "system":
+		return "System"
+	}
+	return "User"
+}
+
+var altReplacer = strings.NewReplacer("[", "\\[", "]", "\\]", "\n", " ")
+
+func renderMarkdown(conversation *Conversation) []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# %s\n\nExported at %s\n", title(conversation), conversation.ExportedAt.Format(timeLayout))
+
+	for _, entry := range conversation.Entries {
+		fmt.Fprintf(&out, "\n### %s · %s\n\n", speaker(entry), entry.Time.Format(timeLayout))
+		if entry.ImageUrl != "" {
+			fmt.Fprintf(&out, "![%s](%s)\n\n", altReplacer.Replace(entry.Content), entry.ImageUrl)
+			continue
+		}
+		fmt.Fprintf(&out, "%s\n", entry.Content)
+	}
+	return out.Bytes()
+}
+
+const htmlHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { font-family: sans-serif; max-width: 48em; margin: 2em auto; padding: 0 1em; }
+.entry { margin: 1em 0; padding: 0.5em 1em; border-radius: 8px; background: #f1f1f1; }
+.assistant { background: #e3f0ff; }
+.meta { color: #666; font-size: 0.85em; }
+.content { white-space: pre-wrap; }
+img { max-width: 100%%; }
+</style>
+</head>
+<body>
+<h1>%s</h1>
+<p class="meta">Exported at %s</p>
+`
+
+func renderHTML(conversation *Conversation) []byte {
+	var out bytes.Buffer
+	heading := html.EscapeString(title(conversation))
+	fmt.Fprintf(&out, htmlHeader, heading, heading, conversation.ExportedAt.Format(timeLayout))
+
+	for _, entry := range conversation.Entries {
+		fmt.Fprintf(&out, "<div class=\"entry %s\">\n<p class=\"meta\">%s · %s</p>\n",
+			html.EscapeString(entry.Role), html.EscapeString(speaker(entry)), entry.Time.Format(timeLayout))
+		if entry.ImageUrl != "" {
+			fmt.Fprintf(&out, "<a href=\"%s\"><img src=\"%s\" alt=\"%s\"></a>\n",
+				html.EscapeString(entry.ImageUrl), html.EscapeString(entry.ImageUrl), html.EscapeString(entry.Content))
+		}
+		if entry.Content != "" {
+			fmt.Fprintf(&out, "<div class=\"content\">%s</div>\n", html.EscapeString(entry.Content))
+		}
+		out.WriteString("</div>\n")
+	}
+	out.WriteString("</body>\n</html>\n")
+	return out.Bytes()
+}
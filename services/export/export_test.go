@@ -0,0 +1,168 @@
+package export
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/utils/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConversation() *Conversation {
+	start := time.Date(2024, 3, 10, 14, 30, 0, 0, time.UTC)
+	return &Conversation{
+		ChatId:     "-100",
+		ExportedAt: start.Add(time.Hour),
+		Entries: []Entry{
+			{Time: start, Role: "user", Name: "alice", Content: "my key is sk-abcdefghijklmnopqrstuvwxyz123456"},
+			{Time: start.Add(time.Minute), Role: "assistant", Content: "Use <b>env</b> vars & rotate it."},
+			{Time: start.Add(2 * time.Minute), Role: "user", Name: "bob", Content: "thanks"},
+			{Time: start.Add(3 * time.Minute), Role: "user", Name: "alice", Content: "a [red]\nfox", ImageUrl: "https://images.example.com/fox.png"},
+		},
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Format
+		wantErr bool
+	}{
+		{"", Markdown, false},
+		{"markdown", Markdown, false},
+		{".MD", Markdown, false},
+		{"json", JSON, false},
+		{"htm", HTML, false},
+		{"HTML", HTML, false},
+		{"pdf", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			format, err := ParseFormat(tt.value)
+			if tt.wantErr {
+				assert.EqualError(t, err, "unknown format pdf, use md, json or html")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, format)
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	conversation := testConversation()
+
+	tests := []struct {
+		name         string
+		options      Options
+		wantContents []string
+		wantNames    []string
+	}{
+		{
+			name:         "nothing hidden",
+			wantContents: []string{conversation.Entries[0].Content, conversation.Entries[1].Content, "thanks", "a [red]\nfox"},
+			wantNames:    []string{"alice", "", "bob", "alice"},
+		},
+		{
+			name:         "secrets",
+			options:      Options{Secrets: true},
+			wantContents: []string{"my key is " + logger.Redacted, conversation.Entries[1].Content, "thanks", "a [red]\nfox"},
+			wantNames:    []string{"alice", "", "bob", "alice"},
+		},
+		{
+			name:         "content and names",
+			options:      Options{Content: true, Names: true},
+			wantContents: []string{logger.Redacted, logger.Redacted, logger.Redacted, logger.Redacted},
+			wantNames:    []string{"Participant 1", "", "Participant 2", "Participant 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redacted := Redact(conversation, tt.options)
+
+			var contents, names []string
+			for _, entry := range redacted.Entries {
+				contents = append(contents, entry.Content)
+				names = append(names, entry.Name)
+			}
+			assert.Equal(t, tt.wantContents, contents)
+			assert.Equal(t, tt.wantNames, names)
+		})
+	}
+
+	assert.Equal(t, "alice", conversation.Entries[0].Name, "the original conversation is not changed")
+}
+
+func TestFilename(t *testing.T) {
+	assert.Equal(t, "chat--100-20240310-153000.html", Filename(testConversation(), HTML))
+}
+
+func TestRender(t *testing.T) {
+	conversation := testConversation()
+
+	tests := []struct {
+		format   Format
+		contains []string
+	}{
+		{
+			format: Markdown,
+			contains: []string{
+				"# Chat -100\n\nExported at 2024-03-10 15:30:00 UTC\n",
+				"\n### alice · 2024-03-10 14:30:00 UTC\n\nmy key is",
+				"\n### Assistant · 2024-03-10 14:31:00 UTC\n\nUse <b>env</b> vars & rotate it.\n",
+				"![a \\[red\\] fox](https://images.example.com/fox.png)\n",
+			},
+		},
+		{
+			format: HTML,
+			contains: []string{
+				"<title>Chat -100</title>",
+				"<div class=\"entry assistant\">\n<p class=\"meta\">Assistant · 2024-03-10 14:31:00 UTC</p>",
+				"<div class=\"content\">Use &lt;b&gt;env&lt;/b&gt; vars &amp; rotate it.</div>",
+				"<img src=\"https://images.example.com/fox.png\" alt=\"a [red]\nfox\">",
+			},
+		},
+		{
+			format: JSON,
+			contains: []string{
+				`"chatId": "-100"`,
+				`"content": "Use <b>env</b> vars & rotate it."`,
+				`"imageUrl": "https://images.example.com/fox.png"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			content, err := Render(conversation, tt.format)
+			require.NoError(t, err)
+			for _, want := range tt.contains {
+				assert.Contains(t, string(content), want)
+			}
+		})
+	}
+
+	_, err := Render(conversation, "pdf")
+	assert.EqualError(t, err, "unknown format pdf")
+}
+
+func TestRenderJSONRoundTrip(t *testing.T) {
+	conversation := testConversation()
+	conversation.Title = "Team chat"
+
+	content, err := Render(conversation, JSON)
+	require.NoError(t, err)
+
+	var decoded Conversation
+	require.NoError(t, json.Unmarshal(content, &decoded))
+	assert.Equal(t, *conversation, decoded)
+
+	markdown, err := Render(conversation, Markdown)
+	require.NoError(t, err)
+	assert.Contains(t, string(markdown), "# Team chat\n")
+}
@@ -0,0 +1,100 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/marlosl/gpt-telegram-bot/services/documents"
+	"github.com/marlosl/gpt-telegram-bot/services/embeddings"
+)
+
+const chunkSize = 1500
+
+var sourceExtensions = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".txt":      true,
+}
+
+type IngestSummary struct {
+	Files  int
+	Chunks int
+}
+
+// Ingest chunks the Markdown and text files found under dir, computes their
+// embeddings and stores them, replacing the entries previously ingested for
+// the same files. Sources are the file paths relative to dir.
+func Ingest(ctx context.Context, embedder embeddings.Embedder, store Store, dir string) (*IngestSummary, error) {
+	summary := &IngestSummary{}
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != dir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !sourceExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		source, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		chunks, err := ingestFile(ctx, embedder, store, path, filepath.ToSlash(source))
+		if err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+		summary.Files++
+		summary.Chunks += chunks
+		return nil
+	})
+	return summary, err
+}
+
+func ingestFile(ctx context.Context, embedder embeddings.Embedder, store Store, path string, source string) (int, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	text, err := documents.Extract(path, "", content)
+	if err != nil {
+		return 0, err
+	}
+
+	chunks := documents.Chunk(text, chunkSize)
+	vectors, err := embedder.Embed(ctx, chunks)
+	if err != nil {
+		return 0, err
+	}
+
+	entries := make([]Entry, len(chunks))
+	for i, chunk := range chunks {
+		entries[i] = Entry{
+			Source:  source,
+			Index:   i,
+			Content: chunk,
+			Vector:  vectors[i],
+		}
+	}
+	return len(entries), store.Replace(source, entries)
+}
+
+// Retrieve returns the chunks of the knowledge base closest to the question.
+func Retrieve(ctx context.Context, embedder embeddings.Embedder, store Store, question string, limit int) ([]Match, error) {
+	vectors, err := embedder.Embed(ctx, []string{question})
+	if err != nil {
+		return nil, err
+	}
+	return store.Search(vectors[0], limit)
+}
@@ -0,0 +1,68 @@
+package knowledge
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keywordEmbedder embeds texts by the keywords they contain, so the
+// closest chunks are predictable.
+type keywordEmbedder struct {
+	keywords []string
+}
+
+func (k *keywordEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float32, len(k.keywords))
+		for j, keyword := range k.keywords {
+			vectors[i][j] = float32(strings.Count(strings.ToLower(text), keyword))
+		}
+	}
+	return vectors, nil
+}
+
+func TestIngestAndRetrieve(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"deploy.md":           "How to deploy: run make aws-deploy with the AWS credentials.",
+		"guides/vacation.txt": "Vacation requests go to the team lead two weeks before.",
+		"image.png":           "not a source",
+		".hidden/secret.md":   "deploy secrets",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	embedder := &keywordEmbedder{keywords: []string{"deploy", "vacation"}}
+	store := NewMemoryStore()
+
+	summary, err := Ingest(context.Background(), embedder, store, dir)
+	require.NoError(t, err)
+	assert.Equal(t, &IngestSummary{Files: 2, Chunks: 2}, summary)
+
+	tests := []struct {
+		question string
+		want     string
+	}{
+		{"how do I deploy?", "deploy.md"},
+		{"vacation rules", "guides/vacation.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.question, func(t *testing.T) {
+			matches, err := Retrieve(context.Background(), embedder, store, tt.question, 1)
+			require.NoError(t, err)
+			require.Len(t, matches, 1)
+			assert.Equal(t, tt.want, matches[0].Source)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package knowledge
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/consts"
+)
+
+// Entry is a chunk of a source file with its embedding.
+type Entry struct {
+	Source  string
+	Index   int
+	Content string
+	Vector  []float32
+}
+
+type Match struct {
+	Entry
+	Score float64
+}
+
+type Store interface {
+	// Replace stores the entries of the source, removing the previous ones.
+	Replace(source string, entries []Entry) error
+	// Search returns the entries closest to the vector, best first.
+	Search(vector []float32, limit int) ([]Match, error)
+}
+
+// NewStore returns the DynamoDB store when the table is configured, as in
+// the Lambda functions, and an in-memory store otherwise.
+func NewStore() (Store, error) {
+	if os.Getenv(consts.CacheTable) == "" {
+		return NewMemoryStore(), nil
+	}
+
+	repository, err := db.NewKnowledgeRepository()
+	if err != nil {
+		return nil, err
+	}
+	return &DynamoStore{Repository: repository}, nil
+}
+
+type MemoryStore struct {
+	mutex   sync.RWMutex
+	entries map[string][]Entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: map[string][]Entry{},
+	}
+}
+
+func (m *MemoryStore) Replace(source string, entries []Entry) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.entries[source] = entries
+	return nil
+}
+
+func (m *MemoryStore) Search(vector []float32, limit int) ([]Match, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var entries []Entry
+	for _, sourceEntries := range m.entries {
+		entries = append(entries, sourceEntries...)
+	}
+	return rank(vector, entries, limit), nil
+}
+
+// DynamoStore keeps the knowledge base in the cache table. Searching reads
+// every entry and compares them in memory, which suits knowledge bases of
+// a few thousand chunks.
+type DynamoStore struct {
+	Repository *db.KnowledgeRepository
+}
+
+func (d *DynamoStore) Replace(source string, entries []Entry) error {
+	err := d.Repository.DeleteSource(source)
+	if err != nil {
+		return err
+	}
+
+	items := make([]db.KnowledgeEntry, len(entries))
+	for i, entry := range entries {
+		items[i] = db.KnowledgeEntry{
+			Source:  entry.Source,
+			Index:   entry.Index,
+			Content: entry.Content,
+			Vector:  encodeVector(entry.Vector),
+		}
+	}
+	return d.Repository.SaveEntries(items)
+}
+
+func (d *DynamoStore) Search(vector []float32, limit int) ([]Match, error) {
+	items, err := d.Repository.ListEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(items))
+	for _, item := range items {
+		itemVector, err := decodeVector(item.Vector)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{
+			Source:  item.Source,
+			Index:   item.Index,
+			Content: item.Content,
+			Vector:  itemVector,
+		})
+	}
+	return rank(vector, entries, limit), nil
+}
+
+func rank(vector []float32, entries []Entry, limit int) []Match {
+	matches := make([]Match, 0, len(entries))
+	for _, entry := range entries {
+		if len(entry.Vector) != len(vector) {
+			continue
+		}
+		matches = append(matches, Match{
+			Entry: entry,
+			Score: Cosine(vector, entry.Vector),
+		})
+	}
+
+	sort.SliceStable(matches, func(a, b int) bool {
+		return matches[a].Score > matches[b].Score
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// Cosine returns the cosine similarity of two vectors of the same length.
+func Cosine(a []float32, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+func encodeVector(vector []float32) []byte {
+	data := make([]byte, 4*len(vector))
+	for i, value := range vector {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(value))
+	}
+	return data
+}
+
+func decodeVector(data []byte) ([]float32, error) {
+	if len(data)%4 != 0 {
+		return nil, errors.New("invalid vector length")
+	}
+
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return vector, nil
+}
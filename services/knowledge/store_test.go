@@ -0,0 +1,82 @@
+package knowledge
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCosine(t *testing.T) {
+	tests := []struct {
+		name string
+		a    []float32
+		b    []float32
+		want float64
+	}{
+		{"same direction", []float32{1, 2, 3}, []float32{2, 4, 6}, 1},
+		{"opposite", []float32{1, 0}, []float32{-1, 0}, -1},
+		{"orthogonal", []float32{1, 0}, []float32{0, 1}, 0},
+		{"diagonal", []float32{1, 1}, []float32{1, 0}, 1 / math.Sqrt2},
+		{"zero vector", []float32{0, 0}, []float32{1, 1}, 0},
+		{"empty", nil, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, Cosine(tt.a, tt.b), 1e-9)
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	entries := []Entry{
+		{Source: "far.md", Vector: []float32{0, 1}},
+		{Source: "close.md", Vector: []float32{1, 0.1}},
+		{Source: "other-dimension.md", Vector: []float32{1, 0, 0}},
+		{Source: "middle.md", Vector: []float32{1, 1}},
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []string
+	}{
+		{"best first", 5, []string{"close.md", "middle.md", "far.md"}},
+		{"limit", 2, []string{"close.md", "middle.md"}},
+		{"none", 0, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := rank([]float32{1, 0}, entries, tt.limit)
+			sources := make([]string, len(matches))
+			for i, match := range matches {
+				sources[i] = match.Source
+			}
+			assert.Equal(t, tt.want, sources)
+		})
+	}
+}
+
+func TestVectorEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		vector []float32
+	}{
+		{"empty", []float32{}},
+		{"values", []float32{0, 1, -1.5, 3.25, math.MaxFloat32}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := decodeVector(encodeVector(tt.vector))
+			require.NoError(t, err)
+			assert.Equal(t, tt.vector, decoded)
+		})
+	}
+
+	_, err := decodeVector([]byte{1, 2, 3})
+	assert.EqualError(t, err, "invalid vector length")
+}
@@ -0,0 +1,375 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+	"github.com/marlosl/gpt-telegram-bot/utils"
+	"github.com/marlosl/gpt-telegram-bot/utils/config"
+	"github.com/marlosl/gpt-telegram-bot/utils/logger"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type Action string
+
+const (
+	Allow Action = "allow"
+	Log   Action = "log"
+	Warn  Action = "warn"
+	Block Action = "block"
+)
+
+// Stage is the point of a conversation where a text is checked.
+type Stage string
+
+const (
+	Prompts Stage = "prompts"
+	Outputs Stage = "outputs"
+	Images  Stage = "images"
+)
+
+type ModerationProvider string
+
+const (
+	OpenAIProvider   ModerationProvider = "openai"
+	KeywordsProvider ModerationProvider = "keywords"
+
+	openAIModerationUrl = "https://api.openai.com/v1/moderations"
+	defaultOpenAIModel  = "omni-moderation-latest"
+	defaultCategory     = "default"
+	DefaultRefusal      = "Sorry, I can't help with that, it goes against the content policy of this chat."
+)
+
+var severity = map[Action]int{
+	Allow: 0,
+	Log:   1,
+	Warn:  2,
+	Block: 3,
+}
+
+type Moderator interface {
+	// Categories returns the categories the text was flagged for, none
+	// when it is acceptable.
+	Categories(ctx context.Context, text string) ([]string, error)
+}
+
+// Policy maps the flagged categories to the action taken. A category
+// without an action uses the one of its parent category, e.g. hate for
+// hate/threatening, and then the default action.
+type Policy struct {
+	Default Action
+	Actions map[string]Action
+}
+
+// ParsePolicy reads a policy written as category=action pairs separated by
+// commas, e.g. "default=block,self-harm=warn,violence=log". The default
+// action is block unless the policy sets another one.
+func ParsePolicy(value string) (Policy, error) {
+	policy := Policy{
+		Default: Block,
+		Actions: map[string]Action{},
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		category, name, ok := strings.Cut(pair, "=")
+		action := Action(strings.ToLower(strings.TrimSpace(name)))
+		if _, known := severity[action]; !ok || !known {
+			return policy, fmt.Errorf("invalid moderation policy %s, use <category>=block|warn|log|allow", pair)
+		}
+
+		category = strings.ToLower(strings.TrimSpace(category))
+		if category == defaultCategory {
+			policy.Default = action
+		} else {
+			policy.Actions[category] = action
+		}
+	}
+	return policy, nil
+}
+
+func (p Policy) Action(category string) Action {
+	category = strings.ToLower(category)
+	for {
+		if action, ok := p.Actions[category]; ok {
+			return action
+		}
+		index := strings.LastIndex(category, "/")
+		if index < 0 {
+			return p.Default
+		}
+		category = category[:index]
+	}
+}
+
+// Decision is the outcome of a check: the most severe action of the
+// flagged categories.
+type Decision struct {
+	Action     Action
+	Categories []string
+}
+
+func (d *Decision) Flagged() bool {
+	return len(d.Categories) > 0
+}
+
+// Gate checks the texts of the enabled stages against the moderator and
+// decides what to do with them following the policy.
+type Gate struct {
+	Moderator Moderator
+	Policy    Policy
+	Stages    []Stage
+	Refusal   string
+}
+
+// NewGate returns the gate selected by the configuration, or nil when
+// moderation is disabled. The provider is openai, keywords or both,
+// separated by commas. Prompts are always checked, outputs and image
+// prompts only when listed in the moderation stages.
+func NewGate() *Gate {
+	config.NewConfig(config.SSM)
+
+	var moderators multiModerator
+	for _, name := range strings.Split(config.Store.ModerationProvider, ",") {
+		switch ModerationProvider(strings.ToLower(strings.TrimSpace(name))) {
+		case OpenAIProvider:
+			url := config.Store.ModerationUrl
+			if url == "" {
+				url = openAIModerationUrl
+			}
+			moderators = append(moderators, &OpenAIModerator{
+				ApiKey: config.Store.GptApiKey,
+				Url:    url,
+				Model:  defaultOpenAIModel,
+			})
+		case KeywordsProvider:
+			keywords, err := ParseKeywords(config.Store.ModerationKeywords)
+			if err != nil {
+				slog.Error("Invalid moderation keywords, ignoring them", "error", err)
+				continue
+			}
+			moderators = append(moderators, keywords)
+		}
+	}
+	if len(moderators) == 0 {
+		return nil
+	}
+
+	policy, err := ParsePolicy(config.Store.ModerationPolicy)
+	if err != nil {
+		slog.Error("Invalid moderation policy, blocking every flagged text", "error", err)
+		policy = Policy{Default: Block}
+	}
+
+	stages := []Stage{Prompts}
+	for _, name := range strings.Split(config.Store.ModerationStages, ",") {
+		switch stage := Stage(strings.ToLower(strings.TrimSpace(name))); stage {
+		case Outputs, Images:
+			stages = append(stages, stage)
+		}
+	}
+
+	refusal := config.Store.ModerationRefusal
+	if refusal == "" {
+		refusal = DefaultRefusal
+	}
+
+	var moderator Moderator = moderators
+	if len(moderators) == 1 {
+		moderator = moderators[0]
+	}
+	return &Gate{
+		Moderator: moderator,
+		Policy:    policy,
+		Stages:    stages,
+		Refusal:   refusal,
+	}
+}
+
+func (g *Gate) Enabled(stage Stage) bool {
+	if g == nil {
+		return false
+	}
+	for _, enabled := range g.Stages {
+		if enabled == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// Check moderates the text when the stage is enabled. Texts of disabled
+// stages are allowed without a check.
+func (g *Gate) Check(ctx context.Context, stage Stage, text string) (*Decision, error) {
+	decision := &Decision{Action: Allow}
+	if !g.Enabled(stage) || strings.TrimSpace(text) == "" {
+		return decision, nil
+	}
+
+	categories, err := g.Moderator.Categories(ctx, text)
+	if err != nil {
+		return decision, err
+	}
+
+	decision.Categories = categories
+	for _, category := range categories {
+		if action := g.Policy.Action(category); severity[action] > severity[decision.Action] {
+			decision.Action = action
+		}
+	}
+	return decision, nil
+}
+
+type OpenAIModerationRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+type OpenAIModerationResponse struct {
+	Results []struct {
+		Flagged    bool            `json:"flagged"`
+		Categories map[string]bool `json:"categories"`
+	} `json:"results"`
+}
+
+// OpenAIModerator talks to the OpenAI moderations endpoint.
+type OpenAIModerator struct {
+	ApiKey string
+	Url    string
+	Model  string
+}
+
+func (o *OpenAIModerator) Categories(ctx context.Context, text string) ([]string, error) {
+	resp, err := newRequest(ctx).
+		SetAuthToken(o.ApiKey).
+		SetResult(OpenAIModerationResponse{}).
+		SetBody(OpenAIModerationRequest{
+			Model: o.Model,
+			Input: text,
+		}).
+		Post(o.Url)
+
+	utils.PrintRestyDebug(ctx, resp, err)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() < 200 || resp.StatusCode() > 299 {
+		return nil, fmt.Errorf("moderation request failed with response code: %d", resp.StatusCode())
+	}
+
+	var categories []string
+	for _, result := range resp.Result().(*OpenAIModerationResponse).Results {
+		if !result.Flagged {
+			continue
+		}
+		for category, flagged := range result.Categories {
+			if flagged {
+				categories = append(categories, category)
+			}
+		}
+	}
+	return unique(categories), nil
+}
+
+type KeywordRule struct {
+	Category string
+	Pattern  *regexp.Regexp
+}
+
+// KeywordModerator flags the texts matching its rules, without calling
+// any service.
+type KeywordModerator struct {
+	Rules []KeywordRule
+}
+
+// ParseKeywords reads one rule per line as category: regular expression,
+// e.g. "credentials: (?:password|passwd)\s*[:=]". The expressions ignore
+// case.
+func ParseKeywords(value string) (*KeywordModerator, error) {
+	moderator := &KeywordModerator{}
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		category, expression, ok := strings.Cut(line, ":")
+		category = strings.ToLower(strings.TrimSpace(category))
+		expression = strings.TrimSpace(expression)
+		if !ok || category == "" || expression == "" {
+			return nil, fmt.Errorf("invalid keyword rule %s, use <category>: <regular expression>", line)
+		}
+
+		pattern, err := regexp.Compile("(?i)" + expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keyword rule %s: %w", line, err)
+		}
+		moderator.Rules = append(moderator.Rules, KeywordRule{
+			Category: category,
+			Pattern:  pattern,
+		})
+	}
+	return moderator, nil
+}
+
+func (k *KeywordModerator) Categories(ctx context.Context, text string) ([]string, error) {
+	var categories []string
+	for _, rule := range k.Rules {
+		if rule.Pattern.MatchString(text) {
+			categories = append(categories, rule.Category)
+		}
+	}
+	return unique(categories), nil
+}
+
+// multiModerator flags the categories of every moderator.
+type multiModerator []Moderator
+
+func (m multiModerator) Categories(ctx context.Context, text string) ([]string, error) {
+	var categories []string
+	for _, moderator := range m {
+		flagged, err := moderator.Categories(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, flagged...)
+	}
+	return unique(categories), nil
+}
+
+func unique(categories []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, category := range categories {
+		if !seen[category] {
+			seen[category] = true
+			result = append(result, category)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func newRequest(ctx context.Context) *resty.Request {
+	client := resty.New()
+	client.SetTimeout(30 * time.Second)
+	req := client.R().
+		SetContext(ctx).
+		SetHeader("content-type", "application/json").
+		EnableTrace()
+	if id := logger.CorrelationId(ctx); id != "" {
+		req.SetHeader(consts.CorrelationIdHeader, id)
+	}
+	return req
+}
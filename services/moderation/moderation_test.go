@@ -0,0 +1,241 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeModerator struct {
+	categories []string
+	err        error
+	calls      int
+}
+
+func (f *fakeModerator) Categories(ctx context.Context, text string) ([]string, error) {
+	f.calls++
+	return f.categories, f.err
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  Policy
+	}{
+		{"empty", "", Policy{Default: Block, Actions: map[string]Action{}}},
+		{
+			name:  "default and categories",
+			value: "default=warn, Self-Harm=LOG,violence=allow,",
+			want:  Policy{Default: Warn, Actions: map[string]Action{"self-harm": Log, "violence": Allow}},
+		},
+		{
+			name:  "subcategory",
+			value: "hate/threatening=block",
+			want:  Policy{Default: Block, Actions: map[string]Action{"hate/threatening": Block}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParsePolicy(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, policy)
+		})
+	}
+}
+
+func TestParsePolicyErrors(t *testing.T) {
+	tests := []string{
+		"violence",
+		"violence=ban",
+		"default=block,hate=",
+	}
+
+	for _, value := range tests {
+		t.Run(value, func(t *testing.T) {
+			_, err := ParsePolicy(value)
+			assert.ErrorContains(t, err, "invalid moderation policy")
+		})
+	}
+}
+
+func TestPolicyAction(t *testing.T) {
+	policy := Policy{
+		Default: Block,
+		Actions: map[string]Action{
+			"hate":             Warn,
+			"hate/threatening": Block,
+			"violence":         Log,
+		},
+	}
+
+	tests := []struct {
+		category string
+		want     Action
+	}{
+		{"hate", Warn},
+		{"Hate/Threatening", Block},
+		{"hate/other", Warn},
+		{"violence/graphic", Log},
+		{"sexual", Block},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.category, func(t *testing.T) {
+			assert.Equal(t, tt.want, policy.Action(tt.category))
+		})
+	}
+}
+
+func TestGateCheck(t *testing.T) {
+	policy := Policy{
+		Default: Block,
+		Actions: map[string]Action{"violence": Log, "self-harm": Warn},
+	}
+
+	tests := []struct {
+		name       string
+		stage      Stage
+		text       string
+		categories []string
+		want       Action
+		wantCalls  int
+	}{
+		{"not flagged", Prompts, "hello", nil, Allow, 1},
+		{"logged", Prompts, "text", []string{"violence"}, Log, 1},
+		{"most severe wins", Prompts, "text", []string{"violence", "self-harm"}, Warn, 1},
+		{"default action", Prompts, "text", []string{"violence", "sexual"}, Block, 1},
+		{"disabled stage", Images, "text", []string{"sexual"}, Allow, 0},
+		{"blank text", Prompts, "  ", []string{"sexual"}, Allow, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			moderator := &fakeModerator{categories: tt.categories}
+			gate := &Gate{Moderator: moderator, Policy: policy, Stages: []Stage{Prompts, Outputs}}
+
+			decision, err := gate.Check(context.Background(), tt.stage, tt.text)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, decision.Action)
+			assert.Equal(t, tt.wantCalls, moderator.calls)
+		})
+	}
+}
+
+func TestGateCheckError(t *testing.T) {
+	gate := &Gate{Moderator: &fakeModerator{err: errors.New("unavailable")}, Stages: []Stage{Prompts}}
+
+	decision, err := gate.Check(context.Background(), Prompts, "hello")
+	assert.EqualError(t, err, "unavailable")
+	assert.Equal(t, Allow, decision.Action)
+	assert.False(t, decision.Flagged())
+}
+
+func TestNilGate(t *testing.T) {
+	var gate *Gate
+
+	assert.False(t, gate.Enabled(Prompts))
+	decision, err := gate.Check(context.Background(), Prompts, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, Allow, decision.Action)
+}
+
+func TestKeywordModerator(t *testing.T) {
+	moderator, err := ParseKeywords(`
+# secrets shared in the chat
+Credentials: (?:password|passwd)\s*[:=]
+credentials: api[_-]?key
+spam: \bfree money\b
+`)
+	require.NoError(t, err)
+	require.Len(t, moderator.Rules, 3)
+
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"hello there", nil},
+		{"my PASSWORD: hunter2", []string{"credentials"}},
+		{"password=1 and api_key=2", []string{"credentials"}},
+		{"Free Money for your api-key", []string{"credentials", "spam"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			categories, err := moderator.Categories(context.Background(), tt.text)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, categories)
+		})
+	}
+}
+
+func TestParseKeywordsErrors(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"no separator", "invalid keyword rule no separator"},
+		{": password", "invalid keyword rule : password"},
+		{"credentials:", "invalid keyword rule credentials:"},
+		{"credentials: (password", "invalid keyword rule credentials: (password: error parsing regexp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			_, err := ParseKeywords(tt.value)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestMultiModerator(t *testing.T) {
+	moderator := multiModerator{
+		&fakeModerator{categories: []string{"violence", "hate"}},
+		&fakeModerator{categories: []string{"hate", "credentials"}},
+	}
+
+	categories, err := moderator.Categories(context.Background(), "text")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"credentials", "hate", "violence"}, categories)
+
+	moderator = append(moderator, &fakeModerator{err: errors.New("unavailable")})
+	_, err = moderator.Categories(context.Background(), "text")
+	assert.EqualError(t, err, "unavailable")
+}
+
+func TestOpenAIModerator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request OpenAIModerationRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if request.Input == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"results":[
+			{"flagged":true,"categories":{"violence":true,"hate":false,"self-harm":true}},
+			{"flagged":false,"categories":{"sexual":true}}
+		]}`))
+	}))
+	defer server.Close()
+
+	moderator := &OpenAIModerator{ApiKey: "key", Url: server.URL, Model: defaultOpenAIModel}
+
+	categories, err := moderator.Categories(context.Background(), "text")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"self-harm", "violence"}, categories)
+
+	_, err = moderator.Categories(context.Background(), "fail")
+	assert.EqualError(t, err, "moderation request failed with response code: 500")
+}
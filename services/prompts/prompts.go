@@ -0,0 +1,187 @@
+package prompts
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+
+	"gopkg.in/yaml.v3"
+)
+
+const MaxTemplateLength = 4000
+
+var (
+	namePattern        = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+	placeholderPattern = regexp.MustCompile(`\{\{\s*input\s*\}\}`)
+)
+
+// Render replaces the {{input}} placeholders of the template with the
+// input. A template without placeholders gets the input appended after a
+// blank line.
+func Render(template string, input string) string {
+	input = strings.TrimSpace(input)
+	if placeholderPattern.MatchString(template) {
+		return placeholderPattern.ReplaceAllLiteralString(template, input)
+	}
+	if input == "" {
+		return template
+	}
+	return template + "\n\n" + input
+}
+
+// NormalizeName returns the name in lower case, checking that it only has
+// letters, digits, - and _.
+func NormalizeName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !namePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid prompt name %s, use up to 32 letters, digits, - and _", name)
+	}
+	return name, nil
+}
+
+func ValidateTemplate(template string) error {
+	if strings.TrimSpace(template) == "" {
+		return fmt.Errorf("the template is empty")
+	}
+	if len([]rune(template)) > MaxTemplateLength {
+		return fmt.Errorf("the template is longer than %d characters", MaxTemplateLength)
+	}
+	return nil
+}
+
+// Entry is a prompt of the library file.
+type Entry struct {
+	Name     string `yaml:"name"`
+	Scope    string `yaml:"scope"`
+	Owner    string `yaml:"owner"`
+	Template string `yaml:"template"`
+}
+
+// Library is the YAML file used to import and export saved prompts.
+type Library struct {
+	Prompts []Entry `yaml:"prompts"`
+}
+
+func ParseLibrary(content []byte) (*Library, error) {
+	library := &Library{}
+	err := yaml.Unmarshal(content, library)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range library.Prompts {
+		entry := &library.Prompts[i]
+		entry.Name, err = NormalizeName(entry.Name)
+		if err != nil {
+			return nil, fmt.Errorf("prompt %d: %w", i+1, err)
+		}
+		if entry.Scope == "" {
+			entry.Scope = db.PersonalPrompt
+		}
+		if entry.Scope != db.PersonalPrompt && entry.Scope != db.TeamPrompt {
+			return nil, fmt.Errorf("prompt %s: unknown scope %s, use personal or team", entry.Name, entry.Scope)
+		}
+		if err := ValidateTemplate(entry.Template); err != nil {
+			return nil, fmt.Errorf("prompt %s: %w", entry.Name, err)
+		}
+	}
+	return library, nil
+}
+
+func (l *Library) Marshal() ([]byte, error) {
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	err := encoder.Encode(l)
+	if err == nil {
+		err = encoder.Close()
+	}
+	return out.Bytes(), err
+}
+
+// Export reads the prompts of the owners into a library. An empty owner id
+// skips its scope.
+func Export(repository *db.PromptRepository, userId string, chatId string) (*Library, error) {
+	library := &Library{}
+	owners := []struct {
+		scope   string
+		ownerId string
+	}{
+		{db.PersonalPrompt, userId},
+		{db.TeamPrompt, chatId},
+	}
+
+	for _, owner := range owners {
+		if owner.ownerId == "" {
+			continue
+		}
+		saved, err := repository.ListPrompts(owner.scope, owner.ownerId)
+		if err != nil {
+			return nil, err
+		}
+		for _, prompt := range saved {
+			library.Prompts = append(library.Prompts, Entry{
+				Name:     prompt.Name,
+				Scope:    prompt.Scope,
+				Owner:    prompt.OwnerId,
+				Template: prompt.Template,
+			})
+		}
+	}
+	return library, nil
+}
+
+// ImportSummary counts the prompts saved and skipped by Import.
+type ImportSummary struct {
+	Saved   int
+	Skipped []string
+}
+
+// Import saves the prompts of the library. The user and chat ids replace
+// the owners of the personal and team prompts when set, so a library can
+// be copied to another owner. Existing prompts are kept unless overwrite
+// is set.
+func Import(repository *db.PromptRepository, library *Library, userId string, chatId string, overwrite bool) (*ImportSummary, error) {
+	summary := &ImportSummary{}
+	for _, entry := range library.Prompts {
+		owner := entry.Owner
+		if entry.Scope == db.PersonalPrompt && userId != "" {
+			owner = userId
+		}
+		if entry.Scope == db.TeamPrompt && chatId != "" {
+			owner = chatId
+		}
+		if owner == "" {
+			return summary, fmt.Errorf("prompt %s has no owner", entry.Name)
+		}
+
+		if !overwrite {
+			existing, err := repository.GetPrompt(entry.Scope, owner, entry.Name)
+			if err != nil {
+				return summary, err
+			}
+			if existing != nil {
+				summary.Skipped = append(summary.Skipped, entry.Name)
+				continue
+			}
+		}
+
+		err := repository.SavePrompt(&db.PromptTemplate{
+			Scope:    entry.Scope,
+			OwnerId:  owner,
+			Name:     entry.Name,
+			Template: entry.Template,
+		})
+		if err != nil {
+			return summary, err
+		}
+		summary.Saved++
+	}
+
+	sort.Strings(summary.Skipped)
+	return summary, nil
+}
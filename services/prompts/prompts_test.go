@@ -0,0 +1,132 @@
+package prompts
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		input    string
+		want     string
+	}{
+		{"placeholder", "Review this SQL:\n{{input}}", " select 1 ", "Review this SQL:\nselect 1"},
+		{"spaced placeholders", "{{ input }} and {{input  }}", "x", "x and x"},
+		{"literal input", "Say {{input}}", "$1 {{input}}", "Say $1 {{input}}"},
+		{"appended", "Translate to French.", "good morning", "Translate to French.\n\ngood morning"},
+		{"no input", "Tell me a joke.", "  ", "Tell me a joke."},
+		{"empty placeholder", "Input: {{input}}.", "", "Input: ."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Render(tt.template, tt.input))
+		})
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"review-sql", "review-sql", false},
+		{"  Review_SQL ", "review_sql", false},
+		{"a1", "a1", false},
+		{strings.Repeat("a", 32), strings.Repeat("a", 32), false},
+		{strings.Repeat("a", 33), "", true},
+		{"-review", "", true},
+		{"review sql", "", true},
+		{"revisão", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, err := NormalizeName(tt.name)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "invalid prompt name")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, name)
+		})
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"valid", "Summarize {{input}}", ""},
+		{"empty", " \n ", "the template is empty"},
+		{"at the limit", strings.Repeat("é", MaxTemplateLength), ""},
+		{"too long", strings.Repeat("a", MaxTemplateLength+1), "the template is longer than 4000 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTemplate(tt.template)
+			if tt.want == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLibrary(t *testing.T) {
+	library, err := ParseLibrary([]byte(`
+prompts:
+  - name: Review-SQL
+    template: "Review this SQL: {{input}}"
+  - name: standup
+    scope: team
+    owner: "-100"
+    template: |
+      Write the standup notes.
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Entry{
+		{Name: "review-sql", Scope: db.PersonalPrompt, Template: "Review this SQL: {{input}}"},
+		{Name: "standup", Scope: db.TeamPrompt, Owner: "-100", Template: "Write the standup notes.\n"},
+	}, library.Prompts)
+
+	content, err := library.Marshal()
+	require.NoError(t, err)
+	parsed, err := ParseLibrary(content)
+	require.NoError(t, err)
+	assert.Equal(t, library, parsed)
+}
+
+func TestParseLibraryErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"invalid yaml", "prompts: [", "yaml:"},
+		{"invalid name", "prompts:\n  - name: bad name\n    template: x", "prompt 1: invalid prompt name bad name"},
+		{"unknown scope", "prompts:\n  - name: a\n    scope: global\n    template: x", "prompt a: unknown scope global"},
+		{"empty template", "prompts:\n  - name: a\n    template: ''", "prompt a: the template is empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseLibrary([]byte(tt.content))
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// MaxDelay bounds how far ahead a reminder can be set.
+	MaxDelay = 366 * 24 * time.Hour
+	// MinInterval bounds how often a scheduled prompt runs.
+	MinInterval = 15 * time.Minute
+)
+
+var ErrMissingText = errors.New("inform the text")
+
+// ParseWhen splits "<when> <text>" and returns the time the text is due.
+// The time is a delay such as 45m, 2h30m or 3d, a clock time HH:MM (today
+// or tomorrow, whichever comes first), "tomorrow HH:MM" or a date and time
+// "YYYY-MM-DD HH:MM", read in the given location.
+func ParseWhen(text string, now time.Time, location *time.Location) (time.Time, string, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return time.Time{}, "", errors.New("inform when")
+	}
+
+	local := now.In(location)
+	var due time.Time
+	used := 1
+
+	switch {
+	case strings.EqualFold(fields[0], "tomorrow"):
+		if len(fields) < 2 {
+			return time.Time{}, "", errors.New("inform the time, like tomorrow 09:00")
+		}
+		clock, err := time.ParseInLocation("15:04", fields[1], location)
+		if err != nil {
+			return time.Time{}, "", fmt.Errorf("invalid time %s", fields[1])
+		}
+		tomorrow := local.AddDate(0, 0, 1)
+		due = time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), clock.Hour(), clock.Minute(), 0, 0, location)
+		used = 2
+	case len(fields) > 1 && isDate(fields[0]):
+		date, err := time.ParseInLocation("2006-01-02 15:04", fields[0]+" "+fields[1], location)
+		if err != nil {
+			return time.Time{}, "", fmt.Errorf("invalid date %s %s", fields[0], fields[1])
+		}
+		due = date
+		used = 2
+	case strings.Contains(fields[0], ":"):
+		clock, err := time.ParseInLocation("15:04", fields[0], location)
+		if err != nil {
+			return time.Time{}, "", fmt.Errorf("invalid time %s", fields[0])
+		}
+		due = time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, location)
+		if !due.After(local) {
+			due = due.AddDate(0, 0, 1)
+		}
+	default:
+		delay, err := parseDelay(fields[0])
+		if err != nil {
+			return time.Time{}, "", err
+		}
+		due = now.Add(delay)
+	}
+
+	if !due.After(now) {
+		return time.Time{}, "", errors.New("the time has already passed")
+	}
+	if due.Sub(now) > MaxDelay {
+		return time.Time{}, "", errors.New("the time is more than a year ahead")
+	}
+
+	rest := strings.Join(fields[used:], " ")
+	if rest == "" {
+		return time.Time{}, "", ErrMissingText
+	}
+	return due, rest, nil
+}
+
+func isDate(value string) bool {
+	_, err := time.Parse("2006-01-02", value)
+	return err == nil
+}
+
+// parseDelay parses a Go duration that may start with a number of days,
+// like 1d12h.
+func parseDelay(value string) (time.Duration, error) {
+	var delay time.Duration
+
+	lower := strings.ToLower(value)
+	if i := strings.Index(lower, "d"); i > 0 {
+		days, err := strconv.Atoi(lower[:i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid delay %s", value)
+		}
+		delay = time.Duration(days) * 24 * time.Hour
+		lower = lower[i+1:]
+	}
+
+	if lower != "" {
+		duration, err := time.ParseDuration(lower)
+		if err != nil {
+			return 0, fmt.Errorf("invalid delay %s", value)
+		}
+		delay += duration
+	}
+
+	if delay <= 0 {
+		return 0, fmt.Errorf("invalid delay %s", value)
+	}
+	return delay, nil
+}
+
+// ParseCron splits "<cron> <text>" and returns the cron expression. The
+// expression has the five standard fields, optionally quoted, or is a
+// descriptor such as @daily or @every 2h, and can start with
+// CRON_TZ=<time zone>.
+func ParseCron(text string) (string, string, error) {
+	text = strings.TrimSpace(text)
+
+	var spec, rest string
+	if strings.HasPrefix(text, `"`) {
+		end := strings.Index(text[1:], `"`)
+		if end < 0 {
+			return "", "", errors.New("the cron expression is not closed")
+		}
+		spec = text[1 : end+1]
+		rest = text[end+2:]
+	} else {
+		fields := strings.Fields(text)
+		start := 0
+		if len(fields) > 0 && (strings.HasPrefix(fields[0], "CRON_TZ=") || strings.HasPrefix(fields[0], "TZ=")) {
+			start = 1
+		}
+
+		count := start + 5
+		if len(fields) > start && fields[start] == "@every" {
+			count = start + 2
+		} else if len(fields) > start && strings.HasPrefix(fields[start], "@") {
+			count = start + 1
+		}
+		if len(fields) < count {
+			return "", "", errors.New("inform the cron expression")
+		}
+		spec = strings.Join(fields[:count], " ")
+		rest = strings.Join(fields[count:], " ")
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid cron expression %s: %v", spec, err)
+	}
+
+	first := schedule.Next(time.Now())
+	if schedule.Next(first).Sub(first) < MinInterval {
+		return "", "", fmt.Errorf("the prompt can run at most every %v", MinInterval)
+	}
+
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return "", "", ErrMissingText
+	}
+	return spec, rest, nil
+}
+
+// Next returns the first time after the given one the cron expression
+// fires. Expressions without CRON_TZ are read in the given location.
+func Next(spec string, after time.Time, location *time.Location) (time.Time, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.Next(after.In(location)), nil
+}
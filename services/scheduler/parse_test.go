@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	now      = time.Date(2024, 3, 10, 14, 30, 0, 0, time.UTC)
+	saoPaulo = time.FixedZone("BRT", -3*60*60)
+)
+
+func TestParseWhen(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		location *time.Location
+		want     time.Time
+		wantText string
+	}{
+		{"minutes", "45m call mom", time.UTC, now.Add(45 * time.Minute), "call mom"},
+		{"hours and minutes", "2h30m stretch", time.UTC, now.Add(150 * time.Minute), "stretch"},
+		{"days", "3d water the plants", time.UTC, now.Add(72 * time.Hour), "water the plants"},
+		{"days and hours", "1d12h check", time.UTC, now.Add(36 * time.Hour), "check"},
+		{"clock later today", "16:00 meeting", time.UTC, time.Date(2024, 3, 10, 16, 0, 0, 0, time.UTC), "meeting"},
+		{"clock already passed", "09:00 standup", time.UTC, time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC), "standup"},
+		{"clock in location", "12:00 lunch", saoPaulo, time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC), "lunch"},
+		{"tomorrow", "Tomorrow 08:15 run", time.UTC, time.Date(2024, 3, 11, 8, 15, 0, 0, time.UTC), "run"},
+		{"date", "2024-04-01 10:00 pay the rent", saoPaulo, time.Date(2024, 4, 1, 13, 0, 0, 0, time.UTC), "pay the rent"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due, text, err := ParseWhen(tt.text, now, tt.location)
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(due), "want %v, got %v", tt.want, due)
+			assert.Equal(t, tt.wantText, text)
+		})
+	}
+}
+
+func TestParseWhenErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", "inform when"},
+		{"tomorrow", "inform the time, like tomorrow 09:00"},
+		{"tomorrow 25:00 run", "invalid time 25:00"},
+		{"9:60 run", "invalid time 9:60"},
+		{"2024-04-01 25:00 run", "invalid date 2024-04-01 25:00"},
+		{"2024-01-01 10:00 run", "the time has already passed"},
+		{"400d run", "the time is more than a year ahead"},
+		{"soon run", "invalid delay soon"},
+		{"0m run", "invalid delay 0m"},
+		{"xd run", "invalid delay xd"},
+		{"45m", ErrMissingText.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			_, _, err := ParseWhen(tt.text, now, time.UTC)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		text     string
+		wantSpec string
+		wantText string
+	}{
+		{"0 9 * * 1-5 standup notes", "0 9 * * 1-5", "standup notes"},
+		{`"30 8 * * *" morning news`, "30 8 * * *", "morning news"},
+		{"@daily summary", "@daily", "summary"},
+		{"@every 2h ping", "@every 2h", "ping"},
+		{"CRON_TZ=America/Sao_Paulo 0 9 * * * hello", "CRON_TZ=America/Sao_Paulo 0 9 * * *", "hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			spec, text, err := ParseCron(tt.text)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSpec, spec)
+			assert.Equal(t, tt.wantText, text)
+		})
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`"0 9 * * * hello`, "the cron expression is not closed"},
+		{"0 9 * *", "inform the cron expression"},
+		{"@every", "inform the cron expression"},
+		{"61 9 * * * hello", "invalid cron expression 61 9 * * *"},
+		{"* * * * * hello", "the prompt can run at most every 15m0s"},
+		{"@every 5m hello", "the prompt can run at most every 15m0s"},
+		{"@daily", ErrMissingText.Error()},
+		{`"0 9 * * *"`, ErrMissingText.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			_, _, err := ParseCron(tt.text)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		location *time.Location
+		want     time.Time
+	}{
+		{"later today", "0 15 * * *", time.UTC, time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)},
+		{"tomorrow", "0 9 * * *", time.UTC, time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC)},
+		{"read in location", "0 15 * * *", saoPaulo, time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC)},
+		{"cron time zone wins", "CRON_TZ=UTC 0 15 * * *", saoPaulo, time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)},
+		{"every", "@every 2h", time.UTC, now.Add(2 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, err := Next(tt.spec, now, tt.location)
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(next), "want %v, got %v", tt.want, next)
+		})
+	}
+
+	_, err := Next("not a cron", now, time.UTC)
+	assert.Error(t, err)
+}
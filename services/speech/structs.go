@@ -0,0 +1,12 @@
+package speech
+
+type TranscriptionResponse struct {
+	Text string `json:"text"`
+}
+
+type SpeechRequest struct {
+	Model          string `json:"model"`
+	Input          string `json:"input"`
+	Voice          string `json:"voice"`
+	ResponseFormat string `json:"response_format,omitempty"`
+}
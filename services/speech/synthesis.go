@@ -0,0 +1,156 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+
+	"github.com/marlosl/gpt-telegram-bot/utils/config"
+)
+
+type SynthesisProvider string
+
+const (
+	OpenAITtsProvider SynthesisProvider = "openai"
+	PiperProvider     SynthesisProvider = "piper"
+
+	openAISpeechUrl = "https://api.openai.com/v1/audio/speech"
+	ttsModel        = "tts-1"
+	defaultTtsVoice = "alloy"
+
+	// MaxSpeechLength is the maximum input accepted by the OpenAI speech endpoint.
+	MaxSpeechLength = 4096
+)
+
+type AudioFormat string
+
+const (
+	Opus AudioFormat = "opus"
+	Wav  AudioFormat = "wav"
+)
+
+type Synthesizer interface {
+	Synthesize(ctx context.Context, text string) ([]byte, AudioFormat, error)
+}
+
+// NewSynthesizer returns the text-to-speech provider selected by the
+// configuration, falling back to OpenAI TTS when no provider is set.
+func NewSynthesizer() Synthesizer {
+	config.NewConfig(config.SSM)
+
+	voice := config.Store.TtsVoice
+	switch SynthesisProvider(strings.ToLower(config.Store.TtsProvider)) {
+	case PiperProvider:
+		if _, err := exec.LookPath("ffmpeg"); err != nil {
+			slog.Warn("Piper voice replies need ffmpeg, deploy with an ffmpeg layer or voice replies fall back to text")
+		}
+		return &PiperSynthesizer{
+			Url: config.Store.TtsUrl,
+		}
+	}
+
+	if voice == "" {
+		voice = defaultTtsVoice
+	}
+
+	speechUrl := config.Store.TtsUrl
+	if speechUrl == "" {
+		speechUrl = openAISpeechUrl
+	}
+	return &OpenAISynthesizer{
+		ApiKey: config.Store.GptApiKey,
+		Url:    speechUrl,
+		Voice:  voice,
+	}
+}
+
+type OpenAISynthesizer struct {
+	ApiKey string
+	Url    string
+	Voice  string
+}
+
+func (o *OpenAISynthesizer) Synthesize(ctx context.Context, text string) ([]byte, AudioFormat, error) {
+	resp, err := newRequest(ctx).
+		SetAuthToken(o.ApiKey).
+		SetHeader("content-type", "application/json").
+		SetBody(SpeechRequest{
+			Model:          ttsModel,
+			Input:          truncate(text, MaxSpeechLength),
+			Voice:          o.Voice,
+			ResponseFormat: string(Opus),
+		}).
+		Post(o.Url)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.StatusCode() < 200 || resp.StatusCode() > 299 {
+		return nil, "", fmt.Errorf("speech synthesis failed with response code: %d", resp.StatusCode())
+	}
+	return resp.Body(), Opus, nil
+}
+
+// PiperSynthesizer talks to the HTTP server shipped with Piper, which
+// answers with a WAV file for the text sent in the request body. The WAV is
+// encoded by EncodeOggOpus, so ffmpeg must be available, e.g. from the
+// layer set with FFMPEG_LAYER_ARN when deploying.
+type PiperSynthesizer struct {
+	Url string
+}
+
+func (p *PiperSynthesizer) Synthesize(ctx context.Context, text string) ([]byte, AudioFormat, error) {
+	resp, err := newRequest(ctx).
+		SetHeader("content-type", "text/plain; charset=utf-8").
+		SetBody(truncate(text, MaxSpeechLength)).
+		Post(p.Url)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.StatusCode() < 200 || resp.StatusCode() > 299 {
+		return nil, "", fmt.Errorf("speech synthesis failed with response code: %d", resp.StatusCode())
+	}
+	return resp.Body(), Wav, nil
+}
+
+// EncodeOggOpus converts the audio to OGG/Opus, the only format Telegram
+// displays as a voice note. Formats other than Opus require ffmpeg in PATH;
+// on Lambda a layer puts it in /opt/bin.
+func EncodeOggOpus(audio []byte, format AudioFormat) ([]byte, error) {
+	if format == Opus {
+		return audio, nil
+	}
+
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, errors.New("ffmpeg is required to encode voice notes")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(ffmpeg, "-hide_banner", "-loglevel", "error",
+		"-f", string(format), "-i", "pipe:0",
+		"-c:a", "libopus", "-b:a", "32k", "-f", "ogg", "pipe:1")
+	cmd.Stdin = bytes.NewReader(audio)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("encoding voice note: %v: %s", err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
+func truncate(text string, size int) string {
+	runes := []rune(text)
+	if len(runes) <= size {
+		return text
+	}
+	return string(runes[:size])
+}
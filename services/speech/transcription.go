@@ -0,0 +1,119 @@
+package speech
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+	"github.com/marlosl/gpt-telegram-bot/utils"
+	"github.com/marlosl/gpt-telegram-bot/utils/config"
+	"github.com/marlosl/gpt-telegram-bot/utils/logger"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type TranscriptionProvider string
+
+const (
+	OpenAIProvider     TranscriptionProvider = "openai"
+	WhisperCppProvider TranscriptionProvider = "whispercpp"
+
+	openAITranscriptionUrl = "https://api.openai.com/v1/audio/transcriptions"
+	whisperModel           = "whisper-1"
+)
+
+type Transcriber interface {
+	Transcribe(ctx context.Context, filename string, audio io.Reader) (string, error)
+}
+
+// NewTranscriber returns the transcriber selected by the configuration,
+// falling back to OpenAI Whisper when no provider is set.
+func NewTranscriber() Transcriber {
+	config.NewConfig(config.SSM)
+
+	switch TranscriptionProvider(strings.ToLower(config.Store.TranscriptionProvider)) {
+	case WhisperCppProvider:
+		return &WhisperCppTranscriber{
+			Url: strings.TrimSuffix(config.Store.TranscriptionUrl, "/") + "/inference",
+		}
+	}
+
+	transcriptionUrl := config.Store.TranscriptionUrl
+	if transcriptionUrl == "" {
+		transcriptionUrl = openAITranscriptionUrl
+	}
+	return &OpenAITranscriber{
+		ApiKey: config.Store.GptApiKey,
+		Url:    transcriptionUrl,
+	}
+}
+
+type OpenAITranscriber struct {
+	ApiKey string
+	Url    string
+}
+
+func (o *OpenAITranscriber) Transcribe(ctx context.Context, filename string, audio io.Reader) (string, error) {
+	resp, err := newRequest(ctx).
+		SetAuthToken(o.ApiKey).
+		SetResult(TranscriptionResponse{}).
+		SetFileReader("file", filename, audio).
+		SetFormData(map[string]string{
+			"model":           whisperModel,
+			"response_format": "json",
+		}).
+		Post(o.Url)
+
+	return parseTranscription(ctx, resp, err)
+}
+
+// WhisperCppTranscriber talks to the HTTP server shipped with whisper.cpp.
+// The server must be started with --convert so it accepts OGG/Opus voice notes.
+type WhisperCppTranscriber struct {
+	Url string
+}
+
+func (w *WhisperCppTranscriber) Transcribe(ctx context.Context, filename string, audio io.Reader) (string, error) {
+	resp, err := newRequest(ctx).
+		SetResult(TranscriptionResponse{}).
+		SetFileReader("file", filename, audio).
+		SetFormData(map[string]string{
+			"response_format": "json",
+			"temperature":     "0.0",
+		}).
+		Post(w.Url)
+
+	return parseTranscription(ctx, resp, err)
+}
+
+func newRequest(ctx context.Context) *resty.Request {
+	client := resty.New()
+	client.SetTimeout(2 * time.Minute)
+	req := client.R().SetContext(ctx).EnableTrace()
+	if id := logger.CorrelationId(ctx); id != "" {
+		req.SetHeader(consts.CorrelationIdHeader, id)
+	}
+	return req
+}
+
+func parseTranscription(ctx context.Context, resp *resty.Response, err error) (string, error) {
+	utils.PrintRestyDebug(ctx, resp, err)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode() < 200 || resp.StatusCode() > 299 {
+		return "", fmt.Errorf("transcription failed with response code: %d", resp.StatusCode())
+	}
+
+	transcription := resp.Result().(*TranscriptionResponse)
+	text := strings.TrimSpace(transcription.Text)
+	if text == "" {
+		return "", errors.New("transcription is empty")
+	}
+	return text, nil
+}
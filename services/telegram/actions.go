@@ -0,0 +1,67 @@
+package telegram
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type ChatAction string
+
+const (
+	TypingAction         ChatAction = "typing"
+	UploadPhotoAction    ChatAction = "upload_photo"
+	RecordVoiceAction    ChatAction = "record_voice"
+	UploadDocumentAction ChatAction = "upload_document"
+)
+
+// ChatActionInterval is how often a chat action is repeated. Telegram shows
+// an action for 5 seconds or until the bot sends a message.
+const ChatActionInterval = 4 * time.Second
+
+// chatActionInterval is the refresh interval in use, shortened by the tests.
+var chatActionInterval = ChatActionInterval
+
+// StartChatAction sends the action to the chat right away and keeps
+// refreshing it until the returned function is called or ctx is done. The
+// returned function waits for the refresh to stop, so no action is sent
+// after it returns.
+func (t *Telegram) StartChatAction(ctx context.Context, chatId string, action ChatAction) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	t.sendChatAction(ctx, chatId, action)
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(chatActionInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.sendChatAction(ctx, chatId, action)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (t *Telegram) sendChatAction(ctx context.Context, chatId string, action ChatAction) {
+	if chatId == "" {
+		return
+	}
+
+	err := t.Client.SendChatAction(ctx, &SendChatActionRequest{
+		ChatId: chatId,
+		Action: string(action),
+	})
+	if err != nil && ctx.Err() == nil {
+		slog.WarnContext(ctx, "Error sending chat action", "action", action, "error", err)
+	}
+}
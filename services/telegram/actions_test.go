@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// actionServer records the chat actions sent to it.
+type actionServer struct {
+	*httptest.Server
+
+	mutex   sync.Mutex
+	actions []SendChatActionRequest
+}
+
+func newActionServer() *actionServer {
+	s := &actionServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request SendChatActionRequest
+		json.NewDecoder(r.Body).Decode(&request)
+
+		s.mutex.Lock()
+		s.actions = append(s.actions, request)
+		s.mutex.Unlock()
+		w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	return s
+}
+
+func (s *actionServer) count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.actions)
+}
+
+func withChatActionInterval(t *testing.T, interval time.Duration) {
+	previous := chatActionInterval
+	chatActionInterval = interval
+	t.Cleanup(func() { chatActionInterval = previous })
+}
+
+func TestStartChatAction(t *testing.T) {
+	withChatActionInterval(t, 20*time.Millisecond)
+	server := newActionServer()
+	defer server.Close()
+
+	telegram := &Telegram{Client: NewClient(server.URL)}
+	stop := telegram.StartChatAction(context.Background(), "42", TypingAction)
+	time.Sleep(110 * time.Millisecond)
+	stop()
+
+	sent := server.count()
+	assert.GreaterOrEqual(t, sent, 3, "the action is sent right away and refreshed")
+	for _, action := range server.actions {
+		assert.Equal(t, SendChatActionRequest{ChatId: "42", Action: "typing"}, action)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, sent, server.count(), "no action is sent after stop returns")
+}
+
+func TestStartChatActionStoppedRightAway(t *testing.T) {
+	server := newActionServer()
+	defer server.Close()
+
+	telegram := &Telegram{Client: NewClient(server.URL)}
+	telegram.StartChatAction(context.Background(), "42", UploadPhotoAction)()
+
+	assert.Equal(t, 1, server.count())
+	assert.Equal(t, "upload_photo", server.actions[0].Action)
+}
+
+func TestStartChatActionStopsWithContext(t *testing.T) {
+	withChatActionInterval(t, 20*time.Millisecond)
+	server := newActionServer()
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	telegram := &Telegram{Client: NewClient(server.URL)}
+	stop := telegram.StartChatAction(ctx, "42", RecordVoiceAction)
+	cancel()
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, 1, server.count())
+	stop()
+}
+
+func TestStartChatActionWithoutChat(t *testing.T) {
+	server := newActionServer()
+	defer server.Close()
+
+	telegram := &Telegram{Client: NewClient(server.URL)}
+	telegram.StartChatAction(context.Background(), "", TypingAction)()
+
+	assert.Equal(t, 0, server.count())
+}
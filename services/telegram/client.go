@@ -0,0 +1,297 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/utils/metrics"
+	"github.com/marlosl/gpt-telegram-bot/utils/tracing"
+)
+
+const (
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 3
+)
+
+// Client is a typed Bot API client. Requests are sent as JSON POST bodies
+// and the ok/result/description envelope is decoded into the result.
+type Client struct {
+	baseUrl    string
+	httpClient *http.Client
+	MaxRetries int
+}
+
+type APIError struct {
+	ErrorCode       int
+	Description     string
+	RetryAfter      int
+	MigrateToChatId int64
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram error %d: %s", e.ErrorCode, e.Description)
+}
+
+// chatRequest is implemented by requests addressed to a chat, so they can be
+// retried when a group is migrated to a supergroup.
+type chatRequest interface {
+	setChatId(chatId string)
+}
+
+func NewClient(baseUrl string) *Client {
+	return &Client{
+		baseUrl: baseUrl,
+		httpClient: &http.Client{
+			Timeout: defaultTimeout,
+		},
+		MaxRetries: defaultMaxRetries,
+	}
+}
+
+// Call invokes the method and decodes its result. Flood limits are retried
+// after retry_after seconds and chat migrations are retried with the new
+// chat id, up to MaxRetries times.
+func (c *Client) Call(ctx context.Context, method string, request interface{}, result interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = c.call(ctx, method, body, result)
+
+		var apiError *APIError
+		if err == nil || attempt >= c.MaxRetries || !errors.As(err, &apiError) {
+			return err
+		}
+
+		switch {
+		case apiError.RetryAfter > 0:
+			slog.WarnContext(ctx, "Flood limit reached, retrying", "method", method, "retry_after", apiError.RetryAfter)
+			if err := sleep(ctx, time.Duration(apiError.RetryAfter)*time.Second); err != nil {
+				return err
+			}
+		case apiError.MigrateToChatId != 0:
+			chat, ok := request.(chatRequest)
+			if !ok {
+				return err
+			}
+			slog.WarnContext(ctx, "Chat migrated, retrying", "method", method, "migrate_to_chat_id", apiError.MigrateToChatId)
+			chat.setChatId(strconv.FormatInt(apiError.MigrateToChatId, 10))
+			if body, err = json.Marshal(request); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+	}
+}
+
+func (c *Client) call(ctx context.Context, method string, body []byte, result interface{}) (err error) {
+	ctx, span := tracing.Start(ctx, "telegram."+method)
+	defer func() { tracing.End(span, err) }()
+	defer func() { recordError(method, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseUrl+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, err := c.httpClient.Do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error calling Telegram", "method", method, "error", err)
+		return err
+	}
+	return decodeResponse(rsp, result)
+}
+
+// decodeResponse reads the ok/description/error_code envelope returned by
+// every Bot API method, returning an *APIError when the call failed.
+func decodeResponse(response *http.Response, result interface{}) error {
+	defer response.Body.Close()
+
+	var apiResponse APIResponse
+	err := json.NewDecoder(response.Body).Decode(&apiResponse)
+	if err != nil {
+		return fmt.Errorf("invalid response with code %d: %v", response.StatusCode, err)
+	}
+
+	if !apiResponse.Ok {
+		apiError := &APIError{
+			ErrorCode:   apiResponse.ErrorCode,
+			Description: apiResponse.Description,
+		}
+		if apiResponse.Parameters != nil {
+			apiError.RetryAfter = apiResponse.Parameters.RetryAfter
+			apiError.MigrateToChatId = apiResponse.Parameters.MigrateToChatId
+		}
+		return apiError
+	}
+
+	if result == nil || len(apiResponse.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(apiResponse.Result, result)
+}
+
+// recordError counts failed calls by method and error code. Transport
+// failures, which have no error code, are counted as "network".
+func recordError(method string, err error) {
+	if err == nil {
+		return
+	}
+
+	code := "network"
+	var apiError *APIError
+	if errors.As(err, &apiError) {
+		code = strconv.Itoa(apiError.ErrorCode)
+	}
+	metrics.Increment("TelegramErrors", metrics.Dimensions{
+		"Method":    method,
+		"ErrorCode": code,
+	})
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (c *Client) SendMessage(ctx context.Context, request *SendMessageRequest) (*Message, error) {
+	var message Message
+	err := c.Call(ctx, "sendMessage", request, &message)
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (c *Client) EditMessageText(ctx context.Context, request *EditMessageTextRequest) (*Message, error) {
+	var message Message
+	err := c.Call(ctx, "editMessageText", request, &message)
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// EditMessageMedia replaces the media of a message. Editing an inline
+// message returns no message, so none is returned here.
+func (c *Client) EditMessageMedia(ctx context.Context, request *EditMessageMediaRequest) error {
+	return c.Call(ctx, "editMessageMedia", request, nil)
+}
+
+func (c *Client) DeleteMessage(ctx context.Context, request *DeleteMessageRequest) error {
+	return c.Call(ctx, "deleteMessage", request, nil)
+}
+
+func (c *Client) SendChatAction(ctx context.Context, request *SendChatActionRequest) error {
+	return c.Call(ctx, "sendChatAction", request, nil)
+}
+
+func (c *Client) SendPhoto(ctx context.Context, request *SendPhotoRequest) (*Message, error) {
+	var message Message
+	err := c.Call(ctx, "sendPhoto", request, &message)
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (c *Client) SendMediaGroup(ctx context.Context, request *SendMediaGroupRequest) ([]Message, error) {
+	var messages []Message
+	err := c.Call(ctx, "sendMediaGroup", request, &messages)
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (c *Client) GetFile(ctx context.Context, fileId string) (*File, error) {
+	var file File
+	err := c.Call(ctx, "getFile", &GetFileRequest{FileId: fileId}, &file)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.FilePath == "" {
+		return nil, errors.New("getFile returned an empty file path")
+	}
+	return &file, nil
+}
+
+func (c *Client) SetWebhook(ctx context.Context, request *SetWebhookRequest) error {
+	return c.Call(ctx, "setWebhook", request, nil)
+}
+
+func (c *Client) GetWebhookInfo(ctx context.Context) (*WebhookInfo, error) {
+	var info WebhookInfo
+	err := c.Call(ctx, "getWebhookInfo", struct{}{}, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (c *Client) GetMe(ctx context.Context) (*From, error) {
+	var me From
+	err := c.Call(ctx, "getMe", struct{}{}, &me)
+	if err != nil {
+		return nil, err
+	}
+	return &me, nil
+}
+
+func (c *Client) GetChatMember(ctx context.Context, request *GetChatMemberRequest) (*ChatMember, error) {
+	var member ChatMember
+	err := c.Call(ctx, "getChatMember", request, &member)
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (c *Client) AnswerCallbackQuery(ctx context.Context, request *AnswerCallbackQueryRequest) error {
+	return c.Call(ctx, "answerCallbackQuery", request, nil)
+}
+
+func (c *Client) AnswerInlineQuery(ctx context.Context, request *AnswerInlineQueryRequest) error {
+	return c.Call(ctx, "answerInlineQuery", request, nil)
+}
+
+// Download streams a file resolved by GetFile from fileUrl. The caller is
+// responsible for closing the returned reader.
+func (c *Client) Download(ctx context.Context, fileUrl string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Downloads can outlast the API timeout, the context bounds them instead.
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		rsp.Body.Close()
+		return nil, fmt.Errorf("download failed with response code: %d", rsp.StatusCode)
+	}
+	return rsp.Body, nil
+}
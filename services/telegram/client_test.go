@@ -0,0 +1,210 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantMessage *Message
+		wantErr     error
+		wantErrText string
+	}{
+		{
+			name:        "result",
+			status:      http.StatusOK,
+			body:        `{"ok":true,"result":{"message_id":7,"text":"hi"}}`,
+			wantMessage: &Message{MessageId: 7, Text: "hi"},
+		},
+		{
+			name:        "no result",
+			status:      http.StatusOK,
+			body:        `{"ok":true}`,
+			wantMessage: &Message{},
+		},
+		{
+			name:    "error",
+			status:  http.StatusBadRequest,
+			body:    `{"ok":false,"error_code":400,"description":"Bad Request: can't parse entities"}`,
+			wantErr: &APIError{ErrorCode: 400, Description: "Bad Request: can't parse entities"},
+		},
+		{
+			name:    "flood limit",
+			status:  http.StatusTooManyRequests,
+			body:    `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 3","parameters":{"retry_after":3}}`,
+			wantErr: &APIError{ErrorCode: 429, Description: "Too Many Requests: retry after 3", RetryAfter: 3},
+		},
+		{
+			name:    "migrated chat",
+			status:  http.StatusBadRequest,
+			body:    `{"ok":false,"error_code":400,"description":"Bad Request: group chat was upgraded to a supergroup chat","parameters":{"migrate_to_chat_id":-1001234}}`,
+			wantErr: &APIError{ErrorCode: 400, Description: "Bad Request: group chat was upgraded to a supergroup chat", MigrateToChatId: -1001234},
+		},
+		{
+			name:        "not json",
+			status:      http.StatusBadGateway,
+			body:        `<html>Bad Gateway</html>`,
+			wantErrText: "invalid response with code 502",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := &http.Response{
+				StatusCode: tt.status,
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+
+			var message Message
+			err := decodeResponse(response, &message)
+			switch {
+			case tt.wantErr != nil:
+				assert.Equal(t, tt.wantErr, err)
+			case tt.wantErrText != "":
+				assert.ErrorContains(t, err, tt.wantErrText)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantMessage, &message)
+			}
+		})
+	}
+}
+
+// scriptedServer answers the calls with the responses in order, repeating
+// the last one, and records the chat id of every request.
+type scriptedServer struct {
+	*httptest.Server
+
+	mutex     sync.Mutex
+	responses []string
+	chatIds   []string
+}
+
+func newScriptedServer(responses ...string) *scriptedServer {
+	s := &scriptedServer{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		var request struct {
+			ChatId string `json:"chat_id"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+
+		index := len(s.chatIds)
+		if index >= len(s.responses) {
+			index = len(s.responses) - 1
+		}
+		s.chatIds = append(s.chatIds, request.ChatId)
+		w.Write([]byte(s.responses[index]))
+	}))
+	return s
+}
+
+const (
+	sentResponse     = `{"ok":true,"result":{"message_id":1}}`
+	floodResponse    = `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`
+	migratedResponse = `{"ok":false,"error_code":400,"description":"Bad Request: group chat was upgraded to a supergroup chat","parameters":{"migrate_to_chat_id":-1001234}}`
+	blockedResponse  = `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`
+)
+
+func TestClientCall(t *testing.T) {
+	tests := []struct {
+		name        string
+		responses   []string
+		maxRetries  int
+		wantChatIds []string
+		wantErr     string
+	}{
+		{
+			name:        "sent",
+			responses:   []string{sentResponse},
+			maxRetries:  defaultMaxRetries,
+			wantChatIds: []string{"-42"},
+		},
+		{
+			name:        "flood limit retried",
+			responses:   []string{floodResponse, sentResponse},
+			maxRetries:  defaultMaxRetries,
+			wantChatIds: []string{"-42", "-42"},
+		},
+		{
+			name:        "migrated chat retried with the new id",
+			responses:   []string{migratedResponse, sentResponse},
+			maxRetries:  defaultMaxRetries,
+			wantChatIds: []string{"-42", "-1001234"},
+		},
+		{
+			name:        "other errors are not retried",
+			responses:   []string{blockedResponse, sentResponse},
+			maxRetries:  defaultMaxRetries,
+			wantChatIds: []string{"-42"},
+			wantErr:     "telegram error 403: Forbidden: bot was blocked by the user",
+		},
+		{
+			name:        "retries are limited",
+			responses:   []string{migratedResponse},
+			maxRetries:  2,
+			wantChatIds: []string{"-42", "-1001234", "-1001234"},
+			wantErr:     "telegram error 400: Bad Request: group chat was upgraded to a supergroup chat",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newScriptedServer(tt.responses...)
+			defer server.Close()
+
+			client := NewClient(server.URL)
+			client.MaxRetries = tt.maxRetries
+
+			message, err := client.SendMessage(context.Background(), &SendMessageRequest{ChatId: "-42", Text: "hello"})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, int64(1), message.MessageId)
+			}
+			assert.Equal(t, tt.wantChatIds, server.chatIds)
+		})
+	}
+}
+
+func TestClientCallWithoutChat(t *testing.T) {
+	server := newScriptedServer(migratedResponse, sentResponse)
+	defer server.Close()
+
+	_, err := NewClient(server.URL).GetWebhookInfo(context.Background())
+
+	var apiError *APIError
+	require.ErrorAs(t, err, &apiError)
+	assert.Equal(t, int64(-1001234), apiError.MigrateToChatId)
+	assert.Len(t, server.chatIds, 1)
+}
+
+func TestClientFloodLimitWaitStopsWithContext(t *testing.T) {
+	server := newScriptedServer(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 30","parameters":{"retry_after":30}}`)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := NewClient(server.URL).SendMessage(ctx, &SendMessageRequest{ChatId: "-42", Text: "hello"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
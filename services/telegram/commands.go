@@ -7,13 +7,67 @@ type Command string
 const (
 	CreateImageCommand Command = "/createimage"
 	EditCommand        Command = "/edit"
+	SpeakCommand       Command = "/speak"
+	EditImageCommand   Command = "/editimage"
+	VariationCommand   Command = "/variation"
+	GalleryCommand     Command = "/gallery"
+	VoiceModeCommand   Command = "/voicemode"
+	SettingsCommand    Command = "/settings"
+	DocsCommand        Command = "/docs"
+	ForgetCommand      Command = "/forget"
+	KnowledgeCommand   Command = "/kb"
+	SummarizeCommand   Command = "/summarize"
+	RemindCommand      Command = "/remind"
+	ScheduleCommand    Command = "/schedule"
+	SchedulesCommand   Command = "/schedules"
+	CancelCommand      Command = "/cancel"
+	ExportCommand      Command = "/export"
+	NewThreadCommand   Command = "/new"
+	HistoryCommand     Command = "/history"
+	SwitchCommand      Command = "/switch"
+	RenameCommand      Command = "/rename"
+	DeleteCommand      Command = "/delete"
+	ModerationCommand  Command = "/moderation"
+	SavePromptCommand  Command = "/save"
+	RunPromptCommand   Command = "/p"
+	PromptsCommand     Command = "/prompts"
 	None               Command = ""
 
 	MaxMessageLength = 12
 )
 
+// Commands lists the commands that can be enabled or disabled per group.
+var Commands = []Command{
+	CreateImageCommand,
+	EditCommand,
+	SpeakCommand,
+	EditImageCommand,
+	VariationCommand,
+	GalleryCommand,
+	VoiceModeCommand,
+	DocsCommand,
+	ForgetCommand,
+	KnowledgeCommand,
+	SummarizeCommand,
+	RemindCommand,
+	ScheduleCommand,
+	SchedulesCommand,
+	ExportCommand,
+	NewThreadCommand,
+	HistoryCommand,
+	SwitchCommand,
+	RenameCommand,
+	DeleteCommand,
+	SavePromptCommand,
+	RunPromptCommand,
+	PromptsCommand,
+}
+
 func GetCommand(text *string) Command {
-	initialText := (*text)[0:MaxMessageLength]
+	initialText := *text
+	if len(initialText) > MaxMessageLength {
+		initialText = initialText[0:MaxMessageLength]
+	}
 	command := strings.Split(initialText, " ")[0]
 
 	switch command {
@@ -21,6 +75,54 @@ func GetCommand(text *string) Command {
 		return CreateImageCommand
 	case string(EditCommand):
 		return EditCommand
+	case string(EditImageCommand):
+		return EditImageCommand
+	case string(VariationCommand):
+		return VariationCommand
+	case string(GalleryCommand):
+		return GalleryCommand
+	case string(SpeakCommand):
+		return SpeakCommand
+	case string(VoiceModeCommand):
+		return VoiceModeCommand
+	case string(SettingsCommand):
+		return SettingsCommand
+	case string(DocsCommand):
+		return DocsCommand
+	case string(ForgetCommand):
+		return ForgetCommand
+	case string(KnowledgeCommand):
+		return KnowledgeCommand
+	case string(SummarizeCommand):
+		return SummarizeCommand
+	case string(RemindCommand):
+		return RemindCommand
+	case string(ScheduleCommand):
+		return ScheduleCommand
+	case string(SchedulesCommand):
+		return SchedulesCommand
+	case string(CancelCommand):
+		return CancelCommand
+	case string(ExportCommand):
+		return ExportCommand
+	case string(NewThreadCommand):
+		return NewThreadCommand
+	case string(HistoryCommand):
+		return HistoryCommand
+	case string(SwitchCommand):
+		return SwitchCommand
+	case string(RenameCommand):
+		return RenameCommand
+	case string(DeleteCommand):
+		return DeleteCommand
+	case string(ModerationCommand):
+		return ModerationCommand
+	case string(SavePromptCommand):
+		return SavePromptCommand
+	case string(RunPromptCommand):
+		return RunPromptCommand
+	case string(PromptsCommand):
+		return PromptsCommand
 	}
 	return None
 }
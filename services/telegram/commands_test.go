@@ -0,0 +1,76 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCommand(t *testing.T) {
+	tests := []struct {
+		text string
+		want Command
+	}{
+		{"", None},
+		{"hello there", None},
+		{"/createimage a red fox in the snow", CreateImageCommand},
+		{"/edit fix the grammar: helo world", EditCommand},
+		{"/speak hello", SpeakCommand},
+		{"/speak", SpeakCommand},
+		{"/speaker hello", None},
+		{"/voicemode on", VoiceModeCommand},
+		{"/editimage", EditImageCommand},
+		{"/variation n=2", VariationCommand},
+		{"/gallery", GalleryCommand},
+		{"/settings", SettingsCommand},
+		{"/docs what is the total?", DocsCommand},
+		{"/forget", ForgetCommand},
+		{"/kb how do I deploy?", KnowledgeCommand},
+		{"/summarize https://example.com", SummarizeCommand},
+		{"/remind 45m call mom", RemindCommand},
+		{"/schedule @daily hello", ScheduleCommand},
+		{"/schedules cancel 1", SchedulesCommand},
+		{"/cancel", CancelCommand},
+		{"/export html", ExportCommand},
+		{"/new Trip", NewThreadCommand},
+		{"/history", HistoryCommand},
+		{"/switch 2", SwitchCommand},
+		{"/rename Trip", RenameCommand},
+		{"/delete 2", DeleteCommand},
+		{"/moderation", ModerationCommand},
+		{"/save review-sql Review: {{input}}", SavePromptCommand},
+		{"/p review-sql select 1", RunPromptCommand},
+		{"/prompts", PromptsCommand},
+		{"/unknown", None},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			text := tt.text
+			assert.Equal(t, tt.want, GetCommand(&text))
+		})
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		name            string
+		cmd             Command
+		text            string
+		wantText        string
+		wantInstruction string
+	}{
+		{"edit with instruction", EditCommand, "/edit fix the grammar: helo world", "helo world", "fix the grammar"},
+		{"edit without instruction", EditCommand, "/edit helo world", "helo world", ""},
+		{"speak", SpeakCommand, "/speak  hello world ", "hello world", ""},
+		{"empty", SpeakCommand, "/speak", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, instruction := ParseMessage(tt.cmd, &tt.text)
+			assert.Equal(t, tt.wantText, *text)
+			assert.Equal(t, tt.wantInstruction, *instruction)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package telegram
+
+import "unicode/utf16"
+
+// Urls returns the links of the message, both the ones written in the text
+// and the ones behind text links. Entity offsets count UTF-16 code units.
+func (m *Message) Urls() []string {
+	if m.Entities == nil {
+		return nil
+	}
+
+	text := utf16.Encode([]rune(m.Text))
+
+	var urls []string
+	for _, entity := range *m.Entities {
+		switch entity.Type {
+		case "url":
+			end := entity.OffSet + entity.Length
+			if entity.OffSet < 0 || end > len(text) {
+				continue
+			}
+			urls = append(urls, string(utf16.Decode(text[entity.OffSet:end])))
+		case "text_link":
+			if entity.Url != "" {
+				urls = append(urls, entity.Url)
+			}
+		}
+	}
+	return urls
+}
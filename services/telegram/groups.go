@@ -0,0 +1,90 @@
+package telegram
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+const (
+	GroupChat      = "group"
+	SupergroupChat = "supergroup"
+)
+
+func (c *Chat) IsGroup() bool {
+	return c != nil && (c.Type == GroupChat || c.Type == SupergroupChat)
+}
+
+// GetMe returns the bot user. The result is cached for the lifetime of the
+// service, as it never changes for a token.
+func (t *Telegram) GetMe(ctx context.Context) (*From, error) {
+	if t.me != nil {
+		return t.me, nil
+	}
+
+	me, err := t.Client.GetMe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.me = me
+	return me, nil
+}
+
+// IsChatAdmin reports whether the user is an administrator or the creator
+// of the chat.
+func (t *Telegram) IsChatAdmin(ctx context.Context, chatId string, userId int64) (bool, error) {
+	member, err := t.Client.GetChatMember(ctx, &GetChatMemberRequest{
+		ChatId: chatId,
+		UserId: userId,
+	})
+	if err != nil {
+		return false, err
+	}
+	return member.Status == "creator" || member.Status == "administrator", nil
+}
+
+// AddressedCommand strips the @username suffix from a command such as
+// /createimage@mybot. It returns false when the command is addressed to
+// another bot.
+func AddressedCommand(text string, username string) (string, bool) {
+	if !strings.HasPrefix(text, "/") {
+		return text, true
+	}
+
+	end := strings.IndexAny(text, " \n")
+	if end < 0 {
+		end = len(text)
+	}
+
+	at := strings.Index(text[:end], "@")
+	if at < 0 {
+		return text, true
+	}
+
+	if !strings.EqualFold(text[at+1:end], username) {
+		return text, false
+	}
+	return text[:at] + text[end:], true
+}
+
+// Mentions reports whether the text mentions @username.
+func Mentions(text string, username string) bool {
+	return username != "" && mentionPattern(username).MatchString(text)
+}
+
+// StripMention removes the @username mentions from the text.
+func StripMention(text string, username string) string {
+	if username == "" {
+		return text
+	}
+	return strings.TrimSpace(mentionPattern(username).ReplaceAllString(text, ""))
+}
+
+func mentionPattern(username string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)@` + regexp.QuoteMeta(username) + `\b`)
+}
+
+// IsReplyTo reports whether the message replies to a message sent by the user.
+func (m *Message) IsReplyTo(userId int64) bool {
+	return m.ReplyToMessage != nil && m.ReplyToMessage.From != nil && m.ReplyToMessage.From.ID == userId
+}
@@ -0,0 +1,30 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		username     string
+		wantMentions bool
+		wantStripped string
+	}{
+		{"mention", "@GptBot what time is it?", "GptBot", true, "what time is it?"},
+		{"case insensitive", "hey @gptbot", "GptBot", true, "hey"},
+		{"longer username", "@GptBotter hi", "GptBot", false, "@GptBotter hi"},
+		{"no mention", "hello", "GptBot", false, "hello"},
+		{"no username", "@GptBot hi", "", false, "@GptBot hi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantMentions, Mentions(tt.text, tt.username))
+			assert.Equal(t, tt.wantStripped, StripMention(tt.text, tt.username))
+		})
+	}
+}
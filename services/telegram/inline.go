@@ -0,0 +1,53 @@
+package telegram
+
+import (
+	"context"
+	"log/slog"
+)
+
+// InlineCacheTime is how long, in seconds, Telegram keeps the inline
+// results of a query on its side.
+const InlineCacheTime = 300
+
+// AnswerInlineQuery sends the results of an inline query. The results are
+// personal, Telegram only reuses them for the same user.
+func (t *Telegram) AnswerInlineQuery(ctx context.Context, queryId string, results []InlineQueryResultArticle) error {
+	err := t.Client.AnswerInlineQuery(ctx, &AnswerInlineQueryRequest{
+		InlineQueryId: queryId,
+		Results:       results,
+		CacheTime:     InlineCacheTime,
+		IsPersonal:    true,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error answering inline query", "error", err)
+	}
+	return err
+}
+
+// EditInlineMessage replaces the text of a message sent through inline mode.
+func (t *Telegram) EditInlineMessage(ctx context.Context, inlineMessageId string, message string) error {
+	_, err := t.Client.EditMessageText(ctx, &EditMessageTextRequest{
+		InlineMessageId: inlineMessageId,
+		Text:            message,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error editing inline message", "error", err)
+	}
+	return err
+}
+
+// EditInlinePhoto replaces a message sent through inline mode by the image.
+func (t *Telegram) EditInlinePhoto(ctx context.Context, inlineMessageId string, imgUrl string, caption string) error {
+	err := t.Client.EditMessageMedia(ctx, &EditMessageMediaRequest{
+		InlineMessageId: inlineMessageId,
+		Media: InputMediaPhoto{
+			Type:    "photo",
+			Media:   imgUrl,
+			Caption: truncateCaption(caption),
+		},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error editing inline photo", "error", err)
+	}
+	return err
+}
@@ -1,17 +1,13 @@
 package telegram
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
-	"mime/multipart"
-	"net/http"
-	"net/url"
-	"time"
+	"log/slog"
 
 	"github.com/marlosl/gpt-telegram-bot/clients/db"
-	"github.com/marlosl/gpt-telegram-bot/utils"
 	"github.com/marlosl/gpt-telegram-bot/utils/config"
 )
 
@@ -25,10 +21,17 @@ const (
 type Telegram struct {
 	Type       MessageType
 	serviceUrl string
+	Client     *Client
 	Cache      *db.CacheRepository
+	me         *From
 }
 
-const urlTelegram string = "https://api.telegram.org"
+const (
+	urlTelegram string = "https://api.telegram.org"
+
+	MaxMediaGroupSize = 10
+	MaxCaptionLength  = 1024
+)
 
 func NewTextService() *Telegram {
 	t := &Telegram{
@@ -49,151 +52,146 @@ func NewImageService() *Telegram {
 func (t *Telegram) Init() {
 	var err error
 	t.serviceUrl = t.GetTelegramUrl()
+	t.Client = NewClient(t.serviceUrl)
 	t.Cache, err = db.NewCacheRepository()
 	if err != nil {
-		log.Fatalf("Error creating cache repository: %v", err)
+		slog.Error("Error creating cache repository", "error", err)
 	}
 }
 
 func (t *Telegram) GetTelegramUrl() string {
+	token := t.getToken()
+	if token == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", urlTelegram, token)
+}
+
+func (t *Telegram) GetTelegramFileUrl(filePath string) string {
+	return fmt.Sprintf("%s/file/%s/%s", urlTelegram, t.getToken(), filePath)
+}
+
+func (t *Telegram) getToken() string {
 	switch t.Type {
 	case Text:
-		return fmt.Sprintf("%s/%s", urlTelegram, config.Store.TelegramBotTextToken)
+		return config.Store.TelegramBotTextToken
 	case Image:
-		return fmt.Sprintf("%s/%s", urlTelegram, config.Store.TelegramBotImageToken)
+		return config.Store.TelegramBotImageToken
 	}
 	return ""
 }
 
-func (t *Telegram) SendMessage(message string, chatId string, isHtml bool) {
-	params := url.Values{}
-	params.Add("chat_id", chatId)
+func (t *Telegram) SendMessage(ctx context.Context, message string, chatId string, isHtml bool) error {
+	_, err := t.SendTextMessage(ctx, message, chatId, isHtml)
+	return err
+}
+
+// SendTextMessage sends the message and returns it as delivered, so its
+// message id can be referenced later.
+func (t *Telegram) SendTextMessage(ctx context.Context, message string, chatId string, isHtml bool) (*Message, error) {
+	request := &SendMessageRequest{
+		ChatId: chatId,
+		Text:   message,
+	}
 	if isHtml {
-		params.Add("parse_mode", "html")
+		request.ParseMode = "html"
 	}
-	t.SendTelegramMessage(message, params)
-}
 
-func (t *Telegram) SendRepliedMessage(message string, reply string) {
-	params := url.Values{}
-	params.Add("reply_markup", reply)
-	t.SendTelegramMessage(message, params)
+	sent, err := t.Client.SendMessage(ctx, request)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending message", "error", err)
+	}
+	return sent, err
 }
 
-func (t *Telegram) SendTelegramMessage(message string, paramValues url.Values) {
-	params := url.Values{}
-	if (len(paramValues)) > 0 {
-		params = paramValues
+func (t *Telegram) SendRepliedMessage(ctx context.Context, message string, chatId string, reply *InlineKeyboard) error {
+	_, err := t.Client.SendMessage(ctx, &SendMessageRequest{
+		ChatId:      chatId,
+		Text:        message,
+		ReplyMarkup: reply,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending message", "error", err)
 	}
-
-	params.Add("text", message)
-
-	urlMsg := t.serviceUrl + "/sendMessage?" + params.Encode()
-
-	fmt.Println("urlMsg", urlMsg)
-
-	response, err := http.Get(urlMsg)
-
-	fmt.Println("err", err)
-	fmt.Println("response", response)
+	return err
 }
 
-func (t *Telegram) SendTelegramCallbackQueryResponse(callbackQueryId string) {
-	params := url.Values{}
-
-	params.Add("callback_query_id", callbackQueryId)
-
-	urlMsg := t.serviceUrl + "/answerCallbackQuery?" + params.Encode()
-
-	fmt.Println("urlMsg", urlMsg)
-
-	response, err := http.Get(urlMsg)
-
-	fmt.Println("err", err)
-	fmt.Println("response", response)
+func (t *Telegram) SendTelegramCallbackQueryResponse(ctx context.Context, callbackQueryId string) error {
+	err := t.Client.AnswerCallbackQuery(ctx, &AnswerCallbackQueryRequest{
+		CallbackQueryId: callbackQueryId,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error answering callback query", "error", err)
+	}
+	return err
 }
 
-func (t *Telegram) SetWebhook(webhookUrl string, token string) {
-	params := url.Values{}
-
-	params.Add("url", webhookUrl)
-	params.Add("secret_token", token)
-
-	urlMsg := t.serviceUrl + "/setWebhook?" + params.Encode()
-
-	fmt.Println("urlMsg", urlMsg)
-
-	response, err := http.Get(urlMsg)
-
-	fmt.Println("err", err)
-	fmt.Println("response", response)
+func (t *Telegram) SetWebhook(ctx context.Context, webhookUrl string, token string) error {
+	return t.Client.SetWebhook(ctx, &SetWebhookRequest{
+		Url:         webhookUrl,
+		SecretToken: token,
+	})
 }
 
-func (t *Telegram) SendPhotoGet(imgUrl string, chatId string) error {
-	params := url.Values{}
-	params.Add("chat_id", chatId)
-	params.Add("photo", imgUrl)
-
-	urlMsg := t.serviceUrl + "/sendPhoto?" + params.Encode()
-
-	fmt.Println("urlMsg", urlMsg)
-
-	response, err := http.Get(urlMsg)
+func (t *Telegram) GetWebhookInfo(ctx context.Context) (*WebhookInfo, error) {
+	return t.Client.GetWebhookInfo(ctx)
+}
 
-	fmt.Println("err", err)
-	fmt.Println("response", response)
+func (t *Telegram) SendPhotoGet(ctx context.Context, imgUrl string, chatId string, caption string) error {
+	_, err := t.Client.SendPhoto(ctx, &SendPhotoRequest{
+		ChatId:  chatId,
+		Photo:   imgUrl,
+		Caption: truncateCaption(caption),
+	})
 	return err
 }
 
-func (t *Telegram) SendPhoto(imgUrl string, chatId string) error {
-	params := url.Values{}
-	params.Add("chat_id", chatId)
-	urlMsg := t.serviceUrl + "/sendPhoto?" + params.Encode()
-
-	fmt.Println("sendPhotoUrl", urlMsg)
+// SendMediaGroup sends the images as a single album, using the caption on
+// the first item. Telegram requires between 2 and 10 items per album.
+func (t *Telegram) SendMediaGroup(ctx context.Context, imgUrls []string, chatId string, caption string) error {
+	if len(imgUrls) == 1 {
+		return t.SendPhotoGet(ctx, imgUrls[0], chatId, caption)
+	}
 
-	imgFile, err := http.Get(imgUrl)
-	if err != nil {
-		fmt.Printf("Error getting image: %v\n", err)
-		return err
+	if len(imgUrls) < 2 || len(imgUrls) > MaxMediaGroupSize {
+		return fmt.Errorf("an album must have between 2 and %d images", MaxMediaGroupSize)
 	}
-	defer imgFile.Body.Close()
-	client := &http.Client{
-		Timeout: time.Second * 10,
+
+	request := &SendMediaGroupRequest{
+		ChatId: chatId,
 	}
-	// New multipart writer.
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	fw, err := writer.CreateFormFile("photo", imgUrl)
-	if err != nil {
-		fmt.Printf("Error creating form field: %v\n", err)
-		return err
+	for i, imgUrl := range imgUrls {
+		media := InputMediaPhoto{
+			Type:  "photo",
+			Media: imgUrl,
+		}
+		if i == 0 {
+			media.Caption = truncateCaption(caption)
+		}
+		request.Media = append(request.Media, media)
 	}
 
-	_, err = io.Copy(fw, imgFile.Body)
-	if err != nil {
-		fmt.Printf("Error copying file: %v\n", err)
-		return err
-	}
+	_, err := t.Client.SendMediaGroup(ctx, request)
+	return err
+}
 
-	// Close multipart writer.
-	writer.Close()
-	req, err := http.NewRequest("POST", urlMsg, bytes.NewReader(body.Bytes()))
-	if err != nil {
-		fmt.Printf("Error creating request: %v\n", err)
-		return err
+func truncateCaption(caption string) string {
+	runes := []rune(caption)
+	if len(runes) <= MaxCaptionLength {
+		return caption
 	}
+	return string(runes[:MaxCaptionLength])
+}
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	rsp, err := client.Do(req)
-	if err != nil {
-		fmt.Printf("Error sending image: %v\n", err)
-	}
+func (t *Telegram) GetFile(ctx context.Context, fileId string) (*File, error) {
+	return t.Client.GetFile(ctx, fileId)
+}
 
-	fmt.Printf("SendPhoto Response: %s\n", utils.SPrintJson(rsp))
-	if rsp.StatusCode != http.StatusOK {
-		fmt.Printf("Request failed with response code: %d\n", rsp.StatusCode)
+// DownloadFile returns the content of a file previously resolved by GetFile.
+// The caller is responsible for closing the returned reader.
+func (t *Telegram) DownloadFile(ctx context.Context, file *File) (io.ReadCloser, error) {
+	if file == nil || file.FilePath == "" {
+		return nil, errors.New("file path is empty")
 	}
-	fmt.Println("SendPhoto - 9")
-	return nil
+	return t.Client.Download(ctx, t.GetTelegramFileUrl(file.FilePath))
 }
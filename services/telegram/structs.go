@@ -2,6 +2,8 @@ package telegram
 
 import (
 	"context"
+	"encoding/json"
+	"strings"
 	"time"
 )
 
@@ -26,6 +28,7 @@ type Event struct {
 type ChatStatus struct {
 	ID            string    `json:"id,omitempty" bson:"_id,omitempty"`
 	ChatId        int64     `json:"chatId" bson:"chatId"`
+	UserId        int64     `json:"userId,omitempty" bson:"userId,omitempty"`
 	Status        string    `json:"status"`
 	LastParameter string    `json:"lastParameter" bson:"lastParameter"`
 	LastUpdate    time.Time `json:"lastUpdate" bson:"lastUpdate"`
@@ -49,25 +52,99 @@ type Entity struct {
 	OffSet int    `json:"offset"`
 	Length int    `json:"length"`
 	Type   string `json:"type"`
+	Url    string `json:"url,omitempty"`
 }
 
 type InlineKeyboard struct {
-	Buttons [1][]InlineKeyboardButton `json:"inline_keyboard"`
+	Buttons [][]InlineKeyboardButton `json:"inline_keyboard"`
 }
 
 type InlineKeyboardButton struct {
-	Text         string `json:"text"`
-	CallbackData string `json:"callback_data"`
+	Text                         string  `json:"text"`
+	CallbackData                 string  `json:"callback_data,omitempty"`
+	SwitchInlineQueryCurrentChat *string `json:"switch_inline_query_current_chat,omitempty"`
+}
+
+type Voice struct {
+	FileId       string `json:"file_id"`
+	FileUniqueId string `json:"file_unique_id"`
+	Duration     int    `json:"duration"`
+	MimeType     string `json:"mime_type,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
+}
+
+type Audio struct {
+	FileId       string `json:"file_id"`
+	FileUniqueId string `json:"file_unique_id"`
+	Duration     int    `json:"duration"`
+	Performer    string `json:"performer,omitempty"`
+	Title        string `json:"title,omitempty"`
+	FileName     string `json:"file_name,omitempty"`
+	MimeType     string `json:"mime_type,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
+}
+
+type PhotoSize struct {
+	FileId       string `json:"file_id"`
+	FileUniqueId string `json:"file_unique_id"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	FileSize     int64  `json:"file_size,omitempty"`
+}
+
+type Document struct {
+	FileId       string `json:"file_id"`
+	FileUniqueId string `json:"file_unique_id"`
+	FileName     string `json:"file_name,omitempty"`
+	MimeType     string `json:"mime_type,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
+}
+
+type File struct {
+	FileId       string `json:"file_id"`
+	FileUniqueId string `json:"file_unique_id"`
+	FileSize     int64  `json:"file_size,omitempty"`
+	FilePath     string `json:"file_path,omitempty"`
 }
 
 type Message struct {
-	MessageId    int64           `json:"message_id,omitempty"`
-	From         *From           `json:"from,omitempty"`
-	Chat         *Chat           `json:"chat,omitempty"`
-	Date         int64           `json:"date,omitempty"`
-	Text         string          `json:"text"`
-	Entities     *[]Entity       `json:"entities,omitempty"`
-	ReplayMarkup *InlineKeyboard `json:"reply_markup,omitempty"`
+	MessageId      int64           `json:"message_id,omitempty"`
+	From           *From           `json:"from,omitempty"`
+	Chat           *Chat           `json:"chat,omitempty"`
+	Date           int64           `json:"date,omitempty"`
+	Text           string          `json:"text"`
+	Entities       *[]Entity       `json:"entities,omitempty"`
+	ReplayMarkup   *InlineKeyboard `json:"reply_markup,omitempty"`
+	Voice          *Voice          `json:"voice,omitempty"`
+	Audio          *Audio          `json:"audio,omitempty"`
+	Photo          []PhotoSize     `json:"photo,omitempty"`
+	Caption        string          `json:"caption,omitempty"`
+	Document       *Document       `json:"document,omitempty"`
+	ReplyToMessage *Message        `json:"reply_to_message,omitempty"`
+}
+
+// ImageFileId returns the file id of the image attached to the message,
+// either as a photo or as an image document.
+func (m *Message) ImageFileId() string {
+	if photo := m.LargestPhoto(); photo != nil {
+		return photo.FileId
+	}
+	if m.Document != nil && strings.HasPrefix(m.Document.MimeType, "image/") {
+		return m.Document.FileId
+	}
+	return ""
+}
+
+// LargestPhoto returns the biggest available size of the attached photo.
+func (m *Message) LargestPhoto() *PhotoSize {
+	var largest *PhotoSize
+	for i := range m.Photo {
+		photo := &m.Photo[i]
+		if largest == nil || photo.Width*photo.Height > largest.Width*largest.Height {
+			largest = photo
+		}
+	}
+	return largest
 }
 
 type CallbackQuery struct {
@@ -78,10 +155,26 @@ type CallbackQuery struct {
 	Data         string   `json:"data"`
 }
 
+type InlineQuery struct {
+	ID     string `json:"id"`
+	From   *From  `json:"from,omitempty"`
+	Query  string `json:"query"`
+	Offset string `json:"offset"`
+}
+
+type ChosenInlineResult struct {
+	ResultId        string `json:"result_id"`
+	From            *From  `json:"from,omitempty"`
+	Query           string `json:"query"`
+	InlineMessageId string `json:"inline_message_id,omitempty"`
+}
+
 type WebhookMessage struct {
-	UpdateId      int64          `json:"update_id,omitempty"`
-	Message       *Message       `json:"message,omitempty"`
-	CallbackQuery *CallbackQuery `json:"callback_query,omitempty"`
+	UpdateId           int64               `json:"update_id,omitempty"`
+	Message            *Message            `json:"message,omitempty"`
+	CallbackQuery      *CallbackQuery      `json:"callback_query,omitempty"`
+	InlineQuery        *InlineQuery        `json:"inline_query,omitempty"`
+	ChosenInlineResult *ChosenInlineResult `json:"chosen_inline_result,omitempty"`
 }
 
 type MsgParams struct {
@@ -93,12 +186,157 @@ type MsgParams struct {
 	PkgDescription  string
 	ChatId          int64
 	ChatMsg         string
+	From            *From
 	Ctx             context.Context
 	Status          *ChatStatus
 	CallbackQueryId string
 }
 
 type ImageMessage struct {
-	ChatId   string `json:"chatId"`
-	ImageUrl string `json:"imageUrl"`
+	ChatId    string   `json:"chatId"`
+	ImageUrl  string   `json:"imageUrl,omitempty"`
+	ImageUrls []string `json:"imageUrls,omitempty"`
+	Caption   string   `json:"caption,omitempty"`
+}
+
+// Urls returns the images of the message, including the single ImageUrl
+// sent by older versions of the handler.
+func (m *ImageMessage) Urls() []string {
+	if len(m.ImageUrls) > 0 {
+		return m.ImageUrls
+	}
+	if m.ImageUrl != "" {
+		return []string{m.ImageUrl}
+	}
+	return nil
+}
+
+type ResponseParameters struct {
+	MigrateToChatId int64 `json:"migrate_to_chat_id,omitempty"`
+	RetryAfter      int   `json:"retry_after,omitempty"`
+}
+
+type APIResponse struct {
+	Ok          bool                `json:"ok"`
+	Result      json.RawMessage     `json:"result,omitempty"`
+	Description string              `json:"description,omitempty"`
+	ErrorCode   int                 `json:"error_code,omitempty"`
+	Parameters  *ResponseParameters `json:"parameters,omitempty"`
+}
+
+type SendMessageRequest struct {
+	ChatId                string          `json:"chat_id"`
+	Text                  string          `json:"text"`
+	ParseMode             string          `json:"parse_mode,omitempty"`
+	ReplyToMessageId      int64           `json:"reply_to_message_id,omitempty"`
+	ReplyMarkup           *InlineKeyboard `json:"reply_markup,omitempty"`
+	DisableWebPagePreview bool            `json:"disable_web_page_preview,omitempty"`
+}
+
+type EditMessageTextRequest struct {
+	ChatId          string          `json:"chat_id,omitempty"`
+	MessageId       int64           `json:"message_id,omitempty"`
+	InlineMessageId string          `json:"inline_message_id,omitempty"`
+	Text            string          `json:"text"`
+	ParseMode       string          `json:"parse_mode,omitempty"`
+	ReplyMarkup     *InlineKeyboard `json:"reply_markup,omitempty"`
+}
+
+type DeleteMessageRequest struct {
+	ChatId    string `json:"chat_id"`
+	MessageId int64  `json:"message_id"`
+}
+
+type SendChatActionRequest struct {
+	ChatId string `json:"chat_id"`
+	Action string `json:"action"`
+}
+
+type SendPhotoRequest struct {
+	ChatId  string `json:"chat_id"`
+	Photo   string `json:"photo"`
+	Caption string `json:"caption,omitempty"`
+}
+
+type GetFileRequest struct {
+	FileId string `json:"file_id"`
+}
+
+type SetWebhookRequest struct {
+	Url            string   `json:"url"`
+	SecretToken    string   `json:"secret_token,omitempty"`
+	AllowedUpdates []string `json:"allowed_updates,omitempty"`
+}
+
+type WebhookInfo struct {
+	Url                  string   `json:"url"`
+	HasCustomCertificate bool     `json:"has_custom_certificate"`
+	PendingUpdateCount   int      `json:"pending_update_count"`
+	LastErrorDate        int64    `json:"last_error_date,omitempty"`
+	LastErrorMessage     string   `json:"last_error_message,omitempty"`
+	MaxConnections       int      `json:"max_connections,omitempty"`
+	AllowedUpdates       []string `json:"allowed_updates,omitempty"`
+}
+
+type GetChatMemberRequest struct {
+	ChatId string `json:"chat_id"`
+	UserId int64  `json:"user_id"`
+}
+
+type ChatMember struct {
+	Status string `json:"status"`
+	User   *From  `json:"user,omitempty"`
+}
+
+type AnswerCallbackQueryRequest struct {
+	CallbackQueryId string `json:"callback_query_id"`
+	Text            string `json:"text,omitempty"`
+	ShowAlert       bool   `json:"show_alert,omitempty"`
+}
+
+func (r *SendMessageRequest) setChatId(chatId string)     { r.ChatId = chatId }
+func (r *EditMessageTextRequest) setChatId(chatId string) { r.ChatId = chatId }
+func (r *DeleteMessageRequest) setChatId(chatId string)   { r.ChatId = chatId }
+func (r *SendChatActionRequest) setChatId(chatId string)  { r.ChatId = chatId }
+func (r *SendPhotoRequest) setChatId(chatId string)       { r.ChatId = chatId }
+func (r *SendMediaGroupRequest) setChatId(chatId string)  { r.ChatId = chatId }
+
+type InputMediaPhoto struct {
+	Type    string `json:"type"`
+	Media   string `json:"media"`
+	Caption string `json:"caption,omitempty"`
+}
+
+type SendMediaGroupRequest struct {
+	ChatId string            `json:"chat_id"`
+	Media  []InputMediaPhoto `json:"media"`
+}
+
+type EditMessageMediaRequest struct {
+	ChatId          string          `json:"chat_id,omitempty"`
+	MessageId       int64           `json:"message_id,omitempty"`
+	InlineMessageId string          `json:"inline_message_id,omitempty"`
+	Media           InputMediaPhoto `json:"media"`
+	ReplyMarkup     *InlineKeyboard `json:"reply_markup,omitempty"`
+}
+
+type InputTextMessageContent struct {
+	MessageText string `json:"message_text"`
+	ParseMode   string `json:"parse_mode,omitempty"`
+}
+
+type InlineQueryResultArticle struct {
+	Type                string                  `json:"type"`
+	ID                  string                  `json:"id"`
+	Title               string                  `json:"title"`
+	Description         string                  `json:"description,omitempty"`
+	InputMessageContent InputTextMessageContent `json:"input_message_content"`
+	ReplyMarkup         *InlineKeyboard         `json:"reply_markup,omitempty"`
+}
+
+type AnswerInlineQueryRequest struct {
+	InlineQueryId string                     `json:"inline_query_id"`
+	Results       []InlineQueryResultArticle `json:"results"`
+	CacheTime     int                        `json:"cache_time,omitempty"`
+	IsPersonal    bool                       `json:"is_personal,omitempty"`
 }
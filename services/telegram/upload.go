@@ -0,0 +1,183 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/utils/tracing"
+)
+
+type MediaType string
+
+const (
+	PhotoMedia    MediaType = "photo"
+	DocumentMedia MediaType = "document"
+	AudioMedia    MediaType = "audio"
+	VoiceMedia    MediaType = "voice"
+
+	uploadTimeout = 2 * time.Minute
+)
+
+var uploadMethods = map[MediaType]string{
+	PhotoMedia:    "sendPhoto",
+	DocumentMedia: "sendDocument",
+	AudioMedia:    "sendAudio",
+	VoiceMedia:    "sendVoice",
+}
+
+// InputFile is a file uploaded with multipart/form-data. The reader is
+// streamed to Telegram and is not buffered in memory.
+type InputFile struct {
+	Name   string
+	Reader io.Reader
+}
+
+// Upload sends the file with the method matching the media type. Extra
+// params (caption, parse_mode, ...) are sent as form fields.
+func (t *Telegram) Upload(ctx context.Context, mediaType MediaType, chatId string, file InputFile, params map[string]string) (err error) {
+	method, ok := uploadMethods[mediaType]
+	if !ok {
+		return fmt.Errorf("unsupported media type %s", mediaType)
+	}
+
+	if file.Reader == nil {
+		return errors.New("file reader is empty")
+	}
+
+	bodyReader, bodyWriter := io.Pipe()
+	writer := multipart.NewWriter(bodyWriter)
+
+	go func() {
+		err := writeMultipart(writer, string(mediaType), chatId, file, params)
+		bodyWriter.CloseWithError(err)
+	}()
+
+	ctx, span := tracing.Start(ctx, "telegram."+method)
+	defer func() { tracing.End(span, err) }()
+	defer func() { recordError(method, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.serviceUrl+"/"+method, bodyReader)
+	if err != nil {
+		bodyReader.Close()
+		slog.ErrorContext(ctx, "Error creating request", "error", err)
+		return err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	client := &http.Client{
+		Timeout: uploadTimeout,
+	}
+	rsp, err := client.Do(req)
+	if err != nil {
+		bodyReader.Close()
+		slog.ErrorContext(ctx, "Error uploading file", "media_type", mediaType, "error", err)
+		return err
+	}
+	return decodeResponse(rsp, nil)
+}
+
+func writeMultipart(writer *multipart.Writer, field string, chatId string, file InputFile, params map[string]string) error {
+	if err := writer.WriteField("chat_id", chatId); err != nil {
+		return err
+	}
+
+	for key, value := range params {
+		if value == "" {
+			continue
+		}
+		if err := writer.WriteField(key, value); err != nil {
+			return err
+		}
+	}
+
+	fw, err := writer.CreateFormFile(field, file.Name)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(fw, file.Reader); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+func (t *Telegram) SendVoice(ctx context.Context, voice io.Reader, chatId string, caption string) error {
+	return t.Upload(ctx, VoiceMedia, chatId, InputFile{Name: "voice.ogg", Reader: voice}, map[string]string{
+		"caption": truncateCaption(caption),
+	})
+}
+
+func (t *Telegram) SendDocument(ctx context.Context, document io.Reader, filename string, chatId string, caption string) error {
+	return t.Upload(ctx, DocumentMedia, chatId, InputFile{Name: filename, Reader: document}, map[string]string{
+		"caption": truncateCaption(caption),
+	})
+}
+
+func (t *Telegram) SendAudio(ctx context.Context, audio io.Reader, filename string, chatId string, caption string) error {
+	return t.Upload(ctx, AudioMedia, chatId, InputFile{Name: filename, Reader: audio}, map[string]string{
+		"caption": truncateCaption(caption),
+	})
+}
+
+// SendPhoto downloads the image and uploads it to Telegram, for URLs that
+// Telegram cannot fetch by itself.
+func (t *Telegram) SendPhoto(ctx context.Context, imgUrl string, chatId string, caption string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imgUrl, nil)
+	if err != nil {
+		return err
+	}
+
+	imgFile, err := http.DefaultClient.Do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting image", "error", err)
+		return err
+	}
+	defer imgFile.Body.Close()
+
+	if imgFile.StatusCode != http.StatusOK {
+		return fmt.Errorf("image download failed with response code: %d", imgFile.StatusCode)
+	}
+
+	filename := path.Base(imgFile.Request.URL.Path)
+	if filename == "" || filename == "/" || filename == "." {
+		filename = "image.png"
+	}
+
+	return t.Upload(ctx, PhotoMedia, chatId, InputFile{Name: filename, Reader: imgFile.Body}, map[string]string{
+		"caption": truncateCaption(caption),
+	})
+}
+
+// SendPhotoByUrl asks Telegram to fetch the image and falls back to
+// uploading it when Telegram cannot download the URL.
+func (t *Telegram) SendPhotoByUrl(ctx context.Context, imgUrl string, chatId string, caption string) error {
+	err := t.SendPhotoGet(ctx, imgUrl, chatId, caption)
+	if err == nil || !IsUrlFetchError(err) {
+		return err
+	}
+
+	slog.ErrorContext(ctx, "Telegram could not fetch the image, uploading it", "error", err)
+	return t.SendPhoto(ctx, imgUrl, chatId, caption)
+}
+
+// IsUrlFetchError reports whether the error means Telegram could not
+// download a file sent by URL.
+func IsUrlFetchError(err error) bool {
+	var apiError *APIError
+	if !errors.As(err, &apiError) || apiError.ErrorCode != http.StatusBadRequest {
+		return false
+	}
+
+	description := strings.ToLower(apiError.Description)
+	return strings.Contains(description, "failed to get http url content") ||
+		strings.Contains(description, "wrong file identifier/http url specified") ||
+		strings.Contains(description, "wrong type of the web page content")
+}
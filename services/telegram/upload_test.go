@@ -0,0 +1,165 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// photoServer serves an image and answers sendPhoto, failing the photos
+// sent by URL with the configured response.
+type photoServer struct {
+	*httptest.Server
+
+	mutex       sync.Mutex
+	urlResponse string
+	calls       []string
+	uploaded    string
+	filename    string
+	caption     string
+}
+
+func newPhotoServer(urlResponse string) *photoServer {
+	s := &photoServer{urlResponse: urlResponse}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *photoServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch {
+	case r.URL.Path == "/images/fox.png":
+		w.Write([]byte("png data"))
+	case r.URL.Path == "/bot/sendPhoto" && strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data"):
+		s.calls = append(s.calls, "upload")
+		file, header, err := r.FormFile("photo")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: there is no photo in the request"}`))
+			return
+		}
+		content, _ := io.ReadAll(file)
+		s.uploaded = string(content)
+		s.filename = header.Filename
+		s.caption = r.FormValue("caption")
+		w.Write([]byte(`{"ok":true,"result":{"message_id":2}}`))
+	case r.URL.Path == "/bot/sendPhoto":
+		s.calls = append(s.calls, "url")
+		photo := r.URL.Query().Get("photo")
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			var request struct {
+				Photo string `json:"photo"`
+			}
+			json.NewDecoder(r.Body).Decode(&request)
+			photo = request.Photo
+		}
+		if photo == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: there is no photo in the request"}`))
+			return
+		}
+		w.Write([]byte(s.urlResponse))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"ok":false,"error_code":404,"description":"Not Found"}`))
+	}
+}
+
+func newTestTelegram(serverUrl string) *Telegram {
+	return &Telegram{
+		serviceUrl: serverUrl + "/bot",
+		Client:     NewClient(serverUrl + "/bot"),
+	}
+}
+
+func TestSendPhotoByUrl(t *testing.T) {
+	tests := []struct {
+		name         string
+		urlResponse  string
+		image        string
+		wantCalls    []string
+		wantUploaded string
+		wantErr      string
+	}{
+		{
+			name:        "sent by url",
+			urlResponse: `{"ok":true,"result":{"message_id":1}}`,
+			image:       "/images/fox.png",
+			wantCalls:   []string{"url"},
+		},
+		{
+			name:         "uploaded when telegram cannot fetch the url",
+			urlResponse:  `{"ok":false,"error_code":400,"description":"Bad Request: failed to get HTTP URL content"}`,
+			image:        "/images/fox.png",
+			wantCalls:    []string{"url", "upload"},
+			wantUploaded: "png data",
+		},
+		{
+			name:        "other errors are returned",
+			urlResponse: `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`,
+			image:       "/images/fox.png",
+			wantCalls:   []string{"url"},
+			wantErr:     "telegram error 403: Forbidden: bot was blocked by the user",
+		},
+		{
+			name:        "the image cannot be downloaded either",
+			urlResponse: `{"ok":false,"error_code":400,"description":"Bad Request: wrong file identifier/HTTP URL specified"}`,
+			image:       "/images/missing.png",
+			wantCalls:   []string{"url"},
+			wantErr:     "image download failed with response code: 404",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newPhotoServer(tt.urlResponse)
+			defer server.Close()
+
+			err := newTestTelegram(server.URL).SendPhotoByUrl(context.Background(), server.URL+tt.image, "42", "a fox")
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantCalls, server.calls)
+			if tt.wantUploaded != "" {
+				assert.Equal(t, tt.wantUploaded, server.uploaded)
+				assert.Equal(t, "fox.png", server.filename)
+				assert.Equal(t, "a fox", server.caption)
+			}
+		})
+	}
+}
+
+func TestIsUrlFetchError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"failed to get content", &APIError{ErrorCode: 400, Description: "Bad Request: failed to get HTTP URL content"}, true},
+		{"wrong url", &APIError{ErrorCode: 400, Description: "Bad Request: wrong file identifier/HTTP URL specified"}, true},
+		{"wrong content type", &APIError{ErrorCode: 400, Description: "Bad Request: wrong type of the web page content"}, true},
+		{"other bad request", &APIError{ErrorCode: 400, Description: "Bad Request: chat not found"}, false},
+		{"other code", &APIError{ErrorCode: 500, Description: "failed to get HTTP URL content"}, false},
+		{"network error", errors.New("connection refused"), false},
+		{"no error", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsUrlFetchError(tt.err))
+		})
+	}
+}
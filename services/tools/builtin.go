@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/services/chatgpt"
+	"github.com/marlosl/gpt-telegram-bot/services/documents"
+)
+
+const (
+	conversationSearchLimit   = 200
+	conversationSearchResults = 5
+)
+
+func currentTimeTool() chatgpt.ToolDefinition {
+	return chatgpt.ToolDefinition{
+		Name:        "current_time",
+		Description: "Returns the current date and time, optionally in an IANA time zone such as Europe/Berlin.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"timezone": {"type": "string", "description": "IANA time zone, UTC when omitted"}
+			}
+		}`),
+		Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			var args struct {
+				Timezone string `json:"timezone"`
+			}
+			if err := decodeArguments(arguments, &args); err != nil {
+				return "", err
+			}
+
+			location, err := time.LoadLocation(args.Timezone)
+			if err != nil {
+				return "", fmt.Errorf("unknown time zone %s", args.Timezone)
+			}
+			return time.Now().In(location).Format("Monday, 2006-01-02 15:04:05 MST"), nil
+		},
+	}
+}
+
+func calculatorTool() chatgpt.ToolDefinition {
+	return chatgpt.ToolDefinition{
+		Name: "calculator",
+		Description: "Evaluates an arithmetic expression with + - * / % ^, parentheses, " +
+			"the constants pi and e and the functions sqrt, abs, ln, log, sin, cos, tan, round, floor and ceil.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"expression": {"type": "string", "description": "the expression, e.g. (2 + 3) * sqrt(16)"}
+			},
+			"required": ["expression"]
+		}`),
+		Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			var args struct {
+				Expression string `json:"expression"`
+			}
+			if err := decodeArguments(arguments, &args); err != nil {
+				return "", err
+			}
+
+			result, err := Evaluate(args.Expression)
+			if err != nil {
+				return "", err
+			}
+			return strconv.FormatFloat(result, 'g', 12, 64), nil
+		},
+	}
+}
+
+func convertUnitsTool() chatgpt.ToolDefinition {
+	return chatgpt.ToolDefinition{
+		Name: "convert_units",
+		Description: "Converts a value between units of length, mass, volume, speed, time or temperature, " +
+			"e.g. km to mi, lb to kg, F to C.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"value": {"type": "number"},
+				"from": {"type": "string", "description": "unit of the value"},
+				"to": {"type": "string", "description": "unit to convert to"}
+			},
+			"required": ["value", "from", "to"]
+		}`),
+		Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			var args struct {
+				Value float64 `json:"value"`
+				From  string  `json:"from"`
+				To    string  `json:"to"`
+			}
+			if err := decodeArguments(arguments, &args); err != nil {
+				return "", err
+			}
+
+			result, err := ConvertUnits(args.Value, args.From, args.To)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%s %s", strconv.FormatFloat(result, 'g', 10, 64), args.To), nil
+		},
+	}
+}
+
+func searchConversationTool(history *db.HistoryRepository) chatgpt.ToolDefinition {
+	return chatgpt.ToolDefinition{
+		Name:        "search_conversation",
+		Description: "Searches the earlier messages of this conversation, for things said before the recent context.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"query": {"type": "string", "description": "words to look for"}
+			},
+			"required": ["query"]
+		}`),
+		Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			var args struct {
+				Query string `json:"query"`
+			}
+			if err := decodeArguments(arguments, &args); err != nil {
+				return "", err
+			}
+
+			id, thread := chatId(ctx), threadId(ctx)
+			if id == "" || thread == "" {
+				return "", errors.New("no conversation is available")
+			}
+
+			messages, err := history.ListMessages(id, thread, conversationSearchLimit)
+			if err != nil {
+				return "", err
+			}
+
+			texts := make([]string, len(messages))
+			for i, message := range messages {
+				name := message.Name
+				if message.Role == "assistant" {
+					name = "assistant"
+				}
+				date := time.Unix(0, message.CreatedAt).UTC().Format("2006-01-02 15:04")
+				texts[i] = fmt.Sprintf("[%s] %s: %s", date, name, message.Content)
+			}
+
+			var found []string
+			for _, i := range documents.Select(args.Query, texts, conversationSearchResults) {
+				found = append(found, texts[i])
+			}
+			if len(found) == 0 {
+				return "No earlier messages found.", nil
+			}
+			return strings.Join(found, "\n"), nil
+		},
+	}
+}
@@ -0,0 +1,222 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var calculatorFunctions = map[string]func(float64) float64{
+	"sqrt":  math.Sqrt,
+	"abs":   math.Abs,
+	"ln":    math.Log,
+	"log":   math.Log10,
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+	"round": math.Round,
+	"floor": math.Floor,
+	"ceil":  math.Ceil,
+}
+
+var calculatorConstants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+// Evaluate computes an arithmetic expression. ^ is the power operator and
+// binds tighter than the unary minus, so -2^2 is -4.
+func Evaluate(expression string) (float64, error) {
+	p := &parser{input: []rune(strings.ToLower(expression))}
+	value, err := p.expression()
+	if err != nil {
+		return 0, err
+	}
+
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos+1)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("the result is not a finite number")
+	}
+	return value, nil
+}
+
+type parser struct {
+	input []rune
+	pos   int
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *parser) peek() rune {
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+// expression = term { ("+" | "-") term }
+func (p *parser) expression() (float64, error) {
+	value, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		switch p.peek() {
+		case '+':
+			p.pos++
+			right, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			value += right
+		case '-':
+			p.pos++
+			right, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			value -= right
+		default:
+			return value, nil
+		}
+	}
+}
+
+// term = unary { ("*" | "/" | "%") unary }
+func (p *parser) term() (float64, error) {
+	value, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		operator := p.peek()
+		if operator != '*' && operator != '/' && operator != '%' {
+			return value, nil
+		}
+		p.pos++
+
+		right, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+
+		switch operator {
+		case '*':
+			value *= right
+		case '/':
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			value /= right
+		case '%':
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			value = math.Mod(value, right)
+		}
+	}
+}
+
+// unary = ("+" | "-") unary | power
+func (p *parser) unary() (float64, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		value, err := p.unary()
+		return -value, err
+	case '+':
+		p.pos++
+		return p.unary()
+	}
+	return p.power()
+}
+
+// power = primary [ "^" unary ]
+func (p *parser) power() (float64, error) {
+	base, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+
+	if p.peek() != '^' {
+		return base, nil
+	}
+	p.pos++
+
+	exponent, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(base, exponent), nil
+}
+
+// primary = number | constant | function "(" expression ")" | "(" expression ")"
+func (p *parser) primary() (float64, error) {
+	next := p.peek()
+	switch {
+	case next == '(':
+		p.pos++
+		value, err := p.expression()
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return value, nil
+	case unicode.IsDigit(next) || next == '.':
+		return p.number()
+	case unicode.IsLetter(next):
+		return p.identifier()
+	case next == 0:
+		return 0, fmt.Errorf("unexpected end of expression")
+	}
+	return 0, fmt.Errorf("unexpected %q at position %d", next, p.pos+1)
+}
+
+func (p *parser) number() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && (unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+		p.pos++
+	}
+	return strconv.ParseFloat(string(p.input[start:p.pos]), 64)
+}
+
+func (p *parser) identifier() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && unicode.IsLetter(p.input[p.pos]) {
+		p.pos++
+	}
+	name := string(p.input[start:p.pos])
+
+	if value, ok := calculatorConstants[name]; ok {
+		return value, nil
+	}
+
+	function, ok := calculatorFunctions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown name %s", name)
+	}
+	if p.peek() != '(' {
+		return 0, fmt.Errorf("missing ( after %s", name)
+	}
+
+	argument, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+	return function(argument), nil
+}
@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"7 / 2", 3.5},
+		{"10 % 4", 2},
+		{"-2^2", -4},
+		{"(-2)^2", 4},
+		{"2^3^2", 512},
+		{"2^-1", 0.5},
+		{"--3", 3},
+		{"+.5", 0.5},
+		{"SQRT(16)", 4},
+		{"abs(-3) + round(2.5)", 6},
+		{"2 * pi", 2 * math.Pi},
+		{"ln(e)", 1},
+		{"log(1000)", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, err := Evaluate(tt.expression)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"", "unexpected end of expression"},
+		{"2 +", "unexpected end of expression"},
+		{"1 / 0", "division by zero"},
+		{"1 % (2 - 2)", "division by zero"},
+		{"(1 + 2", "missing )"},
+		{"1 2", `unexpected '2' at position 3`},
+		{"2 * #", `unexpected '#' at position 5`},
+		{"foo(1)", "unknown name foo"},
+		{"sqrt 4", "missing ( after sqrt"},
+		{"log(0)", "the result is not a finite number"},
+		{"1..2", "invalid syntax"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := Evaluate(tt.expression)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/marlosl/gpt-telegram-bot/clients/db"
+	"github.com/marlosl/gpt-telegram-bot/services/chatgpt"
+)
+
+type contextKey struct{}
+
+type threadContextKey struct{}
+
+// WithChatId returns a context carrying the chat the tools act on.
+func WithChatId(ctx context.Context, chatId string) context.Context {
+	return context.WithValue(ctx, contextKey{}, chatId)
+}
+
+func chatId(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// WithThreadId returns a context carrying the conversation thread the tools
+// act on.
+func WithThreadId(ctx context.Context, threadId string) context.Context {
+	return context.WithValue(ctx, threadContextKey{}, threadId)
+}
+
+func threadId(ctx context.Context) string {
+	id, _ := ctx.Value(threadContextKey{}).(string)
+	return id
+}
+
+// NewRegistry returns the built-in tools. The conversation search is only
+// registered when the chat history is available.
+func NewRegistry(history *db.HistoryRepository) *chatgpt.ToolRegistry {
+	registry := chatgpt.NewToolRegistry()
+	registry.Register(currentTimeTool())
+	registry.Register(calculatorTool())
+	registry.Register(convertUnitsTool())
+	if history != nil {
+		registry.Register(searchConversationTool(history))
+	}
+	return registry
+}
+
+func decodeArguments(arguments json.RawMessage, value interface{}) error {
+	return json.Unmarshal(arguments, value)
+}
@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+type unit struct {
+	dimension string
+	// factor converts the unit to the base unit of its dimension.
+	factor float64
+}
+
+var units = map[string]unit{
+	"mm":  {"length", 0.001},
+	"cm":  {"length", 0.01},
+	"m":   {"length", 1},
+	"km":  {"length", 1000},
+	"in":  {"length", 0.0254},
+	"ft":  {"length", 0.3048},
+	"yd":  {"length", 0.9144},
+	"mi":  {"length", 1609.344},
+	"nmi": {"length", 1852},
+
+	"mg": {"mass", 0.000001},
+	"g":  {"mass", 0.001},
+	"kg": {"mass", 1},
+	"t":  {"mass", 1000},
+	"oz": {"mass", 0.028349523125},
+	"lb": {"mass", 0.45359237},
+	"st": {"mass", 6.35029318},
+
+	"ml":   {"volume", 0.001},
+	"l":    {"volume", 1},
+	"m3":   {"volume", 1000},
+	"floz": {"volume", 0.0295735295625},
+	"cup":  {"volume", 0.2365882365},
+	"pt":   {"volume", 0.473176473},
+	"qt":   {"volume", 0.946352946},
+	"gal":  {"volume", 3.785411784},
+
+	"m/s":  {"speed", 1},
+	"km/h": {"speed", 1 / 3.6},
+	"mph":  {"speed", 0.44704},
+	"kn":   {"speed", 1852.0 / 3600},
+
+	"ms":   {"time", 0.001},
+	"s":    {"time", 1},
+	"min":  {"time", 60},
+	"h":    {"time", 3600},
+	"day":  {"time", 86400},
+	"week": {"time", 604800},
+}
+
+var unitAliases = map[string]string{
+	"millimeter": "mm", "centimeter": "cm", "meter": "m", "metre": "m", "kilometer": "km", "kilometre": "km",
+	"inch": "in", "inches": "in", "foot": "ft", "feet": "ft", "yard": "yd", "mile": "mi", "nautical mile": "nmi",
+	"milligram": "mg", "gram": "g", "kilogram": "kg", "ton": "t", "tonne": "t", "ounce": "oz", "pound": "lb", "lbs": "lb", "stone": "st",
+	"milliliter": "ml", "liter": "l", "litre": "l", "fl oz": "floz", "pint": "pt", "quart": "qt", "gallon": "gal",
+	"kph": "km/h", "kmh": "km/h", "knot": "kn", "kt": "kn",
+	"sec": "s", "second": "s", "minute": "min", "hour": "h", "hr": "h", "days": "day", "weeks": "week",
+	"celsius": "c", "°c": "c", "fahrenheit": "f", "°f": "f", "kelvin": "k",
+}
+
+// ConvertUnits converts the value between two units of the same dimension.
+func ConvertUnits(value float64, from string, to string) (float64, error) {
+	fromUnit := normalizeUnit(from)
+	toUnit := normalizeUnit(to)
+
+	if isTemperature(fromUnit) || isTemperature(toUnit) {
+		if !isTemperature(fromUnit) || !isTemperature(toUnit) {
+			return 0, fmt.Errorf("can't convert %s to %s", from, to)
+		}
+		return fromKelvin(toKelvin(value, fromUnit), toUnit), nil
+	}
+
+	source, ok := units[fromUnit]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %s", from)
+	}
+	target, ok := units[toUnit]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %s", to)
+	}
+	if source.dimension != target.dimension {
+		return 0, fmt.Errorf("can't convert %s (%s) to %s (%s)", from, source.dimension, to, target.dimension)
+	}
+	return value * source.factor / target.factor, nil
+}
+
+func normalizeUnit(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := unitAliases[name]; ok {
+		return alias
+	}
+	if alias, ok := unitAliases[strings.TrimSuffix(name, "s")]; ok {
+		return alias
+	}
+	return name
+}
+
+func isTemperature(name string) bool {
+	return name == "c" || name == "f" || name == "k"
+}
+
+func toKelvin(value float64, name string) float64 {
+	switch name {
+	case "c":
+		return value + 273.15
+	case "f":
+		return (value-32)*5/9 + 273.15
+	}
+	return value
+}
+
+func fromKelvin(value float64, name string) float64 {
+	switch name {
+	case "c":
+		return value - 273.15
+	case "f":
+		return (value-273.15)*9/5 + 32
+	}
+	return value
+}
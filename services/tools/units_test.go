@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertUnits(t *testing.T) {
+	tests := []struct {
+		value float64
+		from  string
+		to    string
+		want  float64
+	}{
+		{1, "km", "m", 1000},
+		{1, "mile", "km", 1.609344},
+		{12, "inches", "ft", 1},
+		{3, "Feet", "yards", 1},
+		{1, "kg", "lbs", 2.2046226218},
+		{1, "gallon", "liters", 3.785411784},
+		{100, "km/h", "m/s", 27.7777777778},
+		{2, "hours", "minutes", 120},
+		{1, "week", "days", 7},
+		{100, "celsius", "f", 212},
+		{32, "°F", "c", 0},
+		{0, "k", "celsius", -273.15},
+		{5, "m", "m", 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			got, err := ConvertUnits(tt.value, tt.from, tt.to)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-6)
+		})
+	}
+}
+
+func TestConvertUnitsErrors(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		want string
+	}{
+		{"km", "kg", "can't convert km (length) to kg (mass)"},
+		{"c", "m", "can't convert c to m"},
+		{"furlong", "m", "unknown unit furlong"},
+		{"m", "parsec", "unknown unit parsec"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			_, err := ConvertUnits(1, tt.from, tt.to)
+			require.Error(t, err)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package web
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	minBlockLength  = 40
+	maxLinkDensity  = 0.5
+	maxExtractRunes = 100000
+)
+
+// skippedElements never hold article text.
+var skippedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Svg:      true,
+	atom.Iframe:   true,
+	atom.Button:   true,
+	atom.Select:   true,
+}
+
+var blockElements = map[atom.Atom]bool{
+	atom.P:          true,
+	atom.H1:         true,
+	atom.H2:         true,
+	atom.H3:         true,
+	atom.H4:         true,
+	atom.H5:         true,
+	atom.H6:         true,
+	atom.Li:         true,
+	atom.Pre:        true,
+	atom.Blockquote: true,
+	atom.Td:         true,
+	atom.Dd:         true,
+}
+
+var headingElements = map[atom.Atom]bool{
+	atom.H1: true,
+	atom.H2: true,
+	atom.H3: true,
+	atom.H4: true,
+	atom.H5: true,
+	atom.H6: true,
+}
+
+// Extract returns the title and the readable text of an HTML page. The text
+// comes from the <article> or <main> element when there is one, and blocks
+// that are short or mostly links, such as menus and share buttons, are
+// dropped.
+func Extract(content []byte) (string, string) {
+	document, err := html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return "", ""
+	}
+
+	title := pageTitle(document)
+
+	root := findElement(document, atom.Article)
+	if root == nil {
+		root = findElement(document, atom.Main)
+	}
+	if root == nil {
+		root = document
+	}
+
+	var blocks []string
+	collectBlocks(root, &blocks)
+
+	text := strings.Join(blocks, "\n\n")
+	if runes := []rune(text); len(runes) > maxExtractRunes {
+		text = string(runes[:maxExtractRunes])
+	}
+	return title, text
+}
+
+func pageTitle(document *html.Node) string {
+	var title string
+	walk(document, func(node *html.Node) bool {
+		if node.DataAtom == atom.Meta && attribute(node, "property") == "og:title" {
+			title = attribute(node, "content")
+			return false
+		}
+		if node.DataAtom == atom.Title && title == "" {
+			title = textOf(node)
+		}
+		return true
+	})
+	return normalizeSpaces(title)
+}
+
+func collectBlocks(node *html.Node, blocks *[]string) {
+	if node.Type == html.ElementNode && skippedElements[node.DataAtom] {
+		return
+	}
+
+	if node.Type == html.ElementNode && blockElements[node.DataAtom] {
+		text := normalizeSpaces(textOf(node))
+		if keepBlock(node, text) {
+			*blocks = append(*blocks, text)
+		}
+		return
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		collectBlocks(child, blocks)
+	}
+}
+
+func keepBlock(node *html.Node, text string) bool {
+	if text == "" {
+		return false
+	}
+	if headingElements[node.DataAtom] || node.DataAtom == atom.Pre {
+		return true
+	}
+	if len([]rune(text)) < minBlockLength {
+		return false
+	}
+
+	linkLength := 0
+	walk(node, func(child *html.Node) bool {
+		if child.DataAtom == atom.A {
+			linkLength += len([]rune(normalizeSpaces(textOf(child))))
+			return false
+		}
+		return true
+	})
+	return float64(linkLength)/float64(len([]rune(text))) <= maxLinkDensity
+}
+
+// walk visits the nodes depth first while visit returns true for them.
+func walk(node *html.Node, visit func(*html.Node) bool) {
+	if !visit(node) {
+		return
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		walk(child, visit)
+	}
+}
+
+func findElement(node *html.Node, element atom.Atom) *html.Node {
+	var found *html.Node
+	walk(node, func(child *html.Node) bool {
+		if found != nil {
+			return false
+		}
+		if child.Type == html.ElementNode && child.DataAtom == element {
+			found = child
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+func textOf(node *html.Node) string {
+	var text strings.Builder
+	walk(node, func(child *html.Node) bool {
+		if child.Type == html.ElementNode && skippedElements[child.DataAtom] {
+			return false
+		}
+		if child.Type == html.TextNode {
+			text.WriteString(child.Data)
+			text.WriteString(" ")
+		}
+		return true
+	})
+	return text.String()
+}
+
+func attribute(node *html.Node, name string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func normalizeSpaces(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,167 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// MaxPageSize bounds the bytes read from a page.
+	MaxPageSize  = 2 * 1024 * 1024
+	maxRedirects = 5
+	fetchTimeout = 20 * time.Second
+	userAgent    = "Mozilla/5.0 (compatible; gpt-telegram-bot)"
+)
+
+var ErrBlockedAddress = errors.New("the address is not public")
+
+var allowedContentTypes = map[string]bool{
+	"text/html":             true,
+	"application/xhtml+xml": true,
+	"text/plain":            true,
+}
+
+// carrierNat is the shared address space of RFC 6598, not covered by
+// net.IP.IsPrivate.
+var carrierNat = &net.IPNet{IP: net.IP{100, 64, 0, 0}, Mask: net.CIDRMask(10, 32)}
+
+// isPublicAddress is the check applied to every connection, replaced by
+// the tests to reach a local server.
+var isPublicAddress = IsPublic
+
+type Page struct {
+	Url   string
+	Title string
+	Text  string
+}
+
+// Fetcher downloads web pages. Unless AllowPrivate is set, connections to
+// loopback, private, link-local and other non-public addresses are refused
+// after DNS resolution, including on redirects, so shared links cannot
+// reach internal services.
+type Fetcher struct {
+	AllowPrivate bool
+	client       *http.Client
+}
+
+func NewFetcher() *Fetcher {
+	f := &Fetcher{}
+
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: f.checkAddress,
+	}
+	f.client = &http.Client{
+		Timeout: fetchTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("too many redirects")
+			}
+			return checkScheme(req.URL)
+		},
+	}
+	return f
+}
+
+func (f *Fetcher) checkAddress(network string, address string, conn syscall.RawConn) error {
+	if f.AllowPrivate {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicAddress(ip) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+// IsPublic reports whether the address is routable on the internet.
+func IsPublic(ip net.IP) bool {
+	return !(ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified() ||
+		carrierNat.Contains(ip))
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %s", u.Scheme)
+	}
+	return nil
+}
+
+// Fetch downloads the page and extracts its readable text. Only HTML and
+// plain text pages are accepted and at most MaxPageSize bytes are read.
+func (f *Fetcher) Fetch(ctx context.Context, rawUrl string) (*Page, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkScheme(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("user-agent", userAgent)
+	req.Header.Set("accept", "text/html,application/xhtml+xml,text/plain;q=0.9")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBlockedAddress) {
+			return nil, ErrBlockedAddress
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("the page answered with status %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("content-type"))
+	if !allowedContentTypes[mediaType] {
+		return nil, fmt.Errorf("unsupported content type %s", mediaType)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxPageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{Url: resp.Request.URL.String()}
+	if mediaType == "text/plain" {
+		page.Text = strings.TrimSpace(string(body))
+	} else {
+		page.Title, page.Text = Extract(body)
+	}
+
+	if page.Text == "" {
+		return nil, errors.New("no readable text found")
+	}
+	return page, nil
+}
@@ -0,0 +1,180 @@
+package web
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const articleText = "The committee approved the new budget after a long debate about the costs of the project."
+
+func newTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><head><title>News</title></head><body><article><p>" + articleText + "</p></article></body></html>"))
+	})
+	mux.HandleFunc("/metadata", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Repeat("a", MaxPageSize+1024)))
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"text": "hello"}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+// allowLoopback treats the loopback address of the test server as public
+// while the other checks stay in place.
+func allowLoopback(t *testing.T) {
+	isPublicAddress = func(ip net.IP) bool {
+		return ip.IsLoopback() || IsPublic(ip)
+	}
+	t.Cleanup(func() { isPublicAddress = IsPublic })
+}
+
+func TestFetch(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		path          string
+		allowPrivate  bool
+		allowLoopback bool
+		wantErr       string
+		wantBlocked   bool
+		wantTitle     string
+		wantText      string
+		wantLength    int
+	}{
+		{
+			name:         "allowed with AllowPrivate",
+			path:         "/article",
+			allowPrivate: true,
+			wantTitle:    "News",
+			wantText:     articleText,
+		},
+		{
+			name:        "loopback refused",
+			path:        "/article",
+			wantBlocked: true,
+		},
+		{
+			name:          "redirect to a private address refused",
+			path:          "/metadata",
+			allowLoopback: true,
+			wantBlocked:   true,
+		},
+		{
+			name:         "size cap",
+			path:         "/large",
+			allowPrivate: true,
+			wantLength:   MaxPageSize,
+		},
+		{
+			name:         "content type rejected",
+			path:         "/json",
+			allowPrivate: true,
+			wantErr:      "unsupported content type application/json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.allowLoopback {
+				allowLoopback(t)
+			}
+			fetcher := NewFetcher()
+			fetcher.AllowPrivate = tt.allowPrivate
+
+			page, err := fetcher.Fetch(context.Background(), server.URL+tt.path)
+			switch {
+			case tt.wantBlocked:
+				assert.ErrorIs(t, err, ErrBlockedAddress)
+			case tt.wantErr != "":
+				assert.EqualError(t, err, tt.wantErr)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantTitle, page.Title)
+				if tt.wantLength > 0 {
+					assert.Len(t, page.Text, tt.wantLength)
+				} else {
+					assert.Equal(t, tt.wantText, page.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchRejectsSchemes(t *testing.T) {
+	_, err := NewFetcher().Fetch(context.Background(), "file:///etc/passwd")
+	assert.EqualError(t, err, "unsupported scheme file")
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"fd00::1", false},
+		{"224.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsPublic(net.ParseIP(tt.ip)))
+		})
+	}
+}
+
+func TestExtract(t *testing.T) {
+	page := `<html>
+<head>
+	<title>Ignored title</title>
+	<meta property="og:title" content="Budget approved">
+	<script>var tracking = "should not appear in the text";</script>
+	<style>body { color: red; }</style>
+</head>
+<body>
+	<header><p>Site header with a long slogan that is not part of the article</p></header>
+	<nav><ul><li><a href="/">Home</a></li><li><a href="/news">News</a></li></ul></nav>
+	<main>
+		<h1>Budget approved</h1>
+		<p>The committee approved the new budget after a long debate about the costs.</p>
+		<p>Short line.</p>
+		<p><a href="/a">Share this story on every social network</a> <a href="/b">and more</a> now</p>
+		<p>Members said the <a href="/plan">plan</a> will be reviewed again next year by the board.</p>
+		<aside><p>Related: another story that should be dropped from the text</p></aside>
+		<form><p>Subscribe to the newsletter to receive every story by email</p></form>
+	</main>
+	<footer><p>Copyright notice and the links of the footer of the site</p></footer>
+</body>
+</html>`
+
+	title, text := Extract([]byte(page))
+	assert.Equal(t, "Budget approved", title)
+	assert.Equal(t, "Budget approved\n\n"+
+		"The committee approved the new budget after a long debate about the costs.\n\n"+
+		"Members said the plan will be reviewed again next year by the board.", text)
+}
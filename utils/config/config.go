@@ -2,10 +2,12 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"sync"
 
 	"github.com/marlosl/gpt-telegram-bot/clients/ssm"
 	"github.com/marlosl/gpt-telegram-bot/consts"
+	"github.com/marlosl/gpt-telegram-bot/utils/logger"
 )
 
 type ConfigType int64
@@ -26,9 +28,28 @@ type Config struct {
 	GptApiKey             string
 	SendImageByUrl        bool
 	GptModel              string
+	GptVisionModel        string
+	ImageModel            string
 	TelegramWebhookToken  string
+	TranscriptionProvider string
+	TranscriptionUrl      string
+	MaxVoiceDuration      int
+	TtsProvider           string
+	TtsUrl                string
+	TtsVoice              string
+	EmbeddingsProvider    string
+	EmbeddingsUrl         string
+	EmbeddingsModel       string
+	ModerationProvider    string
+	ModerationUrl         string
+	ModerationPolicy      string
+	ModerationKeywords    string
+	ModerationStages      string
+	ModerationRefusal     string
 }
 
+const DefaultMaxVoiceDuration = 120
+
 func NewConfig(t ConfigType) *Config {
 	if Store == nil {
 		mutex.Lock()
@@ -42,7 +63,24 @@ func NewConfig(t ConfigType) *Config {
 					GptApiKey:             ssm.Get(consts.PARAMETER_GPT_API_KEY),
 					SendImageByUrl:        ssm.Get(consts.PARAMETER_SEND_IMAGE_BY_URL) == "true",
 					GptModel:              ssm.Get(consts.PARAMETER_GPT_MODEL),
+					GptVisionModel:        ssm.Get(consts.PARAMETER_GPT_VISION_MODEL),
+					ImageModel:            ssm.Get(consts.PARAMETER_IMAGE_MODEL),
 					TelegramWebhookToken:  ssm.Get(consts.PARAMETER_TELEGRAM_WEBHOOK_TOKEN),
+					TranscriptionProvider: ssm.Get(consts.PARAMETER_TRANSCRIPTION_PROVIDER),
+					TranscriptionUrl:      ssm.Get(consts.PARAMETER_TRANSCRIPTION_URL),
+					MaxVoiceDuration:      toInt(ssm.Get(consts.PARAMETER_MAX_VOICE_DURATION), DefaultMaxVoiceDuration),
+					TtsProvider:           ssm.Get(consts.PARAMETER_TTS_PROVIDER),
+					TtsUrl:                ssm.Get(consts.PARAMETER_TTS_URL),
+					TtsVoice:              ssm.Get(consts.PARAMETER_TTS_VOICE),
+					EmbeddingsProvider:    ssm.Get(consts.PARAMETER_EMBEDDINGS_PROVIDER),
+					EmbeddingsUrl:         ssm.Get(consts.PARAMETER_EMBEDDINGS_URL),
+					EmbeddingsModel:       ssm.Get(consts.PARAMETER_EMBEDDINGS_MODEL),
+					ModerationProvider:    ssm.Get(consts.PARAMETER_MODERATION_PROVIDER),
+					ModerationUrl:         ssm.Get(consts.PARAMETER_MODERATION_URL),
+					ModerationPolicy:      ssm.Get(consts.PARAMETER_MODERATION_POLICY),
+					ModerationKeywords:    ssm.Get(consts.PARAMETER_MODERATION_KEYWORDS),
+					ModerationStages:      ssm.Get(consts.PARAMETER_MODERATION_STAGES),
+					ModerationRefusal:     ssm.Get(consts.PARAMETER_MODERATION_REFUSAL),
 				}
 			case File:
 				Store = &Config{
@@ -51,10 +89,41 @@ func NewConfig(t ConfigType) *Config {
 					GptApiKey:             os.Getenv(consts.GptApiKey),
 					SendImageByUrl:        false,
 					GptModel:              "",
+					GptVisionModel:        os.Getenv(consts.GptVisionModel),
+					ImageModel:            os.Getenv(consts.ImageModel),
 					TelegramWebhookToken:  "",
+					TranscriptionProvider: os.Getenv(consts.TranscriptionProvider),
+					TranscriptionUrl:      os.Getenv(consts.TranscriptionUrl),
+					MaxVoiceDuration:      toInt(os.Getenv(consts.MaxVoiceDuration), DefaultMaxVoiceDuration),
+					TtsProvider:           os.Getenv(consts.TtsProvider),
+					TtsUrl:                os.Getenv(consts.TtsUrl),
+					TtsVoice:              os.Getenv(consts.TtsVoice),
+					EmbeddingsProvider:    os.Getenv(consts.EmbeddingsProvider),
+					EmbeddingsUrl:         os.Getenv(consts.EmbeddingsUrl),
+					EmbeddingsModel:       os.Getenv(consts.EmbeddingsModel),
+					ModerationProvider:    os.Getenv(consts.ModerationProvider),
+					ModerationUrl:         os.Getenv(consts.ModerationUrl),
+					ModerationPolicy:      os.Getenv(consts.ModerationPolicy),
+					ModerationKeywords:    os.Getenv(consts.ModerationKeywords),
+					ModerationStages:      os.Getenv(consts.ModerationStages),
+					ModerationRefusal:     os.Getenv(consts.ModerationRefusal),
 				}
 			}
+			logger.AddSecrets(
+				Store.TelegramBotTextToken,
+				Store.TelegramBotImageToken,
+				Store.GptApiKey,
+				Store.TelegramWebhookToken,
+			)
 		}
 	}
 	return Store
 }
+
+func toInt(value string, defaultValue int) int {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+	return i
+}
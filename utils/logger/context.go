@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type correlationKey struct{}
+
+// CorrelationIdKey is the log attribute carrying the correlation id.
+const CorrelationIdKey = "correlation_id"
+
+// NewCorrelationId derives the correlation id of a Telegram update, so the
+// webhook and the worker handling the same update log the same id.
+func NewCorrelationId(updateId int64) string {
+	return fmt.Sprintf("upd-%d", updateId)
+}
+
+// WithCorrelationId returns a copy of ctx carrying the correlation id.
+func WithCorrelationId(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationId returns the correlation id carried by ctx, if any.
+func CorrelationId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// contextAttrs returns the attributes added to every record logged with a
+// context: the correlation id and the current trace and span ids.
+func contextAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+
+	var attrs []slog.Attr
+	if id := CorrelationId(ctx); id != "" {
+		attrs = append(attrs, slog.String(CorrelationIdKey, id))
+	}
+
+	span := trace.SpanContextFromContext(ctx)
+	if span.IsValid() {
+		attrs = append(attrs,
+			slog.String("trace_id", span.TraceID().String()),
+			slog.String("span_id", span.SpanID().String()),
+		)
+	}
+	return attrs
+}
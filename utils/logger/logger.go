@@ -0,0 +1,175 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+)
+
+const Redacted = "[REDACTED]"
+
+var (
+	mutex   = &sync.Mutex{}
+	secrets []string
+
+	secretPatterns = []*regexp.Regexp{
+		// Telegram bot tokens, with or without the "bot" prefix used in URLs.
+		regexp.MustCompile(`(bot)?\d{6,}:[A-Za-z0-9_-]{30,}`),
+		// OpenAI API keys.
+		regexp.MustCompile(`sk-[A-Za-z0-9_-]{20,}`),
+		regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/=-]+`),
+	}
+
+	secretKeys = map[string]bool{
+		"authorization":                   true,
+		"x-api-key":                       true,
+		consts.TelegramWebhookTokenHeader: true,
+		"secret_token":                    true,
+		"token":                           true,
+		"api_key":                         true,
+		"apikey":                          true,
+		"password":                        true,
+	}
+
+	contentKeys = map[string]bool{
+		"text":     true,
+		"prompt":   true,
+		"content":  true,
+		"caption":  true,
+		"body":     true,
+		"message":  true,
+		"response": true,
+	}
+)
+
+func init() {
+	Init()
+}
+
+// Init configures the default slog logger. LOG_LEVEL selects the level
+// (debug, info, warn, error) and LOG_REDACT_CONTENT=true hides user
+// content attributes such as prompts and answers.
+func Init() {
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level:       parseLevel(os.Getenv(consts.LogLevel)),
+		ReplaceAttr: replaceAttr(os.Getenv(consts.LogRedactContent) == "true"),
+	})
+	slog.SetDefault(slog.New(&redactHandler{Handler: handler}))
+}
+
+// AddSecrets registers values that must never be written to the logs, such
+// as the tokens loaded from SSM.
+func AddSecrets(values ...string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, value := range values {
+		if len(value) >= 8 {
+			secrets = append(secrets, value)
+		}
+	}
+}
+
+// Redact replaces the known secrets and anything that looks like a token in s.
+func Redact(s string) string {
+	mutex.Lock()
+	known := secrets
+	mutex.Unlock()
+
+	for _, secret := range known {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+func replaceAttr(redactContent bool) func(groups []string, a slog.Attr) slog.Attr {
+	return func(groups []string, a slog.Attr) slog.Attr {
+		key := strings.ToLower(a.Key)
+		if len(groups) == 0 && (a.Key == slog.MessageKey || a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+			if a.Key == slog.MessageKey {
+				return slog.String(a.Key, Redact(a.Value.String()))
+			}
+			return a
+		}
+
+		if secretKeys[key] {
+			return slog.String(a.Key, Redacted)
+		}
+
+		if redactContent && contentKeys[key] {
+			return slog.Int(a.Key+"_length", len(a.Value.String()))
+		}
+
+		switch a.Value.Kind() {
+		case slog.KindString:
+			return slog.String(a.Key, Redact(a.Value.String()))
+		case slog.KindAny:
+			if err, ok := a.Value.Any().(error); ok {
+				return slog.String(a.Key, Redact(err.Error()))
+			}
+		}
+		return a
+	}
+}
+
+// redactHandler resolves attributes built from fmt.Stringer and error values
+// before they reach ReplaceAttr, so their text is redacted as well.
+type redactHandler struct {
+	slog.Handler
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	record := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	record.AddAttrs(contextAttrs(ctx)...)
+	r.Attrs(func(a slog.Attr) bool {
+		record.AddAttrs(resolve(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	resolved := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		resolved = append(resolved, resolve(a))
+	}
+	return &redactHandler{Handler: h.Handler.WithAttrs(resolved)}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+func resolve(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindAny {
+		return a
+	}
+
+	switch v := a.Value.Any().(type) {
+	case error:
+		return slog.String(a.Key, v.Error())
+	case interface{ String() string }:
+		return slog.String(a.Key, v.String())
+	}
+	return a
+}
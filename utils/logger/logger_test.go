@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const botToken = "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw0"
+
+func TestRedact(t *testing.T) {
+	mutex.Lock()
+	previous := secrets
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		secrets = previous
+		mutex.Unlock()
+	})
+	AddSecrets("short", "my-database-password")
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain text", "nothing to hide here", "nothing to hide here"},
+		{"bot token", "token " + botToken + " leaked", "token " + Redacted + " leaked"},
+		{"bot token in url", "https://api.telegram.org/bot" + botToken + "/getMe", "https://api.telegram.org/" + Redacted + "/getMe"},
+		{"openai key", "key sk-abcdefghijklmnopqrstuvwxyz123456", "key " + Redacted},
+		{"bearer token", "Authorization: Bearer abc.def-ghi", "Authorization: " + Redacted},
+		{"known secret", "connect with my-database-password now", "connect with " + Redacted + " now"},
+		{"short values are not secrets", "a short text", "a short text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Redact(tt.text))
+		})
+	}
+}
+
+func TestHandlerRedactsAttributes(t *testing.T) {
+	tests := []struct {
+		name          string
+		redactContent bool
+		attrs         []any
+		want          map[string]any
+	}{
+		{
+			name:  "secret keys",
+			attrs: []any{"token", "anything", "Authorization", "Bearer abc"},
+			want:  map[string]any{"token": Redacted, "Authorization": Redacted},
+		},
+		{
+			name:  "secrets in values and errors",
+			attrs: []any{"url", "https://api.telegram.org/bot" + botToken + "/getMe", "error", errors.New("failed: " + botToken)},
+			want:  map[string]any{"url": "https://api.telegram.org/" + Redacted + "/getMe", "error": "failed: " + Redacted},
+		},
+		{
+			name:          "content kept",
+			redactContent: false,
+			attrs:         []any{"text", "hello"},
+			want:          map[string]any{"text": "hello"},
+		},
+		{
+			name:          "content replaced by its length",
+			redactContent: true,
+			attrs:         []any{"text", "hello", "chat_id", "42"},
+			want:          map[string]any{"text_length": float64(5), "chat_id": "42"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			handler := slog.NewJSONHandler(&out, &slog.HandlerOptions{ReplaceAttr: replaceAttr(tt.redactContent)})
+			log := slog.New(&redactHandler{Handler: handler})
+
+			log.InfoContext(context.Background(), "message with "+botToken, tt.attrs...)
+
+			var entry map[string]any
+			require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+			assert.Equal(t, "message with "+Redacted, entry[slog.MessageKey])
+			for key, value := range tt.want {
+				assert.Equal(t, value, entry[key], key)
+			}
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		level string
+		want  slog.Level
+	}{
+		{"debug", slog.LevelDebug},
+		{"WARN", slog.LevelWarn},
+		{"warning", slog.LevelWarn},
+		{"error", slog.LevelError},
+		{"", slog.LevelInfo},
+		{"verbose", slog.LevelInfo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseLevel(tt.level))
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+)
+
+type Unit string
+
+const (
+	Count        Unit = "Count"
+	Milliseconds Unit = "Milliseconds"
+	None         Unit = "None"
+
+	DefaultNamespace = "GptTelegramBot"
+
+	// maxValues is the largest number of values CloudWatch accepts for a
+	// metric in a single EMF document.
+	maxValues = 100
+)
+
+type Dimensions map[string]string
+
+type metric struct {
+	unit   Unit
+	values []float64
+}
+
+type metricSet struct {
+	dimensions Dimensions
+	metrics    map[string]*metric
+}
+
+var (
+	mutex  = &sync.Mutex{}
+	sets   = map[string]*metricSet{}
+	output = io.Writer(os.Stdout)
+)
+
+// Add records a value for the metric. Values are buffered and written by
+// Flush in CloudWatch Embedded Metric Format, one document per set of
+// dimensions, so CloudWatch can compute sums and percentiles from them.
+func Add(name string, unit Unit, value float64, dimensions Dimensions) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	key := dimensionsKey(dimensions)
+	set, ok := sets[key]
+	if !ok {
+		set = &metricSet{
+			dimensions: dimensions,
+			metrics:    map[string]*metric{},
+		}
+		sets[key] = set
+	}
+
+	m, ok := set.metrics[name]
+	if !ok {
+		m = &metric{unit: unit}
+		set.metrics[name] = m
+	}
+	m.values = append(m.values, value)
+
+	if len(m.values) >= maxValues {
+		write(set)
+		delete(sets, key)
+	}
+}
+
+// Increment adds one to the counter.
+func Increment(name string, dimensions Dimensions) {
+	Add(name, Count, 1, dimensions)
+}
+
+// Since records the time elapsed since start in milliseconds.
+func Since(name string, start time.Time, dimensions Dimensions) {
+	Add(name, Milliseconds, float64(time.Since(start).Milliseconds()), dimensions)
+}
+
+// Flush writes the buffered metrics. Lambda handlers flush before returning,
+// as the process may be frozen afterwards.
+func Flush() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for key, set := range sets {
+		write(set)
+		delete(sets, key)
+	}
+}
+
+func write(set *metricSet) {
+	names := make([]string, 0, len(set.dimensions))
+	for name := range set.dimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	definitions := make([]map[string]string, 0, len(set.metrics))
+	document := map[string]interface{}{}
+	for name, m := range set.metrics {
+		definitions = append(definitions, map[string]string{
+			"Name": name,
+			"Unit": string(m.unit),
+		})
+		document[name] = m.values
+	}
+	for name, value := range set.dimensions {
+		document[name] = value
+	}
+
+	document["_aws"] = map[string]interface{}{
+		"Timestamp": time.Now().UnixMilli(),
+		"CloudWatchMetrics": []map[string]interface{}{
+			{
+				"Namespace":  namespace(),
+				"Dimensions": [][]string{names},
+				"Metrics":    definitions,
+			},
+		},
+	}
+
+	b, err := json.Marshal(document)
+	if err != nil {
+		slog.Error("Error marshalling metrics", "error", err)
+		return
+	}
+	output.Write(append(b, '\n'))
+}
+
+func namespace() string {
+	if ns := os.Getenv(consts.MetricsNamespace); ns != "" {
+		return ns
+	}
+	return DefaultNamespace
+}
+
+func dimensionsKey(dimensions Dimensions) string {
+	pairs := make([]string, 0, len(dimensions))
+	for name, value := range dimensions {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
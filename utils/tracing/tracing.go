@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+
+	"github.com/marlosl/gpt-telegram-bot/consts"
+	"github.com/marlosl/gpt-telegram-bot/utils/logger"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/marlosl/gpt-telegram-bot"
+
+var provider *sdktrace.TracerProvider
+
+// Init enables OpenTelemetry tracing when OTEL_EXPORTER_OTLP_ENDPOINT is
+// set, exporting spans with OTLP over HTTP (e.g. to a local collector).
+// Without it spans are no-ops.
+func Init(serviceName string) {
+	if provider != nil || os.Getenv(consts.OtelExporterEndpoint) == "" {
+		return
+	}
+
+	exporter, err := otlptracehttp.New(context.Background())
+	if err != nil {
+		slog.Error("Error creating the OTLP exporter", "error", err)
+		return
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+}
+
+// Start starts a span tagged with the correlation id carried by ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if id := logger.CorrelationId(ctx); id != "" {
+		attrs = append(attrs, attribute.String(logger.CorrelationIdKey, id))
+	}
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error, if any, and ends the span. The error message is
+// redacted first, since request errors may carry URLs with the bot token.
+func End(span trace.Span, err error) {
+	if err != nil {
+		message := logger.Redact(err.Error())
+		span.RecordError(errors.New(message))
+		span.SetStatus(codes.Error, message)
+	}
+	span.End()
+}
+
+// Flush exports the pending spans. Lambda freezes the process once the
+// handler returns, so handlers flush before returning.
+func Flush(ctx context.Context) {
+	if provider == nil {
+		return
+	}
+
+	err := provider.ForceFlush(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error flushing spans", "error", err)
+	}
+}
@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/marlosl/gpt-telegram-bot/utils/logger"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestEndRedactsErrors(t *testing.T) {
+	token := "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw0"
+
+	tests := []struct {
+		name    string
+		err     error
+		status  codes.Code
+		message string
+	}{
+		{
+			name:   "no error",
+			status: codes.Unset,
+		},
+		{
+			name:    "plain error",
+			err:     fmt.Errorf("chat request failed with response code: 500"),
+			status:  codes.Error,
+			message: "chat request failed with response code: 500",
+		},
+		{
+			name:    "error with the bot token",
+			err:     fmt.Errorf(`Post "https://api.telegram.org/bot%s/sendMessage": timeout`, token),
+			status:  codes.Error,
+			message: `Post "https://api.telegram.org/` + logger.Redacted + `/sendMessage": timeout`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			_, span := provider.Tracer(tracerName).Start(context.Background(), "test")
+
+			End(span, tt.err)
+
+			spans := recorder.Ended()
+			assert.Len(t, spans, 1)
+			assert.Equal(t, tt.status, spans[0].Status().Code)
+			assert.Equal(t, tt.message, spans[0].Status().Description)
+			for _, event := range spans[0].Events() {
+				for _, attr := range event.Attributes {
+					assert.NotContains(t, attr.Value.Emit(), token)
+				}
+			}
+		})
+	}
+}
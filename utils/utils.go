@@ -1,15 +1,18 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
 
 	"github.com/marlosl/gpt-telegram-bot/consts"
 	"github.com/marlosl/gpt-telegram-bot/utils/config"
+	"github.com/marlosl/gpt-telegram-bot/utils/logger"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/spf13/viper"
@@ -28,8 +31,32 @@ var configVars = []string{
 	consts.CloudfareApiKey,
 	consts.CloudfareApiEmail,
 	consts.GptApiKey,
+	consts.GptVisionModel,
+	consts.ImageModel,
 	consts.TelegramBotTextToken,
 	consts.TelegramBotImageToken,
+	consts.TranscriptionProvider,
+	consts.TranscriptionUrl,
+	consts.MaxVoiceDuration,
+	consts.TtsProvider,
+	consts.TtsUrl,
+	consts.TtsVoice,
+	consts.EmbeddingsProvider,
+	consts.EmbeddingsUrl,
+	consts.EmbeddingsModel,
+	consts.ModerationProvider,
+	consts.ModerationUrl,
+	consts.ModerationPolicy,
+	consts.ModerationKeywords,
+	consts.ModerationStages,
+	consts.ModerationRefusal,
+	consts.CacheTable,
+	consts.ImageBucket,
+	consts.ImageBucketPublicUrl,
+	consts.LogLevel,
+	consts.LogRedactContent,
+	consts.OtelExporterEndpoint,
+	consts.MetricsNamespace,
 }
 
 func InitConfig() {
@@ -106,32 +133,29 @@ func DownloadFile(filepath string, url string) error {
 	return err
 }
 
-func PrintRestyDebug(resp *resty.Response, err error) {
-	// Explore response object
-	fmt.Println("Response Info:")
-	fmt.Println("  Error      :", err)
-	fmt.Println("  Status Code:", resp.StatusCode())
-	fmt.Println("  Status     :", resp.Status())
-	fmt.Println("  Proto      :", resp.Proto())
-	fmt.Println("  Time       :", resp.Time())
-	fmt.Println("  Received At:", resp.ReceivedAt())
-	fmt.Println("  Body       :\n", resp)
-	fmt.Println()
-
-	// Explore trace info
-	fmt.Println("Request Trace Info:")
+// PrintRestyDebug logs the response and its trace info at debug level. The
+// request URL is redacted and the body is logged under the "body" key, so
+// LOG_REDACT_CONTENT hides it.
+func PrintRestyDebug(ctx context.Context, resp *resty.Response, err error) {
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
 	ti := resp.Request.TraceInfo()
+	url := ""
+	if resp.Request.RawRequest != nil {
+		url = logger.Redact(resp.Request.RawRequest.URL.String())
+	}
 
-	fmt.Println("  Request URL   :", resp.Request.RawRequest.URL)
-	fmt.Println("  DNSLookup     :", ti.DNSLookup)
-	fmt.Println("  ConnTime      :", ti.ConnTime)
-	fmt.Println("  TCPConnTime   :", ti.TCPConnTime)
-	fmt.Println("  TLSHandshake  :", ti.TLSHandshake)
-	fmt.Println("  ServerTime    :", ti.ServerTime)
-	fmt.Println("  ResponseTime  :", ti.ResponseTime)
-	fmt.Println("  TotalTime     :", ti.TotalTime)
-	fmt.Println("  IsConnReused  :", ti.IsConnReused)
-	fmt.Println("  IsConnWasIdle :", ti.IsConnWasIdle)
-	fmt.Println("  ConnIdleTime  :", ti.ConnIdleTime)
-	fmt.Println("  RequestAttempt:", ti.RequestAttempt)
+	slog.DebugContext(ctx, "Response",
+		"error", err,
+		"status_code", resp.StatusCode(),
+		"url", url,
+		"duration", resp.Time(),
+		"total_time", ti.TotalTime,
+		"server_time", ti.ServerTime,
+		"conn_reused", ti.IsConnReused,
+		"attempt", ti.RequestAttempt,
+		"body", resp.String(),
+	)
 }